@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/admin"
 	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/metrics"
 	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/middleware"
 	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/ratelimiter"
 	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
@@ -18,24 +21,25 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Metrics of the rate limiter and its storage
+	telemetry := metrics.New()
+
 	// Initialize storage
-	var store storage.Storage
-	switch cfg.StorageType {
-	case "redis":
-		store, err = storage.NewRedisStorage(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
-		if err != nil {
-			log.Printf("Failed to connect to Redis: %v, falling back to memory storage", err)
-			store = storage.NewMemoryStorage()
-		}
-	case "memory":
-		store = storage.NewMemoryStorage()
-	default:
-		log.Printf("Unsupported storage type: %s, using memory storage", cfg.StorageType)
-		store = storage.NewMemoryStorage()
+	store, err := newStorage(cfg, telemetry)
+	if err != nil {
+		log.Fatalf("Invalid Redis configuration: %v", err)
 	}
 
 	// Create rate limiter
 	rateLimiter := ratelimiter.New(store, cfg)
+	telemetry.RegisterRateLimiter(rateLimiter)
+	go telemetry.CountBlockedKeys(context.Background())
+
+	// Apply changes to the policy file without restarting
+	if cfg.PolicyFile != "" {
+		log.Printf("Watching policy file %s", cfg.PolicyFile)
+		go cfg.WatchPolicyFile(context.Background(), rateLimiter.SetConfig)
+	}
 
 	// Create middleware
 	rateLimiterMiddleware := middleware.NewRateLimiter(rateLimiter)
@@ -51,10 +55,23 @@ func main() {
 	// Wrap with rate limiter middleware
 	handler := rateLimiterMiddleware.Handler(mux)
 
+	// The admin API and the metrics are not rate limited, so they stay
+	// reachable while clients are blocked
+	root := http.NewServeMux()
+	root.Handle("/", handler)
+	if cfg.MetricsEnabled {
+		root.Handle("GET /metrics", telemetry.Handler())
+	}
+	if cfg.AdminToken != "" {
+		root.Handle("/admin/", admin.NewHandler(rateLimiter, cfg.AdminToken))
+	} else {
+		log.Printf("ADMIN_TOKEN not set, admin API disabled")
+	}
+
 	// Start server
 	server := &http.Server{
 		Addr:         ":8080",
-		Handler:      handler,
+		Handler:      root,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 	}
@@ -65,6 +82,46 @@ func main() {
 	}
 }
 
+// newStorage creates the storage selected by the configuration. Redis always
+// goes behind a circuit breaker, even when it is down at startup: the failure
+// policy applies until it answers, and then the breaker switches to it.
+func newStorage(cfg *config.Config, telemetry *metrics.Metrics) (storage.Storage, error) {
+	switch cfg.StorageType {
+	case "redis":
+		redisOptions, err := cfg.RedisOptions()
+		if err != nil {
+			return nil, err
+		}
+		redisStore, err := storage.OpenRedisStorage(redisOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		// Redis outages are handled by the failure policy
+		var fallback storage.Storage
+		if cfg.FailurePolicy == config.FailLocal {
+			fallback = telemetry.Instrument(storage.NewMemoryStorage(), "memory")
+		}
+		breaker := storage.NewCircuitBreaker(telemetry.Instrument(redisStore, "redis"), fallback, cfg.BreakerThreshold, cfg.BreakerCheckInterval)
+		telemetry.RegisterCircuitBreaker(breaker)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redisStore.Health(ctx); err != nil {
+			log.Printf("Failed to connect to Redis: %v, applying the failure policy (%s) until it answers", err, cfg.FailurePolicy)
+			breaker.Trip(err)
+		} else {
+			log.Printf("Using Redis storage (failure policy: %s)", cfg.FailurePolicy)
+		}
+		return breaker, nil
+	case "memory":
+		return telemetry.Instrument(storage.NewMemoryStorage(), "memory"), nil
+	default:
+		log.Printf("Unsupported storage type: %s, using memory storage", cfg.StorageType)
+		return telemetry.Instrument(storage.NewMemoryStorage(), "memory"), nil
+	}
+}
+
 func handleUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
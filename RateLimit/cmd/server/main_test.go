@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/metrics"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// unusedAddr returns a local address where nothing listens yet
+func unusedAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestNewStorageRedisDownAtStartup(t *testing.T) {
+	ctx := context.Background()
+	limit := storage.Limit{Rate: 5, Window: time.Minute}
+
+	for _, policy := range []config.FailurePolicy{config.FailLocal, config.FailClosed} {
+		t.Run(string(policy), func(t *testing.T) {
+			addr := unusedAddr(t)
+			cfg := &config.Config{
+				StorageType:          "redis",
+				RedisAddr:            addr,
+				RedisDialTimeout:     100 * time.Millisecond,
+				FailurePolicy:        policy,
+				BreakerThreshold:     3,
+				BreakerCheckInterval: 20 * time.Millisecond,
+			}
+
+			store, err := newStorage(cfg, metrics.New())
+			if err != nil {
+				t.Fatalf("Expected the storage to be created with Redis down, got %v", err)
+			}
+			defer store.Close()
+
+			breaker, ok := store.(*storage.CircuitBreaker)
+			if !ok {
+				t.Fatalf("Expected Redis behind a circuit breaker, got %T", store)
+			}
+			if !breaker.Open() {
+				t.Fatal("Expected the breaker to start open while Redis is down")
+			}
+
+			// The failure policy applies from the first request
+			result, err := store.CheckLimit(ctx, "ip:10.0.0.1", limit)
+			switch policy {
+			case config.FailLocal:
+				if err != nil || !result.Allowed {
+					t.Fatalf("Expected the local fallback to allow the request, got %+v %v", result, err)
+				}
+			case config.FailClosed:
+				if !errors.Is(err, storage.ErrUnavailable) {
+					t.Fatalf("Expected ErrUnavailable without a fallback, got %v", err)
+				}
+			}
+
+			server := miniredis.NewMiniRedis()
+			if err := server.StartAddr(addr); err != nil {
+				t.Fatalf("Failed to start miniredis: %v", err)
+			}
+			defer server.Close()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for breaker.Open() {
+				if time.Now().After(deadline) {
+					t.Fatal("Expected the breaker to close once Redis answers")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if result, err := store.CheckLimit(ctx, "ip:10.0.0.2", limit); err != nil || !result.Allowed {
+				t.Fatalf("Expected Redis to allow the request, got %+v %v", result, err)
+			}
+			if !server.Exists("rate_limit:ip:10.0.0.2") {
+				t.Errorf("Expected the request to be counted in Redis, got keys %v", server.Keys())
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/ratelimiter"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// Handler serves the admin API, which inspects and manages the rate limit
+// keys (ip:<ip>, token:<token> and route:<name>:ip:<ip> or route:<name>:token:<token>):
+//
+//	GET    /admin/blocked          lists the blocked keys
+//	GET    /admin/keys/{key}       shows the state of a key
+//	POST   /admin/keys/{key}/block blocks a key ({"duration": "5m"})
+//	DELETE /admin/keys/{key}/block unblocks a key
+//	POST   /admin/keys/{key}/reset clears the counters and the block of a key
+//
+// Every request must send the admin token as "Authorization: Bearer <token>".
+type Handler struct {
+	rateLimiter *ratelimiter.RateLimiter
+	token       string
+	mux         *http.ServeMux
+}
+
+// BlockedKey is a blocked key in the admin API
+type BlockedKey struct {
+	Key        string `json:"key"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// KeyResponse is the state of a key in the admin API
+type KeyResponse struct {
+	Key        string            `json:"key"`
+	Dimension  string            `json:"dimension"`
+	Algorithm  storage.Algorithm `json:"algorithm"`
+	Limit      int               `json:"limit"`
+	Window     string            `json:"window"`
+	Count      int               `json:"count"`
+	Remaining  int               `json:"remaining"`
+	Blocked    bool              `json:"blocked"`
+	TTLSeconds int               `json:"ttl_seconds,omitempty"`
+}
+
+// BlockRequest is the body of a block request
+type BlockRequest struct {
+	Duration string `json:"duration"`
+}
+
+// ErrorResponse represents the error response structure
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Code      int    `json:"code"`
+	Timestamp string `json:"timestamp"`
+}
+
+// NewHandler creates the admin API. The token must not be empty.
+func NewHandler(rateLimiter *ratelimiter.RateLimiter, token string) *Handler {
+	h := &Handler{rateLimiter: rateLimiter, token: token, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /admin/blocked", h.listBlocked)
+	h.mux.HandleFunc("GET /admin/keys/{key}", h.inspect)
+	h.mux.HandleFunc("POST /admin/keys/{key}/block", h.block)
+	h.mux.HandleFunc("DELETE /admin/keys/{key}/block", h.unblock)
+	h.mux.HandleFunc("POST /admin/keys/{key}/reset", h.reset)
+
+	return h
+}
+
+// ServeHTTP authenticates the request and routes it
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "Invalid or missing admin token")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) listBlocked(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.rateLimiter.ListBlocked(r.Context())
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	// Longest blocks first
+	slices.SortFunc(keys, func(a, b storage.BlockedKey) int {
+		return cmp.Or(cmp.Compare(b.TTL, a.TTL), strings.Compare(a.Key, b.Key))
+	})
+	blocked := make([]BlockedKey, 0, len(keys))
+	for _, key := range keys {
+		blocked = append(blocked, BlockedKey{Key: key.Key, TTLSeconds: seconds(key.TTL)})
+	}
+	writeJSON(w, http.StatusOK, blocked)
+}
+
+func (h *Handler) inspect(w http.ResponseWriter, r *http.Request) {
+	info, err := h.rateLimiter.Inspect(r.Context(), r.PathValue("key"))
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	response := KeyResponse{
+		Key:       info.Key,
+		Dimension: string(info.Dimension),
+		Algorithm: info.Limit.Algorithm,
+		Limit:     info.Limit.Rate,
+		Window:    info.Limit.Window.String(),
+		Count:     info.Count,
+		Remaining: info.Remaining,
+		Blocked:   info.Blocked,
+	}
+	if response.Algorithm == "" {
+		response.Algorithm = storage.AlgorithmSlidingLog
+	}
+	if info.Blocked {
+		response.TTLSeconds = seconds(info.BlockedFor)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) block(w http.ResponseWriter, r *http.Request) {
+	var request BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+	duration, err := time.ParseDuration(request.Duration)
+	if err != nil || duration <= 0 {
+		writeError(w, http.StatusBadRequest, "duration must be a positive duration such as 5m")
+		return
+	}
+
+	if err := h.rateLimiter.Block(r.Context(), r.PathValue("key"), duration); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	log.Printf("Admin blocked %s for %v", r.PathValue("key"), duration)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) unblock(w http.ResponseWriter, r *http.Request) {
+	if err := h.rateLimiter.Unblock(r.Context(), r.PathValue("key")); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	log.Printf("Admin unblocked %s", r.PathValue("key"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) reset(w http.ResponseWriter, r *http.Request) {
+	if err := h.rateLimiter.Reset(r.Context(), r.PathValue("key")); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	log.Printf("Admin reset %s", r.PathValue("key"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeStorageError answers 400 for keys the rate limiter doesn't know and 500
+// otherwise. Storage errors are only logged: they may expose addresses or
+// other details of the infrastructure.
+func (h *Handler) writeStorageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ratelimiter.ErrUnknownKey) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("Admin API error: %v", err)
+	writeError(w, http.StatusInternalServerError, "Storage unavailable")
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Failed to encode admin response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, ErrorResponse{
+		Error:     http.StatusText(statusCode),
+		Message:   message,
+		Code:      statusCode,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// seconds rounds a TTL up, so a key still blocked never shows 0
+func seconds(ttl time.Duration) int {
+	return int(math.Ceil(ttl.Seconds()))
+}
@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/ratelimiter"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+const testToken = "secret"
+
+func newTestHandler(t *testing.T) (*Handler, *ratelimiter.RateLimiter) {
+	t.Helper()
+
+	cfg := &config.Config{
+		IPRateLimit:        2,
+		IPBlockDuration:    time.Minute,
+		TokenRateLimit:     10,
+		TokenBlockDuration: time.Minute,
+		TokenConfigs:       make(map[string]config.TokenConfig),
+	}
+
+	store := storage.NewMemoryStorage()
+	t.Cleanup(func() { store.Close() })
+	rateLimiter := ratelimiter.New(store, cfg)
+	return NewHandler(rateLimiter, testToken), rateLimiter
+}
+
+func send(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAdminAuthentication(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for name, header := range map[string]string{
+		"Missing token": "",
+		"Wrong token":   "Bearer wrong",
+		"Not bearer":    "Basic " + testToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/blocked", nil)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("Expected 401, got %d", recorder.Code)
+			}
+		})
+	}
+
+	t.Run("Empty admin token", func(t *testing.T) {
+		_, rateLimiter := newTestHandler(t)
+		req := httptest.NewRequest("GET", "/admin/blocked", nil)
+		req.Header.Set("Authorization", "Bearer ")
+		recorder := httptest.NewRecorder()
+		NewHandler(rateLimiter, "").ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestAdminKeys(t *testing.T) {
+	h, rateLimiter := newTestHandler(t)
+	ctx := context.Background()
+
+	// Exceed the IP limit, blocking 10.0.0.1
+	for i := 0; i < 3; i++ {
+		rateLimiter.Check(ctx, "10.0.0.1", "")
+	}
+
+	t.Run("List blocked keys", func(t *testing.T) {
+		recorder := send(h, "GET", "/admin/blocked", "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", recorder.Code)
+		}
+		var blocked []BlockedKey
+		if err := json.NewDecoder(recorder.Body).Decode(&blocked); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(blocked) != 1 || blocked[0].Key != "ip:10.0.0.1" || blocked[0].TTLSeconds != 60 {
+			t.Fatalf("Unexpected blocked keys: %+v", blocked)
+		}
+	})
+
+	t.Run("Inspect key", func(t *testing.T) {
+		recorder := send(h, "GET", "/admin/keys/ip:10.0.0.1", "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", recorder.Code)
+		}
+		var key KeyResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&key); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if key.Dimension != "ip" || key.Algorithm != storage.AlgorithmSlidingLog || key.Limit != 2 || key.Count != 2 || !key.Blocked || key.TTLSeconds != 60 {
+			t.Errorf("Unexpected key state: %+v", key)
+		}
+	})
+
+	t.Run("Unblock key", func(t *testing.T) {
+		if recorder := send(h, "DELETE", "/admin/keys/ip:10.0.0.1/block", ""); recorder.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", recorder.Code)
+		}
+		if blocked, _ := rateLimiter.ListBlocked(ctx); len(blocked) != 0 {
+			t.Fatalf("Expected no blocked keys, got %v", blocked)
+		}
+		// The counters are kept, so the IP is still over its limit
+		if result, _ := rateLimiter.Check(ctx, "10.0.0.1", ""); result.Allowed {
+			t.Fatal("Expected the IP to stay limited after unblock")
+		}
+	})
+
+	t.Run("Reset key", func(t *testing.T) {
+		if recorder := send(h, "POST", "/admin/keys/ip:10.0.0.1/reset", ""); recorder.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", recorder.Code)
+		}
+		if result, _ := rateLimiter.Check(ctx, "10.0.0.1", ""); !result.Allowed {
+			t.Fatal("Expected the IP to be allowed after reset")
+		}
+	})
+
+	t.Run("Block key", func(t *testing.T) {
+		recorder := send(h, "POST", "/admin/keys/token:abc123/block", `{"duration": "10m"}`)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", recorder.Code)
+		}
+		if result, _ := rateLimiter.Check(ctx, "10.0.0.2", "abc123"); result.Allowed {
+			t.Fatal("Expected the blocked token to be denied")
+		}
+		if blocked, _ := rateLimiter.ListBlocked(ctx); len(blocked) != 1 || blocked[0].TTL <= 9*time.Minute {
+			t.Fatalf("Expected the token to be blocked for 10m, got %v", blocked)
+		}
+	})
+
+	t.Run("Bad requests", func(t *testing.T) {
+		testCases := []struct {
+			name, method, path, body string
+		}{
+			{"Unknown key", "GET", "/admin/keys/user:1", ""},
+			{"Invalid IP", "POST", "/admin/keys/ip:nope/reset", ""},
+			{"Missing duration", "POST", "/admin/keys/ip:10.0.0.1/block", `{}`},
+			{"Invalid duration", "POST", "/admin/keys/ip:10.0.0.1/block", `{"duration": "forever"}`},
+			{"Invalid body", "POST", "/admin/keys/ip:10.0.0.1/block", `duration=5m`},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				if recorder := send(h, tc.method, tc.path, tc.body); recorder.Code != http.StatusBadRequest {
+					t.Errorf("Expected 400, got %d", recorder.Code)
+				}
+			})
+		}
+	})
+}
+
+// failingStorage fails every call with an error that names its address
+type failingStorage struct {
+	*storage.MemoryStorage
+}
+
+var errRedisDown = errors.New("dial tcp 10.1.2.3:6379: connect: connection refused")
+
+func (failingStorage) ListBlocked(ctx context.Context) ([]storage.BlockedKey, error) {
+	return nil, errRedisDown
+}
+
+func (failingStorage) Reset(ctx context.Context, key string) error {
+	return errRedisDown
+}
+
+func TestAdminStorageError(t *testing.T) {
+	_, rateLimiter := newTestHandler(t)
+	store := failingStorage{storage.NewMemoryStorage()}
+	defer store.Close()
+	h := NewHandler(ratelimiter.New(store, rateLimiter.Config()), testToken)
+
+	for path, method := range map[string]string{"/admin/blocked": "GET", "/admin/keys/ip:10.0.0.1/reset": "POST"} {
+		recorder := send(h, method, path, "")
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected 500, got %d", path, recorder.Code)
+		}
+		if strings.Contains(recorder.Body.String(), "10.1.2.3") {
+			t.Errorf("%s: expected the storage error to stay out of the response, got %s", path, recorder.Body.String())
+		}
+		var response ErrorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || response.Message != "Storage unavailable" {
+			t.Errorf("%s: expected a generic message, got %+v %v", path, response, err)
+		}
+	}
+}
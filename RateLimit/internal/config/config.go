@@ -1,11 +1,28 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// RateWindow is the window of every limit: limits are requests per second
+const RateWindow = time.Second
+
+// FailurePolicy decides what happens to requests while the storage is down
+type FailurePolicy string
+
+const (
+	FailOpen   FailurePolicy = "fail_open"   // allow the requests and log the error
+	FailClosed FailurePolicy = "fail_closed" // reject the requests with 503
+	FailLocal  FailurePolicy = "local"       // limit each instance with in-memory storage
 )
 
 // Config holds all configuration for the rate limiter
@@ -15,24 +32,77 @@ type Config struct {
 
 	// Storage configuration
 	StorageType   string
-	RedisAddr     string
+	RedisAddr     string // comma-separated for Sentinel and cluster nodes
 	RedisPassword string
 	RedisDB       int
 
+	// Redis connection options
+	RedisMode                  storage.RedisMode // standalone, sentinel or cluster
+	RedisMasterName            string            // Sentinel master name
+	RedisSentinelPassword      string
+	RedisTLS                   bool
+	RedisTLSCAFile             string // CA bundle to verify the server (system roots when empty)
+	RedisTLSInsecureSkipVerify bool
+	RedisPoolSize              int // connections per node (go-redis default when zero)
+	RedisDialTimeout           time.Duration
+	RedisReadTimeout           time.Duration
+	RedisWriteTimeout          time.Duration
+	RedisKeyPrefix             string // namespace for every key, so services can share one Redis
+
+	// Storage outage handling
+	FailurePolicy        FailurePolicy
+	BreakerThreshold     int           // consecutive storage errors that open the circuit breaker
+	BreakerCheckInterval time.Duration // how often an open breaker checks the storage health
+
 	// Rate limiting configuration
-	IPRateLimit        int           // requests per second for IP-based limiting
-	IPBlockDuration    time.Duration // block duration when IP limit is exceeded
-	TokenRateLimit     int           // default requests per second for token-based limiting
-	TokenBlockDuration time.Duration // block duration when token limit is exceeded
+	IPRateLimit        int               // requests per second for IP-based limiting
+	IPBlockDuration    time.Duration     // block duration when IP limit is exceeded
+	IPAlgorithm        storage.Algorithm // algorithm for IP-based limiting
+	IPBurst            int               // token bucket capacity for IP-based limiting
+	TokenRateLimit     int               // default requests per second for token-based limiting
+	TokenBlockDuration time.Duration     // block duration when token limit is exceeded
+	TokenAlgorithm     storage.Algorithm // default algorithm for token-based limiting
+	TokenBurst         int               // default token bucket capacity for token-based limiting
 
 	// Token-specific configurations (can be extended)
 	TokenConfigs map[string]TokenConfig
+
+	// Route-specific policies, matched in order
+	Routes []RoutePolicy
+
+	// Policy file overriding the limits above, reloaded when it changes
+	PolicyFile           string
+	PolicyReloadInterval time.Duration
+
+	// Token of the admin API (disabled when empty)
+	AdminToken string
+
+	// Serve the Prometheus metrics on /metrics
+	MetricsEnabled bool
+
+	// env is the configuration from the environment, before the policy file
+	env *Config
+	// policyData is the content of the policy file this configuration was loaded from
+	policyData []byte
 }
 
 // TokenConfig holds configuration for specific tokens
 type TokenConfig struct {
-	RateLimit     int           // requests per second for this token
-	BlockDuration time.Duration // block duration when this token's limit is exceeded
+	RateLimit     int               // requests per second for this token
+	BlockDuration time.Duration     // block duration when this token's limit is exceeded
+	Algorithm     storage.Algorithm // algorithm for this token
+	Burst         int               // token bucket capacity for this token
+}
+
+// Limit returns the storage limit for this token
+func (t TokenConfig) Limit() storage.Limit {
+	return storage.Limit{
+		Algorithm:     t.Algorithm,
+		Rate:          t.RateLimit,
+		Window:        RateWindow,
+		Burst:         t.Burst,
+		BlockDuration: t.BlockDuration,
+	}
 }
 
 // Load loads configuration from environment variables and .env file
@@ -41,51 +111,178 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		Port:               getEnvString("PORT", "8080"),
-		StorageType:        getEnvString("STORAGE_TYPE", "redis"),
-		RedisAddr:          getEnvString("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:      getEnvString("REDIS_PASSWORD", ""),
-		RedisDB:            getEnvInt("REDIS_DB", 0),
-		IPRateLimit:        getEnvInt("IP_RATE_LIMIT", 10),
-		IPBlockDuration:    getEnvDuration("IP_BLOCK_DURATION", "5m"),
-		TokenRateLimit:     getEnvInt("TOKEN_RATE_LIMIT", 100),
-		TokenBlockDuration: getEnvDuration("TOKEN_BLOCK_DURATION", "5m"),
-		TokenConfigs:       make(map[string]TokenConfig),
+		Port:                       getEnvString("PORT", "8080"),
+		StorageType:                getEnvString("STORAGE_TYPE", "redis"),
+		RedisAddr:                  getEnvString("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:              getEnvString("REDIS_PASSWORD", ""),
+		RedisDB:                    getEnvInt("REDIS_DB", 0),
+		RedisMasterName:            getEnvString("REDIS_MASTER_NAME", ""),
+		RedisSentinelPassword:      getEnvString("REDIS_SENTINEL_PASSWORD", ""),
+		RedisTLS:                   getEnvString("REDIS_TLS", "false") == "true",
+		RedisTLSCAFile:             getEnvString("REDIS_TLS_CA_FILE", ""),
+		RedisTLSInsecureSkipVerify: getEnvString("REDIS_TLS_INSECURE_SKIP_VERIFY", "false") == "true",
+		RedisPoolSize:              getEnvInt("REDIS_POOL_SIZE", 0),
+		RedisDialTimeout:           getEnvDuration("REDIS_DIAL_TIMEOUT", "5s"),
+		RedisReadTimeout:           getEnvDuration("REDIS_READ_TIMEOUT", "3s"),
+		RedisWriteTimeout:          getEnvDuration("REDIS_WRITE_TIMEOUT", "3s"),
+		RedisKeyPrefix:             getEnvString("REDIS_KEY_PREFIX", ""),
+		IPRateLimit:                getEnvInt("IP_RATE_LIMIT", 10),
+		IPBlockDuration:            getEnvDuration("IP_BLOCK_DURATION", "5m"),
+		TokenRateLimit:             getEnvInt("TOKEN_RATE_LIMIT", 100),
+		TokenBlockDuration:         getEnvDuration("TOKEN_BLOCK_DURATION", "5m"),
+		IPBurst:                    getEnvInt("IP_BURST", 0),
+		TokenBurst:                 getEnvInt("TOKEN_BURST", 0),
+		TokenConfigs:               make(map[string]TokenConfig),
+		PolicyFile:                 getEnvString("POLICY_FILE", ""),
+		PolicyReloadInterval:       getEnvDuration("POLICY_RELOAD_INTERVAL", "5s"),
+		AdminToken:                 getEnvString("ADMIN_TOKEN", ""),
+		MetricsEnabled:             getEnvString("METRICS_ENABLED", "true") == "true",
+		BreakerThreshold:           getEnvInt("STORAGE_BREAKER_THRESHOLD", 3),
+		BreakerCheckInterval:       getEnvDuration("STORAGE_BREAKER_CHECK_INTERVAL", "5s"),
+	}
+
+	var err error
+	if cfg.RedisMode, err = storage.ParseRedisMode(getEnvString("REDIS_MODE", "")); err != nil {
+		return nil, err
+	}
+	if cfg.RedisMode == storage.RedisSentinel && cfg.RedisMasterName == "" {
+		return nil, fmt.Errorf("REDIS_MASTER_NAME is required when REDIS_MODE=sentinel")
+	}
+
+	switch policy := FailurePolicy(getEnvString("STORAGE_FAILURE_POLICY", string(FailClosed))); policy {
+	case FailOpen, FailClosed, FailLocal:
+		cfg.FailurePolicy = policy
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_FAILURE_POLICY %q (use fail_open, fail_closed or local)", policy)
+	}
+
+	// Both intervals drive tickers, which require a positive period
+	if cfg.BreakerCheckInterval <= 0 {
+		return nil, fmt.Errorf("STORAGE_BREAKER_CHECK_INTERVAL must be positive, got %v", cfg.BreakerCheckInterval)
+	}
+	if cfg.PolicyReloadInterval <= 0 {
+		return nil, fmt.Errorf("POLICY_RELOAD_INTERVAL must be positive, got %v", cfg.PolicyReloadInterval)
+	}
+
+	// The limits are divided by their rate, and a negative burst would never fill
+	if cfg.IPRateLimit <= 0 {
+		return nil, fmt.Errorf("IP_RATE_LIMIT must be positive, got %d", cfg.IPRateLimit)
+	}
+	if cfg.TokenRateLimit <= 0 {
+		return nil, fmt.Errorf("TOKEN_RATE_LIMIT must be positive, got %d", cfg.TokenRateLimit)
+	}
+	if cfg.IPBurst < 0 {
+		return nil, fmt.Errorf("IP_BURST must not be negative, got %d", cfg.IPBurst)
+	}
+	if cfg.TokenBurst < 0 {
+		return nil, fmt.Errorf("TOKEN_BURST must not be negative, got %d", cfg.TokenBurst)
+	}
+
+	if cfg.IPAlgorithm, err = getEnvAlgorithm("IP_ALGORITHM", ""); err != nil {
+		return nil, err
+	}
+	if cfg.TokenAlgorithm, err = getEnvAlgorithm("TOKEN_ALGORITHM", ""); err != nil {
+		return nil, err
 	}
 
 	// Load token-specific configurations
 	// Format: TOKEN_<TOKEN_NAME>_RATE_LIMIT and TOKEN_<TOKEN_NAME>_BLOCK_DURATION
 	// Example: TOKEN_ABC123_RATE_LIMIT=50, TOKEN_ABC123_BLOCK_DURATION=10m
-	cfg.loadTokenConfigs()
+	// Optional: TOKEN_<TOKEN_NAME>_ALGORITHM and TOKEN_<TOKEN_NAME>_BURST
+	if err := cfg.loadTokenConfigs(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.loadRoutePolicies(); err != nil {
+		return nil, err
+	}
+
+	// Policies from the file override the environment
+	if cfg.PolicyFile != "" {
+		return cfg.LoadPolicyFile(cfg.PolicyFile)
+	}
 
 	return cfg, nil
 }
 
-func (c *Config) loadTokenConfigs() {
-	// Load predefined tokens from environment variables
-	// This is a simple implementation - in production, you might want to load from a database
+// IPLimit returns the limit applied to each IP address
+func (c *Config) IPLimit() storage.Limit {
+	return storage.Limit{
+		Algorithm:     c.IPAlgorithm,
+		Rate:          c.IPRateLimit,
+		Window:        RateWindow,
+		Burst:         c.IPBurst,
+		BlockDuration: c.IPBlockDuration,
+	}
+}
 
-	// Example token configurations
-	if rateLimit := getEnvInt("TOKEN_ABC123_RATE_LIMIT", 0); rateLimit > 0 {
-		c.TokenConfigs["abc123"] = TokenConfig{
-			RateLimit:     rateLimit,
-			BlockDuration: getEnvDuration("TOKEN_ABC123_BLOCK_DURATION", "5m"),
+// RedisOptions returns the options of the Redis connection
+func (c *Config) RedisOptions() (storage.RedisOptions, error) {
+	opts := storage.RedisOptions{
+		Mode:             c.RedisMode,
+		MasterName:       c.RedisMasterName,
+		Password:         c.RedisPassword,
+		SentinelPassword: c.RedisSentinelPassword,
+		DB:               c.RedisDB,
+		PoolSize:         c.RedisPoolSize,
+		DialTimeout:      c.RedisDialTimeout,
+		ReadTimeout:      c.RedisReadTimeout,
+		WriteTimeout:     c.RedisWriteTimeout,
+		KeyPrefix:        c.RedisKeyPrefix,
+	}
+	for _, addr := range strings.Split(c.RedisAddr, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			opts.Addrs = append(opts.Addrs, addr)
 		}
 	}
 
-	if rateLimit := getEnvInt("TOKEN_XYZ789_RATE_LIMIT", 0); rateLimit > 0 {
-		c.TokenConfigs["xyz789"] = TokenConfig{
-			RateLimit:     rateLimit,
-			BlockDuration: getEnvDuration("TOKEN_XYZ789_BLOCK_DURATION", "5m"),
+	if c.RedisTLS {
+		opts.TLS = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: c.RedisTLSInsecureSkipVerify,
+		}
+		if c.RedisTLSCAFile != "" {
+			pem, err := os.ReadFile(c.RedisTLSCAFile)
+			if err != nil {
+				return storage.RedisOptions{}, fmt.Errorf("failed to read REDIS_TLS_CA_FILE: %w", err)
+			}
+			opts.TLS.RootCAs = x509.NewCertPool()
+			if !opts.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return storage.RedisOptions{}, fmt.Errorf("no certificate found in REDIS_TLS_CA_FILE %s", c.RedisTLSCAFile)
+			}
 		}
 	}
 
-	if rateLimit := getEnvInt("TOKEN_PREMIUM_RATE_LIMIT", 0); rateLimit > 0 {
-		c.TokenConfigs["premium"] = TokenConfig{
+	return opts, nil
+}
+
+func (c *Config) loadTokenConfigs() error {
+	// Load predefined tokens from environment variables
+	// This is a simple implementation - in production, you might want to load from a database
+
+	// Example token configurations
+	for token, envName := range map[string]string{"abc123": "ABC123", "xyz789": "XYZ789", "premium": "PREMIUM"} {
+		rateLimit := getEnvInt("TOKEN_"+envName+"_RATE_LIMIT", 0)
+		if rateLimit <= 0 {
+			continue
+		}
+		algorithm, err := getEnvAlgorithm("TOKEN_"+envName+"_ALGORITHM", c.TokenAlgorithm)
+		if err != nil {
+			return err
+		}
+		burst := getEnvInt("TOKEN_"+envName+"_BURST", c.TokenBurst)
+		if burst < 0 {
+			return fmt.Errorf("TOKEN_%s_BURST must not be negative, got %d", envName, burst)
+		}
+		c.TokenConfigs[token] = TokenConfig{
 			RateLimit:     rateLimit,
-			BlockDuration: getEnvDuration("TOKEN_PREMIUM_BLOCK_DURATION", "5m"),
+			BlockDuration: getEnvDuration("TOKEN_"+envName+"_BLOCK_DURATION", "5m"),
+			Algorithm:     algorithm,
+			Burst:         burst,
 		}
 	}
+
+	return nil
 }
 
 // GetTokenConfig returns the configuration for a specific token
@@ -96,6 +293,8 @@ func (c *Config) GetTokenConfig(token string) (TokenConfig, bool) {
 		return TokenConfig{
 			RateLimit:     c.TokenRateLimit,
 			BlockDuration: c.TokenBlockDuration,
+			Algorithm:     c.TokenAlgorithm,
+			Burst:         c.TokenBurst,
 		}, false
 	}
 	return config, true
@@ -117,6 +316,19 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAlgorithm reads a rate limit algorithm, failing on unknown names
+func getEnvAlgorithm(key string, defaultValue storage.Algorithm) (storage.Algorithm, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return storage.ParseAlgorithm(string(defaultValue))
+	}
+	algorithm, err := storage.ParseAlgorithm(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return algorithm, nil
+}
+
 func getEnvDuration(key string, defaultValue string) time.Duration {
 	value := getEnvString(key, defaultValue)
 	if duration, err := time.ParseDuration(value); err == nil {
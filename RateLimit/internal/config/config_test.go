@@ -1,9 +1,15 @@
 package config
 
 import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
 )
 
 func TestConfigLoad(t *testing.T) {
@@ -132,6 +138,197 @@ func TestConfigLoad(t *testing.T) {
 	})
 }
 
+func TestAlgorithmConfig(t *testing.T) {
+	t.Run("Algorithm and burst via env vars", func(t *testing.T) {
+		t.Setenv("IP_ALGORITHM", "token_bucket")
+		t.Setenv("IP_BURST", "20")
+		t.Setenv("TOKEN_ALGORITHM", "sliding_window")
+		t.Setenv("TOKEN_PREMIUM_RATE_LIMIT", "1000")
+		t.Setenv("TOKEN_PREMIUM_ALGORITHM", "fixed_window")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load should not fail: %v", err)
+		}
+
+		ipLimit := cfg.IPLimit()
+		if ipLimit.Algorithm != storage.AlgorithmTokenBucket || ipLimit.Burst != 20 || ipLimit.Window != RateWindow {
+			t.Errorf("Unexpected IP limit: %+v", ipLimit)
+		}
+
+		premium, _ := cfg.GetTokenConfig("premium")
+		if premium.Algorithm != storage.AlgorithmFixedWindow {
+			t.Errorf("Expected premium token algorithm fixed_window, got %s", premium.Algorithm)
+		}
+
+		// Tokens without their own algorithm use the default one
+		regular, _ := cfg.GetTokenConfig("regular")
+		if regular.Limit().Algorithm != storage.AlgorithmSlidingWindow {
+			t.Errorf("Expected default token algorithm sliding_window, got %s", regular.Algorithm)
+		}
+	})
+
+	t.Run("Default algorithm", func(t *testing.T) {
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load should not fail: %v", err)
+		}
+		if cfg.IPAlgorithm != storage.AlgorithmSlidingLog || cfg.TokenAlgorithm != storage.AlgorithmSlidingLog {
+			t.Errorf("Expected sliding_log by default, got %s and %s", cfg.IPAlgorithm, cfg.TokenAlgorithm)
+		}
+	})
+
+	t.Run("Unknown algorithm", func(t *testing.T) {
+		t.Setenv("IP_ALGORITHM", "leaky_bucket")
+
+		if _, err := Load(); err == nil {
+			t.Fatal("Load should fail with an unknown algorithm")
+		}
+	})
+}
+
+func TestRedisConfig(t *testing.T) {
+	t.Run("Sentinel with connection options", func(t *testing.T) {
+		t.Setenv("REDIS_MODE", "sentinel")
+		t.Setenv("REDIS_ADDR", "sentinel-1:26379, sentinel-2:26379,sentinel-3:26379")
+		t.Setenv("REDIS_MASTER_NAME", "mymaster")
+		t.Setenv("REDIS_POOL_SIZE", "50")
+		t.Setenv("REDIS_READ_TIMEOUT", "500ms")
+		t.Setenv("REDIS_KEY_PREFIX", "checkout:")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load should not fail: %v", err)
+		}
+		opts, err := cfg.RedisOptions()
+		if err != nil {
+			t.Fatalf("RedisOptions should not fail: %v", err)
+		}
+
+		if opts.Mode != storage.RedisSentinel || opts.MasterName != "mymaster" || len(opts.Addrs) != 3 || opts.Addrs[1] != "sentinel-2:26379" {
+			t.Errorf("Unexpected Sentinel options: %+v", opts)
+		}
+		if opts.PoolSize != 50 || opts.ReadTimeout != 500*time.Millisecond || opts.DialTimeout != 5*time.Second || opts.KeyPrefix != "checkout:" {
+			t.Errorf("Unexpected connection options: %+v", opts)
+		}
+		if opts.TLS != nil {
+			t.Error("Expected TLS to be disabled by default")
+		}
+	})
+
+	t.Run("TLS with CA file", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		if err := os.WriteFile(caFile, ca, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &Config{RedisAddr: "redis:6380", RedisTLS: true, RedisTLSCAFile: caFile}
+		opts, err := cfg.RedisOptions()
+		if err != nil {
+			t.Fatalf("RedisOptions should not fail: %v", err)
+		}
+		if opts.TLS == nil || opts.TLS.RootCAs == nil || opts.TLS.InsecureSkipVerify {
+			t.Errorf("Unexpected TLS config: %+v", opts.TLS)
+		}
+
+		cfg.RedisTLSCAFile = filepath.Join(t.TempDir(), "missing.pem")
+		if _, err := cfg.RedisOptions(); err == nil {
+			t.Error("Expected an error for a missing CA file")
+		}
+	})
+
+	t.Run("Invalid Redis configuration", func(t *testing.T) {
+		testCases := map[string]map[string]string{
+			"Unknown mode":            {"REDIS_MODE": "replica"},
+			"Sentinel without master": {"REDIS_MODE": "sentinel"},
+		}
+
+		for name, env := range testCases {
+			t.Run(name, func(t *testing.T) {
+				for key, value := range env {
+					t.Setenv(key, value)
+				}
+				if _, err := Load(); err == nil {
+					t.Fatal("Load should fail")
+				}
+			})
+		}
+	})
+}
+
+func TestFailurePolicyConfig(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load should not fail: %v", err)
+	}
+	if cfg.FailurePolicy != FailClosed || cfg.BreakerThreshold != 3 || cfg.BreakerCheckInterval != 5*time.Second {
+		t.Errorf("Unexpected defaults: %s %d %v", cfg.FailurePolicy, cfg.BreakerThreshold, cfg.BreakerCheckInterval)
+	}
+
+	t.Setenv("STORAGE_FAILURE_POLICY", "local")
+	t.Setenv("STORAGE_BREAKER_THRESHOLD", "5")
+	t.Setenv("STORAGE_BREAKER_CHECK_INTERVAL", "1s")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load should not fail: %v", err)
+	}
+	if cfg.FailurePolicy != FailLocal || cfg.BreakerThreshold != 5 || cfg.BreakerCheckInterval != time.Second {
+		t.Errorf("Unexpected failure policy config: %s %d %v", cfg.FailurePolicy, cfg.BreakerThreshold, cfg.BreakerCheckInterval)
+	}
+
+	t.Setenv("STORAGE_FAILURE_POLICY", "retry")
+	if _, err := Load(); err == nil {
+		t.Fatal("Load should fail with an unknown failure policy")
+	}
+}
+
+func TestIntervalConfig(t *testing.T) {
+	for _, key := range []string{"STORAGE_BREAKER_CHECK_INTERVAL", "POLICY_RELOAD_INTERVAL"} {
+		for _, value := range []string{"0", "0s", "-1s"} {
+			t.Run(key+"="+value, func(t *testing.T) {
+				t.Setenv(key, value)
+				if _, err := Load(); err == nil {
+					t.Fatal("Load should fail with a non-positive interval")
+				}
+			})
+		}
+	}
+}
+
+func TestLimitConfig(t *testing.T) {
+	invalid := map[string][]string{
+		"IP_RATE_LIMIT":      {"0", "-5"},
+		"TOKEN_RATE_LIMIT":   {"0", "-5"},
+		"IP_BURST":           {"-1"},
+		"TOKEN_BURST":        {"-1"},
+		"TOKEN_ABC123_BURST": {"-1"},
+	}
+	for key, values := range invalid {
+		for _, value := range values {
+			t.Run(key+"="+value, func(t *testing.T) {
+				t.Setenv("TOKEN_ABC123_RATE_LIMIT", "50")
+				t.Setenv(key, value)
+				if _, err := Load(); err == nil {
+					t.Fatalf("Load should fail with %s=%s", key, value)
+				}
+			})
+		}
+	}
+
+	t.Run("Route window below 1µs", func(t *testing.T) {
+		t.Setenv("ROUTE_POLICIES", "fast")
+		t.Setenv("ROUTE_FAST_PATH", "/fast")
+		t.Setenv("ROUTE_FAST_RATE_LIMIT", "5")
+		t.Setenv("ROUTE_FAST_WINDOW", "500ns")
+		if _, err := Load(); err == nil {
+			t.Fatal("Load should fail with a route window below 1µs")
+		}
+	})
+}
+
 func TestDurationParsing(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// PolicyFile is the layout of the file named by POLICY_FILE. Every section is
+// optional and overrides the environment configuration. Being a superset of
+// JSON, YAML also covers .json files.
+type PolicyFile struct {
+	IP     *LimitSpec           `yaml:"ip"`     // IP-based limit
+	Token  *LimitSpec           `yaml:"token"`  // default token limit
+	Tokens map[string]LimitSpec `yaml:"tokens"` // token-specific limits, merged over the env ones
+	Routes []RouteSpec          `yaml:"routes"` // route policies, replacing the env ones when present
+}
+
+// LimitSpec is a limit as written in the policy file
+type LimitSpec struct {
+	RateLimit     int    `yaml:"rate_limit"`
+	BlockDuration string `yaml:"block_duration"`
+	Algorithm     string `yaml:"algorithm"`
+	Burst         int    `yaml:"burst"`
+}
+
+// RouteSpec is a route policy as written in the policy file
+type RouteSpec struct {
+	Name          string   `yaml:"name"`
+	Path          string   `yaml:"path"`
+	Methods       []string `yaml:"methods"`
+	RateLimit     int      `yaml:"rate_limit"`
+	Window        string   `yaml:"window"`
+	BlockDuration string   `yaml:"block_duration"`
+	Algorithm     string   `yaml:"algorithm"`
+	Burst         int      `yaml:"burst"`
+	Exempt        bool     `yaml:"exempt"`
+}
+
+// ParsePolicyFile decodes a policy file, rejecting unknown fields
+func ParsePolicyFile(data []byte) (*PolicyFile, error) {
+	var file PolicyFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+	return &file, nil
+}
+
+// WithPolicies returns a copy of the configuration with the policies of the
+// file applied. The copy is only returned when every policy is valid. The
+// policies always apply over the environment configuration, so a token or
+// route removed from the file is gone after a reload.
+func (c *Config) WithPolicies(file *PolicyFile) (*Config, error) {
+	base := c
+	if c.env != nil {
+		base = c.env
+	}
+	cfg := *base
+	cfg.env = base
+	cfg.TokenConfigs = make(map[string]TokenConfig, len(base.TokenConfigs)+len(file.Tokens))
+	for token, tokenConfig := range base.TokenConfigs {
+		cfg.TokenConfigs[token] = tokenConfig
+	}
+
+	if file.IP != nil {
+		ip, err := file.IP.tokenConfig("ip", base.IPBlockDuration)
+		if err != nil {
+			return nil, err
+		}
+		cfg.IPRateLimit, cfg.IPBlockDuration, cfg.IPAlgorithm, cfg.IPBurst = ip.RateLimit, ip.BlockDuration, ip.Algorithm, ip.Burst
+	}
+
+	if file.Token != nil {
+		token, err := file.Token.tokenConfig("token", base.TokenBlockDuration)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TokenRateLimit, cfg.TokenBlockDuration, cfg.TokenAlgorithm, cfg.TokenBurst = token.RateLimit, token.BlockDuration, token.Algorithm, token.Burst
+	}
+
+	for token, spec := range file.Tokens {
+		tokenConfig, err := spec.tokenConfig("tokens."+token, cfg.TokenBlockDuration)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TokenConfigs[token] = tokenConfig
+	}
+
+	if file.Routes != nil {
+		cfg.Routes = make([]RoutePolicy, 0, len(file.Routes))
+		names := make(map[string]bool)
+		for i, spec := range file.Routes {
+			route, err := spec.routePolicy()
+			if err != nil {
+				return nil, fmt.Errorf("routes[%d]: %w", i, err)
+			}
+			if names[route.Name] {
+				return nil, fmt.Errorf("routes[%d]: duplicate route policy %s", i, route.Name)
+			}
+			names[route.Name] = true
+			cfg.Routes = append(cfg.Routes, route)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// LoadPolicyFile reads a policy file and applies it to the configuration
+func (c *Config) LoadPolicyFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ParsePolicyFile(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := c.WithPolicies(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg.policyData = data
+	return cfg, nil
+}
+
+// WatchPolicyFile checks PolicyFile every PolicyReloadInterval and, when its
+// contents differ from the ones c was loaded from, calls apply with the configuration built from them. A
+// file that fails to load or validate is logged and ignored, so the last
+// valid configuration keeps running. It returns when ctx is done.
+func (c *Config) WatchPolicyFile(ctx context.Context, apply func(*Config)) {
+	ticker := time.NewTicker(c.PolicyReloadInterval)
+	defer ticker.Stop()
+
+	// Comparing the contents catches edits that keep the size and modification time
+	last := c.policyData
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(c.PolicyFile)
+		if err != nil {
+			log.Printf("Failed to read policy file %s: %v", c.PolicyFile, err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+
+		file, err := ParsePolicyFile(data)
+		if err != nil {
+			log.Printf("Rejected policy file %s, keeping the last valid configuration: %v", c.PolicyFile, err)
+			continue
+		}
+		cfg, err := c.WithPolicies(file)
+		if err != nil {
+			log.Printf("Rejected policy file %s, keeping the last valid configuration: %v", c.PolicyFile, err)
+			continue
+		}
+		apply(cfg)
+		log.Printf("Reloaded policy file %s", c.PolicyFile)
+	}
+}
+
+func (s LimitSpec) tokenConfig(section string, defaultBlockDuration time.Duration) (TokenConfig, error) {
+	if s.RateLimit <= 0 {
+		return TokenConfig{}, fmt.Errorf("%s: rate_limit must be positive", section)
+	}
+	if s.Burst < 0 {
+		return TokenConfig{}, fmt.Errorf("%s: burst must not be negative", section)
+	}
+	blockDuration, err := parseSpecDuration(s.BlockDuration, defaultBlockDuration)
+	if err != nil {
+		return TokenConfig{}, fmt.Errorf("%s: block_duration: %w", section, err)
+	}
+	algorithm, err := storage.ParseAlgorithm(s.Algorithm)
+	if err != nil {
+		return TokenConfig{}, fmt.Errorf("%s: %w", section, err)
+	}
+	return TokenConfig{RateLimit: s.RateLimit, BlockDuration: blockDuration, Algorithm: algorithm, Burst: s.Burst}, nil
+}
+
+func (s RouteSpec) routePolicy() (RoutePolicy, error) {
+	window, err := parseSpecDuration(s.Window, RateWindow)
+	if err != nil {
+		return RoutePolicy{}, fmt.Errorf("route policy %s: window: %w", s.Name, err)
+	}
+	blockDuration, err := parseSpecDuration(s.BlockDuration, 5*time.Minute)
+	if err != nil {
+		return RoutePolicy{}, fmt.Errorf("route policy %s: block_duration: %w", s.Name, err)
+	}
+
+	algorithm, err := storage.ParseAlgorithm(s.Algorithm)
+	if err != nil {
+		return RoutePolicy{}, fmt.Errorf("route policy %s: %w", s.Name, err)
+	}
+
+	route := RoutePolicy{
+		Name:          s.Name,
+		Path:          s.Path,
+		RateLimit:     s.RateLimit,
+		Window:        window,
+		BlockDuration: blockDuration,
+		Algorithm:     algorithm,
+		Burst:         s.Burst,
+		Exempt:        s.Exempt,
+	}
+	for _, method := range s.Methods {
+		route.Methods = append(route.Methods, strings.ToUpper(method))
+	}
+	if err := route.Validate(); err != nil {
+		return RoutePolicy{}, err
+	}
+	return route, nil
+}
+
+// parseSpecDuration parses a duration of the policy file. Unlike the env
+// vars there is no fallback: a typo must reject the file.
+func parseSpecDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("negative duration %s", value)
+	}
+	return duration, nil
+}
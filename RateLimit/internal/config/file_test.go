@@ -0,0 +1,223 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+const testPolicyFile = `
+ip:
+  rate_limit: 20
+  block_duration: 1m
+token:
+  rate_limit: 200
+  algorithm: token_bucket
+  burst: 400
+tokens:
+  gold:
+    rate_limit: 5000
+    block_duration: 10s
+routes:
+  - name: health
+    path: /health
+    exempt: true
+  - name: orders_post
+    path: /api/v1/orders
+    methods: [post]
+    rate_limit: 2
+    window: 1m
+`
+
+func envConfig() *Config {
+	return &Config{
+		IPRateLimit:        10,
+		IPBlockDuration:    5 * time.Minute,
+		TokenRateLimit:     100,
+		TokenBlockDuration: 5 * time.Minute,
+		TokenConfigs: map[string]TokenConfig{
+			"abc123": {RateLimit: 50, BlockDuration: 10 * time.Minute},
+		},
+		Routes: []RoutePolicy{{Name: "env", Path: "/env", RateLimit: 1}},
+	}
+}
+
+func TestPolicyFile(t *testing.T) {
+	t.Run("YAML policies", func(t *testing.T) {
+		file, err := ParsePolicyFile([]byte(testPolicyFile))
+		if err != nil {
+			t.Fatalf("Parse should not fail: %v", err)
+		}
+		cfg, err := envConfig().WithPolicies(file)
+		if err != nil {
+			t.Fatalf("Policies should be valid: %v", err)
+		}
+
+		if cfg.IPRateLimit != 20 || cfg.IPBlockDuration != time.Minute {
+			t.Errorf("Unexpected IP limit: %d %v", cfg.IPRateLimit, cfg.IPBlockDuration)
+		}
+		if cfg.TokenRateLimit != 200 || cfg.TokenAlgorithm != storage.AlgorithmTokenBucket || cfg.TokenBurst != 400 {
+			t.Errorf("Unexpected token limit: %d %s %d", cfg.TokenRateLimit, cfg.TokenAlgorithm, cfg.TokenBurst)
+		}
+
+		if gold, ok := cfg.GetTokenConfig("gold"); !ok || gold.RateLimit != 5000 || gold.BlockDuration != 10*time.Second {
+			t.Errorf("Unexpected gold token config: %+v", gold)
+		}
+		// Tokens from the environment are kept
+		if _, ok := cfg.GetTokenConfig("abc123"); !ok {
+			t.Error("Expected the abc123 token from the environment")
+		}
+
+		// Routes from the file replace the ones from the environment
+		if len(cfg.Routes) != 2 || cfg.Routes[0].Name != "health" || !cfg.Routes[0].Exempt {
+			t.Fatalf("Unexpected routes: %+v", cfg.Routes)
+		}
+		if orders := cfg.Routes[1]; orders.Methods[0] != "POST" || orders.Window != time.Minute || orders.BlockDuration != 5*time.Minute {
+			t.Errorf("Unexpected orders route: %+v", orders)
+		}
+	})
+
+	t.Run("JSON policies", func(t *testing.T) {
+		file, err := ParsePolicyFile([]byte(`{"tokens": {"gold": {"rate_limit": 5000}}}`))
+		if err != nil {
+			t.Fatalf("Parse should not fail: %v", err)
+		}
+		cfg, err := envConfig().WithPolicies(file)
+		if err != nil {
+			t.Fatalf("Policies should be valid: %v", err)
+		}
+		if gold, _ := cfg.GetTokenConfig("gold"); gold.RateLimit != 5000 {
+			t.Errorf("Expected gold rate limit 5000, got %d", gold.RateLimit)
+		}
+		// Sections missing from the file keep the environment values
+		if cfg.IPRateLimit != 10 || len(cfg.Routes) != 1 {
+			t.Errorf("Expected the environment IP limit and routes, got %d %+v", cfg.IPRateLimit, cfg.Routes)
+		}
+	})
+
+	t.Run("Removed policies are gone", func(t *testing.T) {
+		file, _ := ParsePolicyFile([]byte(testPolicyFile))
+		cfg, _ := envConfig().WithPolicies(file)
+
+		// Applying a new file to the reloaded configuration starts from the environment again
+		file, _ = ParsePolicyFile([]byte(`routes: []`))
+		cfg, err := cfg.WithPolicies(file)
+		if err != nil {
+			t.Fatalf("Policies should be valid: %v", err)
+		}
+		if _, ok := cfg.GetTokenConfig("gold"); ok {
+			t.Error("Expected the gold token to be removed")
+		}
+		if cfg.IPRateLimit != 10 || len(cfg.Routes) != 0 {
+			t.Errorf("Expected the environment IP limit and no routes, got %d %+v", cfg.IPRateLimit, cfg.Routes)
+		}
+	})
+
+	t.Run("Invalid policy files", func(t *testing.T) {
+		testCases := map[string]string{
+			"Unknown field":     "ip:\n  rate: 10\n",
+			"Invalid duration":  "ip:\n  rate_limit: 10\n  block_duration: 5 minutes\n",
+			"Missing limit":     "tokens:\n  gold:\n    burst: 10\n",
+			"Unknown algorithm": "token:\n  rate_limit: 10\n  algorithm: leaky_bucket\n",
+			"Invalid route":     "routes:\n  - name: bad\n    path: api\n    rate_limit: 1\n",
+			"Duplicate route":   "routes:\n  - {name: a, path: /a, rate_limit: 1}\n  - {name: a, path: /b, rate_limit: 1}\n",
+			"Not YAML":          "ip: [",
+		}
+
+		for name, content := range testCases {
+			t.Run(name, func(t *testing.T) {
+				file, err := ParsePolicyFile([]byte(content))
+				if err == nil {
+					_, err = envConfig().WithPolicies(file)
+				}
+				if err == nil {
+					t.Fatal("Expected the policy file to be rejected")
+				}
+			})
+		}
+	})
+}
+
+func TestLoadWithPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte(testPolicyFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POLICY_FILE", path)
+	t.Setenv("IP_RATE_LIMIT", "3")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load should not fail: %v", err)
+	}
+	if cfg.IPRateLimit != 20 {
+		t.Errorf("Expected the file to override the IP limit, got %d", cfg.IPRateLimit)
+	}
+
+	if err := os.WriteFile(path, []byte("ip: ["), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Fatal("Load should fail with an invalid policy file")
+	}
+}
+
+func TestWatchPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte("ip:\n  rate_limit: 20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := envConfig()
+	cfg.PolicyFile = path
+	cfg.PolicyReloadInterval = 10 * time.Millisecond
+	cfg, err := cfg.LoadPolicyFile(path)
+	if err != nil {
+		t.Fatalf("Load should not fail: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan *Config, 10)
+	go cfg.WatchPolicyFile(ctx, func(c *Config) { reloaded <- c })
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("ip:\n  rate_limit: 30\n")
+	select {
+	case c := <-reloaded:
+		if c.IPRateLimit != 30 {
+			t.Errorf("Expected the reloaded IP limit 30, got %d", c.IPRateLimit)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the change to be applied")
+	}
+
+	// A bad edit is rejected and nothing is applied
+	write("ip:\n  rate_limit: -1\n")
+	select {
+	case c := <-reloaded:
+		t.Fatalf("Expected the invalid file to be rejected, got IP limit %d", c.IPRateLimit)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Fixing the file applies it again
+	write("ip:\n  rate_limit: 40\n")
+	select {
+	case c := <-reloaded:
+		if c.IPRateLimit != 40 {
+			t.Errorf("Expected the reloaded IP limit 40, got %d", c.IPRateLimit)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the fixed file to be applied")
+	}
+}
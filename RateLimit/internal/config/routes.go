@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// RoutePolicy holds the limit of a route. It applies to each client (its
+// token, or its IP without one) on top of the IP and token limits.
+type RoutePolicy struct {
+	Name          string            // identifies the policy in the storage keys
+	Path          string            // path prefix, or a pattern when it contains '*'
+	Methods       []string          // HTTP methods matched (any when empty)
+	RateLimit     int               // requests per window for each client
+	Window        time.Duration     // window of the limit (RateWindow when zero)
+	BlockDuration time.Duration     // block duration when the limit is exceeded
+	Algorithm     storage.Algorithm // algorithm for this route
+	Burst         int               // token bucket capacity for this route
+	Exempt        bool              // requests to this route are not rate limited at all
+}
+
+// Matches tells whether the policy covers a request. A path without '*' is a
+// prefix that matches whole segments: "/api/v1/orders" covers "/api/v1/orders"
+// and "/api/v1/orders/42" but not "/api/v1/ordersx". With '*' the path is a
+// pattern where '*' matches a single segment, as in "/api/v1/users/*/orders".
+func (p RoutePolicy) Matches(method, requestPath string) bool {
+	if len(p.Methods) > 0 {
+		found := false
+		for _, m := range p.Methods {
+			if strings.EqualFold(m, method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if strings.Contains(p.Path, "*") {
+		matched, err := path.Match(p.Path, requestPath)
+		return err == nil && matched
+	}
+
+	prefix := strings.TrimSuffix(p.Path, "/")
+	return prefix == "" || requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+// Limit returns the storage limit for this route
+func (p RoutePolicy) Limit() storage.Limit {
+	window := p.Window
+	if window <= 0 {
+		window = RateWindow
+	}
+	return storage.Limit{
+		Algorithm:     p.Algorithm,
+		Rate:          p.RateLimit,
+		Window:        window,
+		Burst:         p.Burst,
+		BlockDuration: p.BlockDuration,
+	}
+}
+
+// Validate checks that the policy can be applied
+func (p RoutePolicy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("route policy without a name")
+	}
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("route policy %s: path must start with /, got %q", p.Name, p.Path)
+	}
+	if _, err := path.Match(p.Path, ""); err != nil {
+		return fmt.Errorf("route policy %s: invalid path pattern %q: %w", p.Name, p.Path, err)
+	}
+	if !p.Exempt && p.RateLimit <= 0 {
+		return fmt.Errorf("route policy %s: rate limit must be positive", p.Name)
+	}
+	if p.Burst < 0 {
+		return fmt.Errorf("route policy %s: burst must not be negative", p.Name)
+	}
+	// Zero selects RateWindow; the Redis scripts count in microseconds
+	if p.Window != 0 && p.Window < time.Microsecond {
+		return fmt.Errorf("route policy %s: window must be at least 1µs, got %v", p.Name, p.Window)
+	}
+	if _, err := storage.ParseAlgorithm(string(p.Algorithm)); err != nil {
+		return fmt.Errorf("route policy %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// MatchRoute returns the first route policy, in configuration order, that
+// covers the request
+func (c *Config) MatchRoute(method, requestPath string) (RoutePolicy, bool) {
+	for _, route := range c.Routes {
+		if route.Matches(method, requestPath) {
+			return route, true
+		}
+	}
+	return RoutePolicy{}, false
+}
+
+// loadRoutePolicies reads the policies listed in ROUTE_POLICIES, in order.
+// Format: ROUTE_POLICIES=orders_post,health and, for each name,
+// ROUTE_<NAME>_PATH, ROUTE_<NAME>_METHODS, ROUTE_<NAME>_RATE_LIMIT,
+// ROUTE_<NAME>_WINDOW, ROUTE_<NAME>_BLOCK_DURATION, ROUTE_<NAME>_ALGORITHM,
+// ROUTE_<NAME>_BURST and ROUTE_<NAME>_EXEMPT
+func (c *Config) loadRoutePolicies() error {
+	for _, name := range strings.Split(getEnvString("ROUTE_POLICIES", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "ROUTE_" + strings.ToUpper(name) + "_"
+
+		algorithm, err := getEnvAlgorithm(prefix+"ALGORITHM", "")
+		if err != nil {
+			return err
+		}
+
+		var methods []string
+		for _, method := range strings.Split(getEnvString(prefix+"METHODS", ""), ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+				methods = append(methods, method)
+			}
+		}
+
+		route := RoutePolicy{
+			Name:          name,
+			Path:          getEnvString(prefix+"PATH", ""),
+			Methods:       methods,
+			RateLimit:     getEnvInt(prefix+"RATE_LIMIT", 0),
+			Window:        getEnvDuration(prefix+"WINDOW", "1s"),
+			BlockDuration: getEnvDuration(prefix+"BLOCK_DURATION", "5m"),
+			Algorithm:     algorithm,
+			Burst:         getEnvInt(prefix+"BURST", 0),
+			Exempt:        getEnvString(prefix+"EXEMPT", "false") == "true",
+		}
+		if err := route.Validate(); err != nil {
+			return err
+		}
+		c.Routes = append(c.Routes, route)
+	}
+
+	return nil
+}
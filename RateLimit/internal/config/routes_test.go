@@ -0,0 +1,114 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+func TestRoutePolicyMatches(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   RoutePolicy
+		method   string
+		path     string
+		expected bool
+	}{
+		{"Exact prefix", RoutePolicy{Path: "/api/v1/orders"}, "GET", "/api/v1/orders", true},
+		{"Sub path", RoutePolicy{Path: "/api/v1/orders"}, "GET", "/api/v1/orders/42", true},
+		{"Partial segment", RoutePolicy{Path: "/api/v1/orders"}, "GET", "/api/v1/ordersx", false},
+		{"Trailing slash", RoutePolicy{Path: "/api/v1/"}, "GET", "/api/v1/users", true},
+		{"Root", RoutePolicy{Path: "/"}, "GET", "/anything", true},
+		{"Method matches", RoutePolicy{Path: "/api/v1/orders", Methods: []string{"POST"}}, "post", "/api/v1/orders", true},
+		{"Method differs", RoutePolicy{Path: "/api/v1/orders", Methods: []string{"POST"}}, "GET", "/api/v1/orders", false},
+		{"Pattern", RoutePolicy{Path: "/api/v1/users/*/orders"}, "GET", "/api/v1/users/7/orders", true},
+		{"Pattern is one segment", RoutePolicy{Path: "/api/v1/users/*/orders"}, "GET", "/api/v1/users/7/8/orders", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.Matches(tc.method, tc.path); got != tc.expected {
+				t.Errorf("Expected %v for %s %s, got %v", tc.expected, tc.method, tc.path, got)
+			}
+		})
+	}
+}
+
+func TestMatchRouteOrder(t *testing.T) {
+	cfg := &Config{Routes: []RoutePolicy{
+		{Name: "orders_post", Path: "/api/v1/orders", Methods: []string{"POST"}, RateLimit: 2},
+		{Name: "api", Path: "/api", RateLimit: 50},
+	}}
+
+	route, ok := cfg.MatchRoute("POST", "/api/v1/orders")
+	if !ok || route.Name != "orders_post" {
+		t.Errorf("Expected the orders_post policy, got %+v", route)
+	}
+
+	route, ok = cfg.MatchRoute("GET", "/api/v1/orders")
+	if !ok || route.Name != "api" {
+		t.Errorf("Expected the api policy, got %+v", route)
+	}
+
+	if _, ok := cfg.MatchRoute("GET", "/health"); ok {
+		t.Error("Expected no policy for /health")
+	}
+}
+
+func TestLoadRoutePolicies(t *testing.T) {
+	t.Run("Policies via env vars", func(t *testing.T) {
+		t.Setenv("ROUTE_POLICIES", "orders_post, health")
+		t.Setenv("ROUTE_ORDERS_POST_PATH", "/api/v1/orders")
+		t.Setenv("ROUTE_ORDERS_POST_METHODS", "post")
+		t.Setenv("ROUTE_ORDERS_POST_RATE_LIMIT", "5")
+		t.Setenv("ROUTE_ORDERS_POST_WINDOW", "1m")
+		t.Setenv("ROUTE_ORDERS_POST_BLOCK_DURATION", "30s")
+		t.Setenv("ROUTE_ORDERS_POST_ALGORITHM", "fixed_window")
+		t.Setenv("ROUTE_HEALTH_PATH", "/health")
+		t.Setenv("ROUTE_HEALTH_EXEMPT", "true")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load should not fail: %v", err)
+		}
+		if len(cfg.Routes) != 2 {
+			t.Fatalf("Expected 2 route policies, got %d", len(cfg.Routes))
+		}
+
+		orders := cfg.Routes[0]
+		if orders.Name != "orders_post" || len(orders.Methods) != 1 || orders.Methods[0] != "POST" {
+			t.Errorf("Unexpected orders policy: %+v", orders)
+		}
+		limit := orders.Limit()
+		if limit.Rate != 5 || limit.Window != time.Minute || limit.BlockDuration != 30*time.Second || limit.Algorithm != storage.AlgorithmFixedWindow {
+			t.Errorf("Unexpected orders limit: %+v", limit)
+		}
+
+		if health := cfg.Routes[1]; !health.Exempt || health.Path != "/health" {
+			t.Errorf("Unexpected health policy: %+v", health)
+		}
+	})
+
+	t.Run("Invalid policies", func(t *testing.T) {
+		testCases := map[string]map[string]string{
+			"Missing path":      {"ROUTE_BAD_RATE_LIMIT": "5"},
+			"Missing limit":     {"ROUTE_BAD_PATH": "/api"},
+			"Unknown algorithm": {"ROUTE_BAD_PATH": "/api", "ROUTE_BAD_RATE_LIMIT": "5", "ROUTE_BAD_ALGORITHM": "leaky_bucket"},
+			"Bad pattern":       {"ROUTE_BAD_PATH": "/api/[", "ROUTE_BAD_RATE_LIMIT": "5"},
+		}
+
+		for name, env := range testCases {
+			t.Run(name, func(t *testing.T) {
+				t.Setenv("ROUTE_POLICIES", "bad")
+				for key, value := range env {
+					t.Setenv(key, value)
+				}
+
+				if _, err := Load(); err == nil {
+					t.Fatal("Load should fail")
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/ratelimiter"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// DefaultToken is the token label of the tokens without their own limit
+const DefaultToken = "default"
+
+// Metrics holds the Prometheus metrics of the rate limiter
+type Metrics struct {
+	registry        *prometheus.Registry
+	allowed         *prometheus.CounterVec
+	denied          *prometheus.CounterVec
+	tokenDenied     *prometheus.CounterVec
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+	blockedKeys     *blockedKeysCollector
+}
+
+// New creates the metrics in their own registry, along with the Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		allowed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimiter_requests_allowed_total",
+			Help: "Requests allowed by the rate limiter, by dimension (ip, token or route).",
+		}, []string{"dimension"}),
+		denied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimiter_requests_denied_total",
+			Help: "Requests denied by the rate limiter, by dimension (ip, token or route).",
+		}, []string{"dimension"}),
+		tokenDenied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimiter_token_requests_denied_total",
+			Help: "Requests with a token denied by the token limit or a route policy, by token fingerprint (\"default\" for tokens without their own limit).",
+		}, []string{"token"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ratelimiter_storage_duration_seconds",
+			Help:    "Latency of the storage operations, by backend and operation.",
+			Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"backend", "operation"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimiter_storage_errors_total",
+			Help: "Failed storage operations, by backend and operation.",
+		}, []string{"backend", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.allowed, m.denied, m.tokenDenied, m.storageDuration, m.storageErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Observe implements ratelimiter.Observer
+func (m *Metrics) Observe(decision ratelimiter.Decision) {
+	dimension := string(decision.Dimension)
+	if decision.Allowed {
+		m.allowed.WithLabelValues(dimension).Inc()
+		return
+	}
+	m.denied.WithLabelValues(dimension).Inc()
+
+	// Route policies limit a token under its own key, so their denials count
+	// for the token too
+	if decision.Dimension == ratelimiter.DimensionToken || decision.Dimension == ratelimiter.DimensionRoute && decision.Token != "" {
+		// Only tokens with their own limit get a series, so clients sending
+		// random tokens can't blow up the number of series
+		token := DefaultToken
+		if decision.CustomToken {
+			token = Fingerprint(decision.Token)
+		}
+		m.tokenDenied.WithLabelValues(token).Inc()
+	}
+}
+
+// Fingerprint identifies a token in the metrics without exposing it: the
+// first 8 hex digits of its SHA-256
+func Fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
+
+// RegisterRateLimiter observes the decisions of the rate limiter and
+// reports its blocked keys, as counted by CountBlockedKeys
+func (m *Metrics) RegisterRateLimiter(rateLimiter *ratelimiter.RateLimiter) {
+	rateLimiter.SetObserver(m)
+	m.blockedKeys = &blockedKeysCollector{rateLimiter: rateLimiter}
+	m.registry.MustRegister(m.blockedKeys)
+}
+
+// CountBlockedKeys counts the blocked keys of the registered rate limiter
+// right away and then every BlockedKeysInterval, until ctx is done
+func (m *Metrics) CountBlockedKeys(ctx context.Context) {
+	ticker := time.NewTicker(BlockedKeysInterval)
+	defer ticker.Stop()
+
+	for {
+		m.blockedKeys.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RegisterCircuitBreaker reports whether the storage circuit breaker is open
+func (m *Metrics) RegisterCircuitBreaker(breaker *storage.CircuitBreaker) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ratelimiter_storage_circuit_open",
+		Help: "Whether the storage circuit breaker is open (1) or closed (0).",
+	}, func() float64 {
+		if breaker.Open() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+// blockedKeysDesc describes the number of blocked keys
+var blockedKeysDesc = prometheus.NewDesc(
+	"ratelimiter_blocked_keys",
+	"Keys currently blocked, by dimension (ip, token or route).",
+	[]string{"dimension"}, nil,
+)
+
+// BlockedKeysInterval is how often the blocked keys are counted. Listing them
+// scans every key in Redis, so it is not done on every scrape.
+const BlockedKeysInterval = 15 * time.Second
+
+// blockedKeysCollector reports the blocked keys counted by the last listing,
+// so the number is right even when several instances share Redis
+type blockedKeysCollector struct {
+	rateLimiter *ratelimiter.RateLimiter
+
+	mu     sync.Mutex
+	counts map[ratelimiter.Dimension]int // nil until the first listing
+}
+
+// refresh lists the blocked keys and counts them by dimension. On failure
+// the previous counts are kept.
+func (c *blockedKeysCollector) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	keys, err := c.rateLimiter.ListBlocked(ctx)
+	if err != nil {
+		log.Printf("Failed to list blocked keys for metrics: %v", err)
+		return
+	}
+
+	counts := map[ratelimiter.Dimension]int{
+		ratelimiter.DimensionIP:    0,
+		ratelimiter.DimensionToken: 0,
+		ratelimiter.DimensionRoute: 0,
+	}
+	for _, key := range keys {
+		dimension, _, _ := strings.Cut(key.Key, ":")
+		if _, ok := counts[ratelimiter.Dimension(dimension)]; ok {
+			counts[ratelimiter.Dimension(dimension)]++
+		}
+	}
+
+	c.mu.Lock()
+	c.counts = counts
+	c.mu.Unlock()
+}
+
+func (c *blockedKeysCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- blockedKeysDesc
+}
+
+func (c *blockedKeysCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for dimension, count := range c.counts {
+		ch <- prometheus.MustNewConstMetric(blockedKeysDesc, prometheus.GaugeValue, float64(count), string(dimension))
+	}
+}
@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/ratelimiter"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+func newTestRateLimiter(t *testing.T, m *Metrics, store storage.Storage) *ratelimiter.RateLimiter {
+	t.Helper()
+
+	cfg := &config.Config{
+		IPRateLimit:        2,
+		IPBlockDuration:    time.Minute,
+		TokenRateLimit:     1,
+		TokenBlockDuration: time.Minute,
+		TokenConfigs: map[string]config.TokenConfig{
+			"premium": {RateLimit: 1, BlockDuration: time.Minute},
+		},
+		Routes: []config.RoutePolicy{
+			{Name: "orders_post", Path: "/api/v1/orders", Methods: []string{"POST"}, RateLimit: 1, Window: time.Second, BlockDuration: time.Minute},
+		},
+	}
+
+	t.Cleanup(func() { store.Close() })
+	rateLimiter := ratelimiter.New(store, cfg)
+	m.RegisterRateLimiter(rateLimiter)
+	return rateLimiter
+}
+
+func TestDecisionMetrics(t *testing.T) {
+	m := New()
+	rateLimiter := newTestRateLimiter(t, m, storage.NewMemoryStorage())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		rateLimiter.Check(ctx, "10.0.0.1", "")
+	}
+	for i := 0; i < 3; i++ {
+		rateLimiter.Check(ctx, "10.0.0.1", "premium")
+		rateLimiter.Check(ctx, "10.0.0.1", "random-token")
+	}
+	route, _ := rateLimiter.Route("POST", "/api/v1/orders")
+	for i := 0; i < 2; i++ {
+		rateLimiter.CheckRoute(ctx, route, "10.0.0.2", "")
+	}
+
+	testCases := []struct {
+		name     string
+		value    float64
+		expected float64
+	}{
+		{"Allowed IP", testutil.ToFloat64(m.allowed.WithLabelValues("ip")), 2},
+		{"Denied IP", testutil.ToFloat64(m.denied.WithLabelValues("ip")), 1},
+		{"Allowed token", testutil.ToFloat64(m.allowed.WithLabelValues("token")), 2},
+		{"Denied token", testutil.ToFloat64(m.denied.WithLabelValues("token")), 4},
+		{"Allowed route", testutil.ToFloat64(m.allowed.WithLabelValues("route")), 1},
+		{"Denied route", testutil.ToFloat64(m.denied.WithLabelValues("route")), 1},
+		{"Denied premium token", testutil.ToFloat64(m.tokenDenied.WithLabelValues(Fingerprint("premium"))), 2},
+		{"Denied default tokens", testutil.ToFloat64(m.tokenDenied.WithLabelValues(DefaultToken)), 2},
+	}
+	for _, tc := range testCases {
+		if tc.value != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, tc.value)
+		}
+	}
+
+	// Tokens without their own limit never get a series of their own
+	if count := testutil.CollectAndCount(m.tokenDenied); count != 2 {
+		t.Errorf("Expected 2 token series, got %d", count)
+	}
+
+	// The blocked keys are the IP, both tokens and the route
+	m.blockedKeys.refresh(ctx)
+	expected := `
+# HELP ratelimiter_blocked_keys Keys currently blocked, by dimension (ip, token or route).
+# TYPE ratelimiter_blocked_keys gauge
+ratelimiter_blocked_keys{dimension="ip"} 1
+ratelimiter_blocked_keys{dimension="route"} 1
+ratelimiter_blocked_keys{dimension="token"} 2
+`
+	if err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "ratelimiter_blocked_keys"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRouteTokenMetrics(t *testing.T) {
+	m := New()
+	rateLimiter := newTestRateLimiter(t, m, storage.NewMemoryStorage())
+	ctx := context.Background()
+
+	route, _ := rateLimiter.Route("POST", "/api/v1/orders")
+	for i := 0; i < 3; i++ {
+		rateLimiter.CheckRoute(ctx, route, "10.0.0.1", "premium")
+		rateLimiter.CheckRoute(ctx, route, "10.0.0.1", "random-token")
+		rateLimiter.CheckRoute(ctx, route, "10.0.0.2", "")
+	}
+
+	if got := testutil.ToFloat64(m.denied.WithLabelValues("route")); got != 6 {
+		t.Errorf("Expected 6 route denials, got %v", got)
+	}
+	// Route denials of a token count for the token, but not those of an IP
+	if got := testutil.ToFloat64(m.tokenDenied.WithLabelValues(Fingerprint("premium"))); got != 2 {
+		t.Errorf("Expected 2 denials of the premium token, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.tokenDenied.WithLabelValues(DefaultToken)); got != 2 {
+		t.Errorf("Expected 2 denials of default tokens, got %v", got)
+	}
+	if count := testutil.CollectAndCount(m.tokenDenied); count != 2 {
+		t.Errorf("Expected 2 token series, got %d", count)
+	}
+}
+
+// downStorage is a storage whose backend is unreachable
+type downStorage struct {
+	*storage.MemoryStorage
+}
+
+func (d downStorage) CheckLimit(ctx context.Context, key string, limit storage.Limit) (*storage.RateLimitResult, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (d downStorage) Health(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestStorageMetrics(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	memory := m.Instrument(storage.NewMemoryStorage(), "memory")
+	redis := m.Instrument(downStorage{storage.NewMemoryStorage()}, "redis")
+	defer memory.Close()
+	defer redis.Close()
+
+	for i := 0; i < 3; i++ {
+		memory.CheckLimit(ctx, "ip:10.0.0.1", storage.Limit{Rate: 10, Window: time.Second})
+		redis.CheckLimit(ctx, "ip:10.0.0.1", storage.Limit{Rate: 10, Window: time.Second})
+	}
+	memory.Block(ctx, "ip:10.0.0.1", time.Minute)
+	redis.Health(ctx)
+
+	if count := testutil.CollectAndCount(m.storageDuration); count != 4 {
+		t.Errorf("Expected 4 latency histograms (memory check and block, redis check and health), got %d", count)
+	}
+	if got := testutil.ToFloat64(m.storageErrors.WithLabelValues("redis", "check")); got != 3 {
+		t.Errorf("Expected 3 redis check errors, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.storageErrors.WithLabelValues("redis", "health")); got != 1 {
+		t.Errorf("Expected 1 redis health error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.storageErrors.WithLabelValues("memory", "check")); got != 0 {
+		t.Errorf("Expected no memory errors, got %v", got)
+	}
+
+	breaker := storage.NewCircuitBreaker(redis, memory, 1, time.Hour)
+	m.RegisterCircuitBreaker(breaker)
+	breaker.CheckLimit(ctx, "ip:10.0.0.2", storage.Limit{Rate: 10, Window: time.Second})
+	expected := `
+# HELP ratelimiter_storage_circuit_open Whether the storage circuit breaker is open (1) or closed (0).
+# TYPE ratelimiter_storage_circuit_open gauge
+ratelimiter_storage_circuit_open 1
+`
+	if err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "ratelimiter_storage_circuit_open"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	m := New()
+	rateLimiter := newTestRateLimiter(t, m, m.Instrument(storage.NewMemoryStorage(), "memory"))
+	rateLimiter.Check(context.Background(), "10.0.0.1", "")
+	m.blockedKeys.refresh(context.Background())
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+
+	for _, series := range []string{
+		`ratelimiter_requests_allowed_total{dimension="ip"} 1`,
+		`ratelimiter_blocked_keys{dimension="ip"} 0`,
+		`ratelimiter_storage_duration_seconds_count{backend="memory",operation="check"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Errorf("Expected %s in the metrics", series)
+		}
+	}
+}
+
+func TestBlockedKeysMetrics(t *testing.T) {
+	m := New()
+	rateLimiter := newTestRateLimiter(t, m, m.Instrument(storage.NewMemoryStorage(), "memory"))
+	rateLimiter.Block(context.Background(), "ip:10.0.0.1", time.Minute)
+
+	// Scrapes report the last count and never list the keys themselves
+	for i := 0; i < 3; i++ {
+		if count := testutil.CollectAndCount(m.blockedKeys); count != 0 {
+			t.Fatalf("Expected no blocked keys series before the first count, got %d", count)
+		}
+	}
+	if count := testutil.CollectAndCount(m.storageDuration); count != 1 {
+		t.Fatalf("Expected only the block to reach the storage, got %d operations", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.CountBlockedKeys(ctx)
+		close(done)
+	}()
+
+	expected := `
+# HELP ratelimiter_blocked_keys Keys currently blocked, by dimension (ip, token or route).
+# TYPE ratelimiter_blocked_keys gauge
+ratelimiter_blocked_keys{dimension="ip"} 1
+ratelimiter_blocked_keys{dimension="route"} 0
+ratelimiter_blocked_keys{dimension="token"} 0
+`
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "ratelimiter_blocked_keys") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the blocked keys to be counted right away")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestFingerprint(t *testing.T) {
+	if got := Fingerprint("abc123"); got != "6ca13d52" {
+		t.Errorf("Expected fingerprint 6ca13d52, got %s", got)
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// instrumentedStorage records the latency and the errors of a storage backend
+type instrumentedStorage struct {
+	storage.Storage
+	backend string
+	metrics *Metrics
+}
+
+// Instrument wraps a storage backend, such as "redis" or "memory", to record
+// the latency and the errors of its operations
+func (m *Metrics) Instrument(s storage.Storage, backend string) storage.Storage {
+	return &instrumentedStorage{Storage: s, backend: backend, metrics: m}
+}
+
+// observe records an operation that started at start
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	s.metrics.storageDuration.WithLabelValues(s.backend, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.metrics.storageErrors.WithLabelValues(s.backend, operation).Inc()
+	}
+}
+
+// CheckRateLimit implements the Storage interface
+func (s *instrumentedStorage) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (*storage.RateLimitResult, error) {
+	return s.CheckLimit(ctx, key, storage.Limit{Rate: limit, Window: window, BlockDuration: blockDuration})
+}
+
+// CheckLimit implements the Storage interface
+func (s *instrumentedStorage) CheckLimit(ctx context.Context, key string, limit storage.Limit) (*storage.RateLimitResult, error) {
+	start := time.Now()
+	result, err := s.Storage.CheckLimit(ctx, key, limit)
+	s.observe("check", start, err)
+	return result, err
+}
+
+// Inspect implements the Storage interface
+func (s *instrumentedStorage) Inspect(ctx context.Context, key string, limit storage.Limit) (*storage.KeyState, error) {
+	start := time.Now()
+	state, err := s.Storage.Inspect(ctx, key, limit)
+	s.observe("inspect", start, err)
+	return state, err
+}
+
+// Reset implements the Storage interface
+func (s *instrumentedStorage) Reset(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.Storage.Reset(ctx, key)
+	s.observe("reset", start, err)
+	return err
+}
+
+// IsBlocked implements the Storage interface
+func (s *instrumentedStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	start := time.Now()
+	blocked, ttl, err := s.Storage.IsBlocked(ctx, key)
+	s.observe("is_blocked", start, err)
+	return blocked, ttl, err
+}
+
+// Block implements the Storage interface
+func (s *instrumentedStorage) Block(ctx context.Context, key string, duration time.Duration) error {
+	start := time.Now()
+	err := s.Storage.Block(ctx, key, duration)
+	s.observe("block", start, err)
+	return err
+}
+
+// Unblock implements the Storage interface
+func (s *instrumentedStorage) Unblock(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.Storage.Unblock(ctx, key)
+	s.observe("unblock", start, err)
+	return err
+}
+
+// ListBlocked implements the Storage interface
+func (s *instrumentedStorage) ListBlocked(ctx context.Context) ([]storage.BlockedKey, error) {
+	start := time.Now()
+	keys, err := s.Storage.ListBlocked(ctx)
+	s.observe("list_blocked", start, err)
+	return keys, err
+}
+
+// Health implements the Storage interface
+func (s *instrumentedStorage) Health(ctx context.Context) error {
+	start := time.Now()
+	err := s.Storage.Health(ctx)
+	s.observe("health", start, err)
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
 	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/ratelimiter"
 	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
 )
@@ -40,6 +41,13 @@ func (rlm *RateLimiterMiddleware) Handler(next http.Handler) http.Handler {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
+		// Exempt routes skip rate limiting entirely
+		route, hasRoute := rlm.rateLimiter.Route(r.Method, r.URL.Path)
+		if hasRoute && route.Exempt {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Extract client IP
 		clientIP := rlm.getClientIP(r)
 
@@ -48,9 +56,22 @@ func (rlm *RateLimiterMiddleware) Handler(next http.Handler) http.Handler {
 
 		// Check rate limit
 		result, err := rlm.rateLimiter.Check(ctx, clientIP, token)
+		if err == nil && result.Allowed && hasRoute {
+			// The route policy applies on top of the IP/token limit
+			result, err = rlm.rateLimiter.CheckRoute(ctx, route, clientIP, token)
+		}
 		if err != nil {
-			log.Printf("Rate limiter error: %v", err)
-			rlm.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", err.Error())
+			if rlm.rateLimiter.Config().FailurePolicy == config.FailOpen {
+				log.Printf("Rate limiter error, allowing request (fail open): %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Fail closed. With the local policy the fallback storage answers
+			// while the primary is down, so an error here is unexpected too.
+			// The error is only logged, as it may describe the infrastructure.
+			log.Printf("Rate limiter error, rejecting request: %v", err)
+			w.Header().Set("Retry-After", formatRetryAfter(time.Second))
+			rlm.writeErrorResponse(w, http.StatusServiceUnavailable, "Rate limiter unavailable", "Storage unavailable")
 			return
 		}
 
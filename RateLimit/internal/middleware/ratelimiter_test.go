@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -275,6 +278,177 @@ func TestMiddlewareIPExtraction(t *testing.T) {
 	})
 }
 
+func TestMiddlewareRoutePolicies(t *testing.T) {
+	cfg := &config.Config{
+		IPRateLimit:        5,
+		IPBlockDuration:    time.Minute,
+		TokenRateLimit:     10,
+		TokenBlockDuration: time.Minute,
+		TokenConfigs:       make(map[string]config.TokenConfig),
+		Routes: []config.RoutePolicy{
+			{Name: "health", Path: "/health", Exempt: true},
+			{Name: "orders_post", Path: "/api/v1/orders", Methods: []string{"POST"}, RateLimit: 2, BlockDuration: time.Minute},
+			{Name: "users_get", Path: "/api/v1/users", Methods: []string{"GET"}, RateLimit: 4, BlockDuration: time.Minute},
+		},
+	}
+
+	storage := storage.NewMemoryStorage()
+	defer storage.Close()
+
+	rateLimiter := ratelimiter.New(storage, cfg)
+	middleware := NewRateLimiter(rateLimiter)
+
+	handler := &TestHandler{}
+	wrappedHandler := middleware.Handler(handler)
+
+	send := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":12345"
+		recorder := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("Stricter limit on matching route", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if recorder := send("POST", "/api/v1/orders", "10.0.0.1"); recorder.Code != http.StatusOK {
+				t.Fatalf("POST request %d should return 200, got %d", i+1, recorder.Code)
+			}
+		}
+
+		recorder := send("POST", "/api/v1/orders", "10.0.0.1")
+		if recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("3rd POST request should return 429, got %d", recorder.Code)
+		}
+		if limit := recorder.Header().Get("X-RateLimit-Limit"); limit != "2" {
+			t.Errorf("Expected the route limit in X-RateLimit-Limit, got %s", limit)
+		}
+
+		// Other routes keep only the IP limit
+		if recorder := send("GET", "/api/v1/orders", "10.0.0.1"); recorder.Code != http.StatusOK {
+			t.Fatalf("GET request should return 200, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("Route limit per client", func(t *testing.T) {
+		if recorder := send("POST", "/api/v1/orders", "10.0.0.2"); recorder.Code != http.StatusOK {
+			t.Fatalf("Another client should not share the route limit, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("IP limit still applies", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			if recorder := send("GET", "/api/v1/users", "10.0.0.3"); recorder.Code != http.StatusOK {
+				t.Fatalf("GET request %d should return 200, got %d", i+1, recorder.Code)
+			}
+		}
+
+		// The 5th request passes the IP limit but not the route limit, and still counts for the IP
+		if recorder := send("GET", "/api/v1/users", "10.0.0.3"); recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("5th GET request should return 429, got %d", recorder.Code)
+		}
+
+		if recorder := send("GET", "/other", "10.0.0.3"); recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("IP over its limit should return 429, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("Exempt route", func(t *testing.T) {
+		before := handler.called
+		for i := 0; i < 20; i++ {
+			if recorder := send("GET", "/health", "10.0.0.4"); recorder.Code != http.StatusOK {
+				t.Fatalf("Exempt request %d should return 200, got %d", i+1, recorder.Code)
+			}
+		}
+		if handler.called != before+20 {
+			t.Fatalf("Handler should be called for every exempt request, got %d", handler.called-before)
+		}
+	})
+}
+
+// downStorage is a storage whose backend is unreachable
+type downStorage struct {
+	*storage.MemoryStorage
+}
+
+func (d downStorage) CheckLimit(ctx context.Context, key string, limit storage.Limit) (*storage.RateLimitResult, error) {
+	return nil, errors.New("dial tcp 127.0.0.1:6379: connection refused")
+}
+
+func (d downStorage) Health(ctx context.Context) error {
+	return errors.New("dial tcp 127.0.0.1:6379: connection refused")
+}
+
+func TestMiddlewareFailurePolicy(t *testing.T) {
+	newHandler := func(t *testing.T, policy config.FailurePolicy, store storage.Storage) (http.Handler, *TestHandler) {
+		cfg := &config.Config{
+			IPRateLimit:     2,
+			IPBlockDuration: time.Minute,
+			TokenConfigs:    make(map[string]config.TokenConfig),
+			FailurePolicy:   policy,
+		}
+		t.Cleanup(func() { store.Close() })
+		handler := &TestHandler{}
+		return NewRateLimiter(ratelimiter.New(store, cfg)).Handler(handler), handler
+	}
+	send := func(h http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "192.168.1.10:12345"
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("Fail open", func(t *testing.T) {
+		wrappedHandler, handler := newHandler(t, config.FailOpen, downStorage{storage.NewMemoryStorage()})
+		for i := 0; i < 5; i++ {
+			if recorder := send(wrappedHandler); recorder.Code != http.StatusOK {
+				t.Fatalf("Request %d should return 200, got %d", i+1, recorder.Code)
+			}
+		}
+		if handler.called != 5 {
+			t.Fatalf("Handler should be called 5 times, got %d", handler.called)
+		}
+	})
+
+	for _, policy := range []config.FailurePolicy{config.FailClosed, ""} {
+		t.Run("Fail closed "+string(policy), func(t *testing.T) {
+			wrappedHandler, handler := newHandler(t, policy, downStorage{storage.NewMemoryStorage()})
+			recorder := send(wrappedHandler)
+			if recorder.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected 503, got %d", recorder.Code)
+			}
+			if recorder.Header().Get("Retry-After") == "" {
+				t.Error("Expected a Retry-After header")
+			}
+			if body := recorder.Body.String(); strings.Contains(body, "6379") || strings.Contains(body, "connection refused") {
+				t.Errorf("Expected the storage error to stay out of the response, got %s", body)
+			}
+			if handler.called != 0 {
+				t.Error("Handler should not be called")
+			}
+		})
+	}
+
+	t.Run("Local fallback", func(t *testing.T) {
+		store := storage.NewCircuitBreaker(downStorage{storage.NewMemoryStorage()}, storage.NewMemoryStorage(), 1, time.Hour)
+		wrappedHandler, _ := newHandler(t, config.FailLocal, store)
+
+		// The in-memory fallback keeps enforcing the IP limit
+		for i := 0; i < 2; i++ {
+			if recorder := send(wrappedHandler); recorder.Code != http.StatusOK {
+				t.Fatalf("Request %d should return 200, got %d", i+1, recorder.Code)
+			}
+		}
+		if recorder := send(wrappedHandler); recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("3rd request should return 429, got %d", recorder.Code)
+		}
+		if !store.Open() {
+			t.Error("Expected the circuit breaker to be open")
+		}
+	})
+}
+
 func BenchmarkMiddleware(b *testing.B) {
 	cfg := &config.Config{
 		IPRateLimit:        1000000, // High limit for benchmark
@@ -0,0 +1,213 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+// Dimension identifies what a storage key limits
+type Dimension string
+
+const (
+	DimensionIP    Dimension = "ip"    // limit of an IP address without a token
+	DimensionToken Dimension = "token" // limit of an API token
+	DimensionRoute Dimension = "route" // route policy limit of a client
+)
+
+// ErrUnknownKey is returned for keys that were not built by this package
+var ErrUnknownKey = errors.New("unknown rate limit key")
+
+// RateLimiter decides which limit applies to a request and checks it against the storage
+type RateLimiter struct {
+	storage  storage.Storage
+	config   atomic.Pointer[config.Config]
+	observer Observer
+}
+
+// Decision is the outcome of a check, as reported to the Observer
+type Decision struct {
+	Dimension   Dimension
+	Token       string // token of the request, if any
+	CustomToken bool   // whether the token has its own limit
+	Allowed     bool
+}
+
+// Observer is notified of every check that reaches a decision
+type Observer interface {
+	Observe(decision Decision)
+}
+
+// KeyInfo describes the state of a storage key under its current limit
+type KeyInfo struct {
+	Key       string
+	Dimension Dimension
+	Limit     storage.Limit
+	storage.KeyState
+}
+
+// New creates a new rate limiter
+func New(store storage.Storage, cfg *config.Config) *RateLimiter {
+	rl := &RateLimiter{storage: store}
+	rl.config.Store(cfg)
+	return rl
+}
+
+// Config returns the configuration in use
+func (rl *RateLimiter) Config() *config.Config {
+	return rl.config.Load()
+}
+
+// SetConfig replaces the configuration. Requests already being checked finish
+// with the previous one.
+func (rl *RateLimiter) SetConfig(cfg *config.Config) {
+	rl.config.Store(cfg)
+}
+
+// SetObserver sets the observer of the decisions. It must be called before
+// the rate limiter serves requests.
+func (rl *RateLimiter) SetObserver(observer Observer) {
+	rl.observer = observer
+}
+
+// observe reports a decision to the observer
+func (rl *RateLimiter) observe(result *storage.RateLimitResult, err error, decision Decision) (*storage.RateLimitResult, error) {
+	if err == nil && rl.observer != nil {
+		decision.Allowed = result.Allowed
+		rl.observer.Observe(decision)
+	}
+	return result, err
+}
+
+// IPKey returns the storage key of an IP address
+func IPKey(ip string) string {
+	return string(DimensionIP) + ":" + ip
+}
+
+// TokenKey returns the storage key of an API token
+func TokenKey(token string) string {
+	return string(DimensionToken) + ":" + token
+}
+
+// RouteKey returns the storage key of a client under a route policy. The
+// client is the token, or the IP when there is no token.
+func RouteKey(route, ip, token string) string {
+	if token != "" {
+		return string(DimensionRoute) + ":" + route + ":" + TokenKey(token)
+	}
+	return string(DimensionRoute) + ":" + route + ":" + IPKey(ip)
+}
+
+// Check checks the request against the IP or token limit. A token takes
+// precedence: its limit replaces the IP limit, so a request with a token
+// never counts against its IP.
+func (rl *RateLimiter) Check(ctx context.Context, ip, token string) (*storage.RateLimitResult, error) {
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("invalid IP address: %q", ip)
+	}
+
+	cfg := rl.Config()
+	if token != "" {
+		tokenConfig, custom := cfg.GetTokenConfig(token)
+		result, err := rl.storage.CheckLimit(ctx, TokenKey(token), tokenConfig.Limit())
+		return rl.observe(result, err, Decision{Dimension: DimensionToken, Token: token, CustomToken: custom})
+	}
+	result, err := rl.storage.CheckLimit(ctx, IPKey(ip), cfg.IPLimit())
+	return rl.observe(result, err, Decision{Dimension: DimensionIP})
+}
+
+// Route returns the route policy that covers the request, if any
+func (rl *RateLimiter) Route(method, path string) (config.RoutePolicy, bool) {
+	return rl.Config().MatchRoute(method, path)
+}
+
+// CheckRoute checks the request against the route policy
+func (rl *RateLimiter) CheckRoute(ctx context.Context, route config.RoutePolicy, ip, token string) (*storage.RateLimitResult, error) {
+	result, err := rl.storage.CheckLimit(ctx, RouteKey(route.Name, ip, token), route.Limit())
+	_, custom := rl.Config().GetTokenConfig(token)
+	return rl.observe(result, err, Decision{Dimension: DimensionRoute, Token: token, CustomToken: custom})
+}
+
+// Reset clears the counters and the block of a key
+func (rl *RateLimiter) Reset(ctx context.Context, key string) error {
+	if _, _, err := rl.limitOf(key); err != nil {
+		return err
+	}
+	return rl.storage.Reset(ctx, key)
+}
+
+// Block blocks a key for the duration, as if it had exceeded its limit
+func (rl *RateLimiter) Block(ctx context.Context, key string, duration time.Duration) error {
+	if _, _, err := rl.limitOf(key); err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return fmt.Errorf("block duration must be positive, got %v", duration)
+	}
+	return rl.storage.Block(ctx, key, duration)
+}
+
+// Unblock removes the block of a key, keeping its counters
+func (rl *RateLimiter) Unblock(ctx context.Context, key string) error {
+	if _, _, err := rl.limitOf(key); err != nil {
+		return err
+	}
+	return rl.storage.Unblock(ctx, key)
+}
+
+// ListBlocked returns every blocked key
+func (rl *RateLimiter) ListBlocked(ctx context.Context) ([]storage.BlockedKey, error) {
+	return rl.storage.ListBlocked(ctx)
+}
+
+// Inspect reports the state of a key under the limit that currently applies to it
+func (rl *RateLimiter) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	dimension, limit, err := rl.limitOf(key)
+	if err != nil {
+		return nil, err
+	}
+	state, err := rl.storage.Inspect(ctx, key, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyInfo{Key: key, Dimension: dimension, Limit: limit, KeyState: *state}, nil
+}
+
+// limitOf parses a key built by IPKey, TokenKey or RouteKey and returns the limit that applies to it
+func (rl *RateLimiter) limitOf(key string) (Dimension, storage.Limit, error) {
+	cfg := rl.Config()
+	dimension, rest, _ := strings.Cut(key, ":")
+	switch Dimension(dimension) {
+	case DimensionIP:
+		if net.ParseIP(rest) == nil {
+			return "", storage.Limit{}, fmt.Errorf("%w: invalid IP address in %q", ErrUnknownKey, key)
+		}
+		return DimensionIP, cfg.IPLimit(), nil
+	case DimensionToken:
+		if rest == "" {
+			return "", storage.Limit{}, fmt.Errorf("%w: empty token in %q", ErrUnknownKey, key)
+		}
+		tokenConfig, _ := cfg.GetTokenConfig(rest)
+		return DimensionToken, tokenConfig.Limit(), nil
+	case DimensionRoute:
+		name, client, _ := strings.Cut(rest, ":")
+		if client == "" {
+			return "", storage.Limit{}, fmt.Errorf("%w: missing client in %q", ErrUnknownKey, key)
+		}
+		for _, route := range cfg.Routes {
+			if route.Name == name && !route.Exempt {
+				return DimensionRoute, route.Limit(), nil
+			}
+		}
+		return "", storage.Limit{}, fmt.Errorf("%w: no route policy %s", ErrUnknownKey, name)
+	default:
+		return "", storage.Limit{}, fmt.Errorf("%w: %q", ErrUnknownKey, key)
+	}
+}
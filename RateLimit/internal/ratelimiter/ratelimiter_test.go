@@ -0,0 +1,247 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/config"
+	"github.com/richardblynd/PosGO-Desafios/tree/main/ratelimiter/internal/storage"
+)
+
+func newTestRateLimiter(t *testing.T) *RateLimiter {
+	t.Helper()
+
+	cfg := &config.Config{
+		IPRateLimit:        2,
+		IPBlockDuration:    time.Minute,
+		TokenRateLimit:     3,
+		TokenBlockDuration: time.Minute,
+		TokenConfigs: map[string]config.TokenConfig{
+			"premium": {RateLimit: 5, BlockDuration: time.Minute},
+		},
+		Routes: []config.RoutePolicy{
+			{Name: "orders_post", Path: "/api/v1/orders", Methods: []string{"POST"}, RateLimit: 1, Window: time.Second, BlockDuration: time.Minute},
+		},
+	}
+
+	store := storage.NewMemoryStorage()
+	t.Cleanup(func() { store.Close() })
+	return New(store, cfg)
+}
+
+// allowed counts how many of n checks pass
+func allowed(t *testing.T, rl *RateLimiter, n int, ip, token string) int {
+	t.Helper()
+
+	count := 0
+	for i := 0; i < n; i++ {
+		result, err := rl.Check(context.Background(), ip, token)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Allowed {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRateLimiterCheck(t *testing.T) {
+	t.Run("IP limit", func(t *testing.T) {
+		rl := newTestRateLimiter(t)
+		if got := allowed(t, rl, 5, "10.0.0.1", ""); got != 2 {
+			t.Errorf("Expected 2 requests allowed, got %d", got)
+		}
+		// Each IP has its own counter
+		if got := allowed(t, rl, 1, "10.0.0.2", ""); got != 1 {
+			t.Errorf("Expected another IP to be allowed, got %d", got)
+		}
+	})
+
+	t.Run("Default token limit", func(t *testing.T) {
+		rl := newTestRateLimiter(t)
+		if got := allowed(t, rl, 5, "10.0.0.1", "unknown"); got != 3 {
+			t.Errorf("Expected 3 requests allowed, got %d", got)
+		}
+	})
+
+	t.Run("Token specific limit", func(t *testing.T) {
+		rl := newTestRateLimiter(t)
+		if got := allowed(t, rl, 10, "10.0.0.1", "premium"); got != 5 {
+			t.Errorf("Expected 5 requests allowed, got %d", got)
+		}
+	})
+
+	t.Run("Token takes precedence over IP", func(t *testing.T) {
+		rl := newTestRateLimiter(t)
+		allowed(t, rl, 5, "10.0.0.1", "")
+
+		// The IP is blocked, but a token request uses only the token limit
+		if got := allowed(t, rl, 1, "10.0.0.1", "premium"); got != 1 {
+			t.Fatal("Expected the token request to be allowed")
+		}
+		// And token requests don't count against the IP
+		allowed(t, rl, 3, "10.0.0.2", "premium")
+		info, err := rl.Inspect(context.Background(), IPKey("10.0.0.2"))
+		if err != nil {
+			t.Fatalf("Inspect should not fail: %v", err)
+		}
+		if info.Count != 0 {
+			t.Errorf("Expected the IP count to stay at 0, got %d", info.Count)
+		}
+	})
+
+	t.Run("Invalid IP", func(t *testing.T) {
+		rl := newTestRateLimiter(t)
+		if _, err := rl.Check(context.Background(), "not-an-ip", ""); err == nil {
+			t.Fatal("Expected an error for an invalid IP")
+		}
+	})
+}
+
+func TestRateLimiterRoutes(t *testing.T) {
+	rl := newTestRateLimiter(t)
+	ctx := context.Background()
+
+	route, ok := rl.Route("POST", "/api/v1/orders")
+	if !ok {
+		t.Fatal("Expected the orders_post policy")
+	}
+	if _, ok := rl.Route("GET", "/api/v1/orders"); ok {
+		t.Fatal("Expected no policy for GET")
+	}
+
+	for _, client := range []struct{ ip, token string }{{"10.0.0.1", ""}, {"10.0.0.1", "premium"}} {
+		first, err := rl.CheckRoute(ctx, route, client.ip, client.token)
+		if err != nil || !first.Allowed {
+			t.Fatalf("First request of %+v should be allowed: %v", client, err)
+		}
+		if second, _ := rl.CheckRoute(ctx, route, client.ip, client.token); second.Allowed {
+			t.Fatalf("Second request of %+v should be denied", client)
+		}
+	}
+}
+
+func TestRateLimiterInspectReset(t *testing.T) {
+	rl := newTestRateLimiter(t)
+	ctx := context.Background()
+
+	allowed(t, rl, 1, "10.0.0.1", "premium")
+	info, err := rl.Inspect(ctx, TokenKey("premium"))
+	if err != nil {
+		t.Fatalf("Inspect should not fail: %v", err)
+	}
+	if info.Dimension != DimensionToken || info.Limit.Rate != 5 || info.Count != 1 || info.Remaining != 4 {
+		t.Errorf("Unexpected premium token info: %+v", info)
+	}
+
+	allowed(t, rl, 3, "10.0.0.1", "")
+	info, _ = rl.Inspect(ctx, IPKey("10.0.0.1"))
+	if info.Dimension != DimensionIP || !info.Blocked {
+		t.Fatalf("Expected the IP to be blocked, got %+v", info)
+	}
+
+	if err := rl.Reset(ctx, IPKey("10.0.0.1")); err != nil {
+		t.Fatalf("Reset should not fail: %v", err)
+	}
+	if got := allowed(t, rl, 1, "10.0.0.1", ""); got != 1 {
+		t.Fatal("Expected the IP to be allowed after reset")
+	}
+
+	route, _ := rl.Route("POST", "/api/v1/orders")
+	rl.CheckRoute(ctx, route, "10.0.0.1", "")
+	info, err = rl.Inspect(ctx, RouteKey("orders_post", "10.0.0.1", ""))
+	if err != nil || info.Dimension != DimensionRoute || info.Limit.Rate != 1 || info.Count != 1 {
+		t.Errorf("Unexpected route info: %+v %v", info, err)
+	}
+
+	for _, key := range []string{"", "user:1", "ip:not-an-ip", "token:", "route:missing:ip:10.0.0.1", "route:orders_post"} {
+		if _, err := rl.Inspect(ctx, key); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey for %q, got %v", key, err)
+		}
+		if err := rl.Reset(ctx, key); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey when resetting %q, got %v", key, err)
+		}
+	}
+}
+
+func TestRateLimiterBlock(t *testing.T) {
+	rl := newTestRateLimiter(t)
+	ctx := context.Background()
+
+	if err := rl.Block(ctx, TokenKey("premium"), time.Minute); err != nil {
+		t.Fatalf("Block should not fail: %v", err)
+	}
+	if got := allowed(t, rl, 1, "10.0.0.1", "premium"); got != 0 {
+		t.Fatal("Expected the blocked token to be denied")
+	}
+	if blocked, _ := rl.ListBlocked(ctx); len(blocked) != 1 || blocked[0].Key != TokenKey("premium") {
+		t.Fatalf("Expected only the premium token to be blocked, got %v", blocked)
+	}
+
+	if err := rl.Unblock(ctx, TokenKey("premium")); err != nil {
+		t.Fatalf("Unblock should not fail: %v", err)
+	}
+	if got := allowed(t, rl, 1, "10.0.0.1", "premium"); got != 1 {
+		t.Fatal("Expected the token to be allowed after unblock")
+	}
+
+	if err := rl.Block(ctx, TokenKey("premium"), 0); err == nil {
+		t.Error("Expected an error for a zero block duration")
+	}
+	if err := rl.Block(ctx, "user:1", time.Minute); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+// recorder collects the observed decisions
+type recorder []Decision
+
+func (r *recorder) Observe(decision Decision) {
+	*r = append(*r, decision)
+}
+
+func TestRateLimiterObserver(t *testing.T) {
+	rl := newTestRateLimiter(t)
+	ctx := context.Background()
+	var decisions recorder
+	rl.SetObserver(&decisions)
+
+	allowed(t, rl, 3, "10.0.0.1", "")
+	allowed(t, rl, 1, "10.0.0.1", "premium")
+	allowed(t, rl, 1, "10.0.0.1", "unknown")
+	route, _ := rl.Route("POST", "/api/v1/orders")
+	rl.CheckRoute(ctx, route, "10.0.0.1", "premium")
+	rl.Check(ctx, "not-an-ip", "")
+
+	expected := recorder{
+		{Dimension: DimensionIP, Allowed: true},
+		{Dimension: DimensionIP, Allowed: true},
+		{Dimension: DimensionIP, Allowed: false},
+		{Dimension: DimensionToken, Token: "premium", CustomToken: true, Allowed: true},
+		{Dimension: DimensionToken, Token: "unknown", Allowed: true},
+		{Dimension: DimensionRoute, Token: "premium", CustomToken: true, Allowed: true},
+	}
+	if len(decisions) != len(expected) {
+		t.Fatalf("Expected %d decisions, got %+v", len(expected), decisions)
+	}
+	for i := range expected {
+		if decisions[i] != expected[i] {
+			t.Errorf("Decision %d: expected %+v, got %+v", i+1, expected[i], decisions[i])
+		}
+	}
+}
+
+func TestRateLimiterSetConfig(t *testing.T) {
+	rl := newTestRateLimiter(t)
+
+	cfg := *rl.Config()
+	cfg.IPRateLimit = 4
+	rl.SetConfig(&cfg)
+
+	if got := allowed(t, rl, 6, "10.0.0.1", ""); got != 4 {
+		t.Errorf("Expected the new limit of 4 requests, got %d", got)
+	}
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Algorithm identifies how requests are counted against a limit
+type Algorithm string
+
+const (
+	// AlgorithmSlidingLog keeps one record per request inside the window.
+	// It is exact, but its memory grows with the limit.
+	AlgorithmSlidingLog Algorithm = "sliding_log"
+	// AlgorithmTokenBucket refills Rate tokens per Window up to Burst tokens
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+	// AlgorithmFixedWindow counts the requests of each window aligned to the clock
+	AlgorithmFixedWindow Algorithm = "fixed_window"
+	// AlgorithmSlidingWindow weights the count of the previous fixed window
+	// by how much of it still overlaps the sliding window
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+)
+
+// ParseAlgorithm validates an algorithm name. An empty name selects the sliding log.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	algorithm := Algorithm(strings.ToLower(strings.TrimSpace(name)))
+	switch algorithm {
+	case "":
+		return AlgorithmSlidingLog, nil
+	case AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit algorithm %q (use sliding_log, token_bucket, fixed_window or sliding_window)", name)
+	}
+}
+
+// Limit describes a rate limit and the algorithm that enforces it
+type Limit struct {
+	Algorithm     Algorithm     // Counting algorithm (sliding log when empty)
+	Rate          int           // Requests allowed per Window
+	Window        time.Duration // Time window for rate limiting (typically 1 second)
+	Burst         int           // Token bucket capacity (Rate when zero)
+	BlockDuration time.Duration // How long to block if the limit is exceeded
+}
+
+// ErrInvalidLimit is returned for a limit that cannot be enforced
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// validate rejects the limits the algorithms would divide by zero with. The
+// Redis scripts count in microseconds, so the window must be at least 1µs.
+func (l Limit) validate() error {
+	switch {
+	case l.Rate <= 0:
+		return fmt.Errorf("%w: rate must be positive, got %d", ErrInvalidLimit, l.Rate)
+	case l.Burst < 0:
+		return fmt.Errorf("%w: burst must not be negative, got %d", ErrInvalidLimit, l.Burst)
+	case l.Window < time.Microsecond:
+		return fmt.Errorf("%w: window must be at least 1µs, got %v", ErrInvalidLimit, l.Window)
+	}
+	return nil
+}
+
+func (l Limit) algorithm() Algorithm {
+	if l.Algorithm == "" {
+		return AlgorithmSlidingLog
+	}
+	return l.Algorithm
+}
+
+// perNanosecond is the token bucket refill rate
+func (l Limit) perNanosecond() float64 {
+	return float64(l.Rate) / float64(l.Window)
+}
+
+func (l Limit) burst() int {
+	if l.Burst <= 0 {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// result builds the outcome of a check. A denied request is blocked for
+// BlockDuration; without one, it may retry as soon as the algorithm allows.
+func (l Limit) result(allowed bool, remaining int, retryAfter time.Duration) *RateLimitResult {
+	result := &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     l.Rate,
+		Remaining: max(remaining, 0),
+	}
+	if !allowed {
+		result.Remaining = 0
+		result.RetryAfter = retryAfter
+		if l.BlockDuration > 0 {
+			result.RetryAfter = l.BlockDuration
+		}
+	}
+	return result
+}
+
+// tokenBucket holds the state of a token bucket
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	expires time.Time // when the bucket is full again and can be dropped
+}
+
+// refill adds the tokens earned since the last refill, up to the capacity
+func (b *tokenBucket) refill(limit Limit, now time.Time) {
+	capacity := float64(limit.burst())
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)*limit.perNanosecond())
+	}
+	b.last = now
+}
+
+// take refills the bucket up to now and takes a token if there is one
+func (b *tokenBucket) take(limit Limit, now time.Time) (bool, int, time.Duration) {
+	b.refill(limit, now)
+	if b.tokens < 1 {
+		return false, 0, time.Duration(math.Ceil((1 - b.tokens) / limit.perNanosecond()))
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// fullAt returns when the bucket will be refilled to its capacity
+func (b tokenBucket) fullAt(limit Limit) time.Time {
+	missing := float64(limit.burst()) - b.tokens
+	return b.last.Add(time.Duration(math.Ceil(missing / limit.perNanosecond())))
+}
+
+// state reports the bucket at now without taking a token. The count is the
+// number of tokens missing from a full bucket.
+func (b tokenBucket) state(limit Limit, now time.Time) (int, int) {
+	b.refill(limit, now)
+	return limit.burst() - int(b.tokens), int(b.tokens)
+}
+
+// windowCounter holds the counts of the current and previous fixed windows
+type windowCounter struct {
+	start    time.Time
+	count    int
+	previous int
+	expires  time.Time // when the counts no longer affect any window and can be dropped
+}
+
+// advance moves the counter to the window that contains now
+func (w *windowCounter) advance(window time.Duration, now time.Time) {
+	start := windowStart(window, now)
+	switch {
+	case w.start.Equal(start):
+		return
+	case w.start.Add(window).Equal(start):
+		w.previous = w.count
+	default:
+		w.previous = 0
+	}
+	w.start = start
+	w.count = 0
+}
+
+// expiresAt returns when the counts stop mattering. The sliding window
+// algorithm still weighs the current window during the next one.
+func (w windowCounter) expiresAt(limit Limit) time.Time {
+	if limit.algorithm() == AlgorithmSlidingWindow {
+		return w.start.Add(2 * limit.Window)
+	}
+	return w.start.Add(limit.Window)
+}
+
+// windowStart returns the start of the window that contains now. Windows are
+// aligned to the Unix epoch, as in the Redis scripts.
+func windowStart(window time.Duration, now time.Time) time.Time {
+	nanos := now.UnixNano()
+	return time.Unix(0, nanos-nanos%int64(window))
+}
+
+// state reports the counter at now without counting a request. The sliding
+// window count is its estimate, rounded up.
+func (w windowCounter) state(limit Limit, now time.Time) (int, int) {
+	w.advance(limit.Window, now)
+	count := w.count
+	if limit.algorithm() == AlgorithmSlidingWindow {
+		count = int(math.Ceil(slidingEstimate(w.previous, w.count, limit.Window, now.Sub(w.start))))
+	}
+	return count, max(limit.Rate-count, 0)
+}
+
+// fixed counts the request in the current window
+func (w *windowCounter) fixed(limit Limit, now time.Time) (bool, int, time.Duration) {
+	w.advance(limit.Window, now)
+	if w.count >= limit.Rate {
+		return false, 0, w.start.Add(limit.Window).Sub(now)
+	}
+	w.count++
+	return true, limit.Rate - w.count, 0
+}
+
+// sliding counts the request against the estimate of the sliding window
+func (w *windowCounter) sliding(limit Limit, now time.Time) (bool, int, time.Duration) {
+	w.advance(limit.Window, now)
+	estimate := slidingEstimate(w.previous, w.count, limit.Window, now.Sub(w.start))
+	if estimate >= float64(limit.Rate) {
+		return false, 0, w.start.Add(limit.Window).Sub(now)
+	}
+	w.count++
+	return true, limit.Rate - int(math.Ceil(estimate)) - 1, 0
+}
+
+// slidingEstimate approximates the requests of the last window, assuming the
+// previous window's requests were evenly spread
+func slidingEstimate(previous, current int, window, elapsed time.Duration) float64 {
+	overlap := 1 - float64(elapsed)/float64(window)
+	return float64(previous)*overlap + float64(current)
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAlgorithm(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Algorithm
+		fails    bool
+	}{
+		{"", AlgorithmSlidingLog, false},
+		{"sliding_log", AlgorithmSlidingLog, false},
+		{"TOKEN_BUCKET", AlgorithmTokenBucket, false},
+		{" fixed_window ", AlgorithmFixedWindow, false},
+		{"sliding_window", AlgorithmSlidingWindow, false},
+		{"leaky_bucket", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Algorithm_"+tc.input, func(t *testing.T) {
+			algorithm, err := ParseAlgorithm(tc.input)
+			if (err != nil) != tc.fails {
+				t.Fatalf("Expected error %v, got %v", tc.fails, err)
+			}
+			if algorithm != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, algorithm)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	limit := Limit{Algorithm: AlgorithmTokenBucket, Rate: 10, Window: time.Second, Burst: 3}
+	now := time.Unix(1700000000, 0)
+	bucket := &tokenBucket{}
+
+	// The bucket starts full, so the burst passes at once
+	for i := 0; i < 3; i++ {
+		allowed, remaining, _ := bucket.take(limit, now)
+		if !allowed {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+		if remaining != 2-i {
+			t.Errorf("Expected %d remaining, got %d", 2-i, remaining)
+		}
+	}
+
+	allowed, _, retryAfter := bucket.take(limit, now)
+	if allowed {
+		t.Fatal("Request beyond the burst should be denied")
+	}
+	if retryAfter != 100*time.Millisecond {
+		t.Errorf("Expected retry after 100ms, got %v", retryAfter)
+	}
+
+	// 10 requests per second refill one token every 100ms
+	if allowed, _, _ := bucket.take(limit, now.Add(100*time.Millisecond)); !allowed {
+		t.Fatal("Request after a refill should be allowed")
+	}
+
+	// The bucket never holds more than the burst
+	for i := 0; i < 4; i++ {
+		allowed, _, _ := bucket.take(limit, now.Add(time.Minute))
+		if allowed != (i < 3) {
+			t.Fatalf("Request %d after a long pause: expected allowed %v", i+1, i < 3)
+		}
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	limit := Limit{Algorithm: AlgorithmFixedWindow, Rate: 2, Window: time.Second}
+	start := time.Unix(1700000000, 0)
+	counter := &windowCounter{}
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := counter.fixed(limit, start.Add(100*time.Millisecond)); !allowed {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+
+	allowed, _, retryAfter := counter.fixed(limit, start.Add(700*time.Millisecond))
+	if allowed {
+		t.Fatal("Third request in the window should be denied")
+	}
+	if retryAfter != 300*time.Millisecond {
+		t.Errorf("Expected retry at the end of the window (300ms), got %v", retryAfter)
+	}
+
+	// The count restarts in the next window
+	if allowed, remaining, _ := counter.fixed(limit, start.Add(time.Second)); !allowed || remaining != 1 {
+		t.Errorf("Expected the next window to allow with 1 remaining, got %v %d", allowed, remaining)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limit := Limit{Algorithm: AlgorithmSlidingWindow, Rate: 4, Window: time.Second}
+	start := time.Unix(1700000000, 0)
+	counter := &windowCounter{}
+
+	for i := 0; i < 4; i++ {
+		if allowed, _, _ := counter.sliding(limit, start.Add(900*time.Millisecond)); !allowed {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+
+	// At 25% of the next window, 75% of the previous 4 requests still count
+	if allowed, _, _ := counter.sliding(limit, start.Add(1250*time.Millisecond)); !allowed {
+		t.Fatal("Expected 3 weighted requests to leave room for one more")
+	}
+	if allowed, _, _ := counter.sliding(limit, start.Add(1250*time.Millisecond)); allowed {
+		t.Fatal("Expected the estimate to reach the limit")
+	}
+
+	// Two windows later nothing of the old requests counts
+	if allowed, remaining, _ := counter.sliding(limit, start.Add(3*time.Second)); !allowed || remaining != 3 {
+		t.Errorf("Expected a fresh window with 3 remaining, got %v %d", allowed, remaining)
+	}
+}
+
+func TestLimitResult(t *testing.T) {
+	withoutBlock := Limit{Rate: 5, Window: time.Second}
+	if result := withoutBlock.result(false, 3, 200*time.Millisecond); result.RetryAfter != 200*time.Millisecond || result.Remaining != 0 {
+		t.Errorf("Expected the algorithm's retry and no remaining, got %+v", result)
+	}
+
+	withBlock := Limit{Rate: 5, Window: time.Second, BlockDuration: time.Minute}
+	if result := withBlock.result(false, 0, 200*time.Millisecond); result.RetryAfter != time.Minute {
+		t.Errorf("Expected the block duration as retry, got %v", result.RetryAfter)
+	}
+
+	if result := withBlock.result(true, -1, 0); !result.Allowed || result.Remaining != 0 || result.Limit != 5 {
+		t.Errorf("Unexpected allowed result: %+v", result)
+	}
+}
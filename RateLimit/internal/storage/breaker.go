@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned while the circuit breaker is open and there is no fallback
+var ErrUnavailable = errors.New("storage unavailable")
+
+// CircuitBreaker wraps a storage that may go down, such as Redis. After
+// threshold consecutive errors it opens: calls skip the primary storage and
+// go to the fallback, or fail with ErrUnavailable without one. While open, it
+// checks the primary Health every interval and closes once it answers.
+type CircuitBreaker struct {
+	primary   Storage
+	fallback  Storage
+	threshold int
+	interval  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	done     chan struct{}
+	closed   sync.Once
+}
+
+// NewCircuitBreaker creates a circuit breaker over primary. The fallback may
+// be nil; a zero threshold opens on the first error.
+func NewCircuitBreaker(primary, fallback Storage, threshold int, interval time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		primary:   primary,
+		fallback:  fallback,
+		threshold: max(threshold, 1),
+		interval:  interval,
+		done:      make(chan struct{}),
+	}
+}
+
+// Open reports whether the breaker is open
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+// do runs call on the primary storage, or on the fallback when the primary is down
+func (b *CircuitBreaker) do(ctx context.Context, call func(Storage) error) error {
+	return b.run(call, true)
+}
+
+// run is do with the choice of counting the outcome towards opening the breaker
+func (b *CircuitBreaker) run(call func(Storage) error, counted bool) error {
+	if b.Open() {
+		if b.fallback != nil {
+			return call(b.fallback)
+		}
+		return ErrUnavailable
+	}
+
+	err := call(b.primary)
+	if err == nil {
+		if counted {
+			b.mu.Lock()
+			b.failures = 0
+			b.mu.Unlock()
+		}
+		return nil
+	}
+	// A client that went away or an invalid limit say nothing about the storage
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrInvalidLimit) {
+		return err
+	}
+
+	if counted {
+		b.failure(err)
+	}
+	if b.fallback != nil {
+		return call(b.fallback)
+	}
+	return err
+}
+
+// failure counts an error and opens the breaker once the threshold is reached
+func (b *CircuitBreaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.open || b.failures < b.threshold {
+		return
+	}
+	b.open = true
+	log.Printf("Storage circuit breaker open after %d consecutive errors: %v", b.failures, err)
+	go b.probe()
+}
+
+// Trip opens the breaker without waiting for the threshold, such as when the
+// primary is already down at startup. It closes once the primary is healthy.
+func (b *CircuitBreaker) Trip(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.open {
+		return
+	}
+	b.open = true
+	log.Printf("Storage circuit breaker open: %v", err)
+	go b.probe()
+}
+
+// probe checks the primary health until it recovers, then closes the breaker
+func (b *CircuitBreaker) probe() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.interval)
+		err := b.primary.Health(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		b.open = false
+		b.failures = 0
+		b.mu.Unlock()
+		log.Printf("Storage recovered, circuit breaker closed")
+		return
+	}
+}
+
+// CheckRateLimit implements the Storage interface
+func (b *CircuitBreaker) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (*RateLimitResult, error) {
+	return b.CheckLimit(ctx, key, Limit{Rate: limit, Window: window, BlockDuration: blockDuration})
+}
+
+// CheckLimit implements the Storage interface
+func (b *CircuitBreaker) CheckLimit(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	var result *RateLimitResult
+	err := b.do(ctx, func(s Storage) (err error) {
+		result, err = s.CheckLimit(ctx, key, limit)
+		return err
+	})
+	return result, err
+}
+
+// Inspect implements the Storage interface
+func (b *CircuitBreaker) Inspect(ctx context.Context, key string, limit Limit) (*KeyState, error) {
+	var state *KeyState
+	err := b.do(ctx, func(s Storage) (err error) {
+		state, err = s.Inspect(ctx, key, limit)
+		return err
+	})
+	return state, err
+}
+
+// Reset implements the Storage interface
+func (b *CircuitBreaker) Reset(ctx context.Context, key string) error {
+	return b.do(ctx, func(s Storage) error { return s.Reset(ctx, key) })
+}
+
+// IsBlocked implements the Storage interface
+func (b *CircuitBreaker) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
+	var blocked bool
+	var ttl time.Duration
+	err := b.do(ctx, func(s Storage) (err error) {
+		blocked, ttl, err = s.IsBlocked(ctx, key)
+		return err
+	})
+	return blocked, ttl, err
+}
+
+// Block implements the Storage interface
+func (b *CircuitBreaker) Block(ctx context.Context, key string, duration time.Duration) error {
+	return b.do(ctx, func(s Storage) error { return s.Block(ctx, key, duration) })
+}
+
+// Unblock implements the Storage interface
+func (b *CircuitBreaker) Unblock(ctx context.Context, key string) error {
+	return b.do(ctx, func(s Storage) error { return s.Unblock(ctx, key) })
+}
+
+// ListBlocked implements the Storage interface. Listing scans every key and
+// is not on the request path, so a slow scan doesn't open the breaker.
+func (b *CircuitBreaker) ListBlocked(ctx context.Context) ([]BlockedKey, error) {
+	var blocked []BlockedKey
+	err := b.run(func(s Storage) (err error) {
+		blocked, err = s.ListBlocked(ctx)
+		return err
+	}, false)
+	return blocked, err
+}
+
+// Close stops the health checks and closes both storages
+func (b *CircuitBreaker) Close() error {
+	b.closed.Do(func() { close(b.done) })
+	err := b.primary.Close()
+	if b.fallback != nil {
+		err = errors.Join(err, b.fallback.Close())
+	}
+	return err
+}
+
+// Health checks the primary storage, whatever the state of the breaker
+func (b *CircuitBreaker) Health(ctx context.Context) error {
+	return b.primary.Health(ctx)
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+// flakyStorage is a MemoryStorage that fails while down
+type flakyStorage struct {
+	*MemoryStorage
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (f *flakyStorage) CheckLimit(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return nil, errDown
+	}
+	return f.MemoryStorage.CheckLimit(ctx, key, limit)
+}
+
+func (f *flakyStorage) ListBlocked(ctx context.Context) ([]BlockedKey, error) {
+	if f.down.Load() {
+		return nil, errDown
+	}
+	return f.MemoryStorage.ListBlocked(ctx)
+}
+
+func (f *flakyStorage) Health(ctx context.Context) error {
+	if f.down.Load() {
+		return errDown
+	}
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Window: time.Minute}
+
+	t.Run("Opens after the threshold", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, nil, 3, time.Hour)
+		defer breaker.Close()
+
+		primary.down.Store(true)
+		for i := 0; i < 3; i++ {
+			if _, err := breaker.CheckLimit(ctx, "ip:1", limit); !errors.Is(err, errDown) {
+				t.Fatalf("Expected the storage error on call %d, got %v", i+1, err)
+			}
+		}
+		if !breaker.Open() {
+			t.Fatal("Expected the breaker to be open after 3 errors")
+		}
+
+		// While open, the primary is not called
+		calls := primary.calls.Load()
+		if _, err := breaker.CheckLimit(ctx, "ip:1", limit); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Expected ErrUnavailable, got %v", err)
+		}
+		if primary.calls.Load() != calls {
+			t.Error("Expected the open breaker to skip the primary storage")
+		}
+	})
+
+	t.Run("Successes reset the count", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, nil, 2, time.Hour)
+		defer breaker.Close()
+
+		for i := 0; i < 3; i++ {
+			primary.down.Store(true)
+			breaker.CheckLimit(ctx, "ip:1", limit)
+			primary.down.Store(false)
+			breaker.CheckLimit(ctx, "ip:1", limit)
+		}
+		if breaker.Open() {
+			t.Fatal("Expected intermittent errors to keep the breaker closed")
+		}
+	})
+
+	t.Run("Canceled requests don't count", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, nil, 1, time.Hour)
+		defer breaker.Close()
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		breaker.do(canceled, func(Storage) error { return context.Canceled })
+		if breaker.Open() {
+			t.Fatal("Expected a canceled request to keep the breaker closed")
+		}
+	})
+
+	t.Run("Invalid limits don't count", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, NewMemoryStorage(), 1, time.Hour)
+		defer breaker.Close()
+
+		if _, err := breaker.CheckLimit(ctx, "ip:1", Limit{Window: time.Second}); !errors.Is(err, ErrInvalidLimit) {
+			t.Fatalf("Expected ErrInvalidLimit, got %v", err)
+		}
+		if breaker.Open() {
+			t.Fatal("Expected an invalid limit to keep the breaker closed")
+		}
+	})
+
+	t.Run("Listing blocked keys doesn't count", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, nil, 1, time.Hour)
+		defer breaker.Close()
+
+		primary.down.Store(true)
+		if _, err := breaker.ListBlocked(ctx); !errors.Is(err, errDown) {
+			t.Fatalf("Expected the storage error, got %v", err)
+		}
+		if breaker.Open() {
+			t.Fatal("Expected a failed listing to keep the breaker closed")
+		}
+	})
+
+	t.Run("Fallback while open", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, NewMemoryStorage(), 1, time.Hour)
+		defer breaker.Close()
+
+		primary.down.Store(true)
+		// The failing request is already served by the fallback
+		for i := 0; i < limit.Rate; i++ {
+			result, err := breaker.CheckLimit(ctx, "ip:1", limit)
+			if err != nil || !result.Allowed {
+				t.Fatalf("Request %d should be allowed by the fallback: %+v %v", i+1, result, err)
+			}
+		}
+		// And the fallback enforces the limit
+		if result, _ := breaker.CheckLimit(ctx, "ip:1", limit); result.Allowed {
+			t.Fatal("Expected the fallback to deny the request over the limit")
+		}
+	})
+
+	t.Run("Closes when the storage recovers", func(t *testing.T) {
+		primary := &flakyStorage{MemoryStorage: NewMemoryStorage()}
+		breaker := NewCircuitBreaker(primary, nil, 1, 10*time.Millisecond)
+		defer breaker.Close()
+
+		primary.down.Store(true)
+		breaker.CheckLimit(ctx, "ip:1", limit)
+		if !breaker.Open() {
+			t.Fatal("Expected the breaker to be open")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		if !breaker.Open() {
+			t.Fatal("Expected the breaker to stay open while the storage is down")
+		}
+
+		primary.down.Store(false)
+		deadline := time.Now().Add(time.Second)
+		for breaker.Open() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if breaker.Open() {
+			t.Fatal("Expected the breaker to close once the storage is healthy")
+		}
+		if result, err := breaker.CheckLimit(ctx, "ip:1", limit); err != nil || !result.Allowed {
+			t.Fatalf("Expected the primary to serve requests again: %+v %v", result, err)
+		}
+	})
+}
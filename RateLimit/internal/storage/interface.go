@@ -13,6 +13,20 @@ type RateLimitResult struct {
 	RetryAfter time.Duration // How long to wait before retry (if blocked)
 }
 
+// KeyState represents the state of a key, as seen by Inspect
+type KeyState struct {
+	Count      int           // Requests counted in the current window (tokens used for the token bucket)
+	Remaining  int           // Requests left before the limit is reached
+	Blocked    bool          // Whether the key is blocked
+	BlockedFor time.Duration // How long the block lasts (if blocked)
+}
+
+// BlockedKey is a blocked key and how long its block lasts
+type BlockedKey struct {
+	Key string
+	TTL time.Duration
+}
+
 // Storage defines the interface for rate limiter storage backends
 type Storage interface {
 	// CheckRateLimit checks if a request is allowed and updates the counter
@@ -22,12 +36,28 @@ type Storage interface {
 	// blockDuration: how long to block if limit is exceeded
 	CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (*RateLimitResult, error)
 
+	// CheckLimit checks if a request is allowed using the algorithm of the limit.
+	// CheckRateLimit is CheckLimit with the sliding log algorithm.
+	CheckLimit(ctx context.Context, key string, limit Limit) (*RateLimitResult, error)
+
+	// Inspect reports the state of a key under the limit without counting a request
+	Inspect(ctx context.Context, key string, limit Limit) (*KeyState, error)
+
+	// Reset removes the counters of every algorithm and the block of a key
+	Reset(ctx context.Context, key string) error
+
 	// IsBlocked checks if a key is currently blocked
 	IsBlocked(ctx context.Context, key string) (bool, time.Duration, error)
 
 	// Block blocks a key for the specified duration
 	Block(ctx context.Context, key string, duration time.Duration) error
 
+	// Unblock removes the block of a key, keeping its counters
+	Unblock(ctx context.Context, key string) error
+
+	// ListBlocked returns every blocked key
+	ListBlocked(ctx context.Context) ([]BlockedKey, error)
+
 	// Close closes the storage connection
 	Close() error
 
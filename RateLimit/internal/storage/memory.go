@@ -9,6 +9,7 @@ import (
 // requestRecord represents a single request record
 type requestRecord struct {
 	timestamp time.Time
+	expires   time.Time // when the request leaves the window
 }
 
 // blockRecord represents a blocked key
@@ -21,6 +22,8 @@ type blockRecord struct {
 type MemoryStorage struct {
 	mu       sync.RWMutex
 	requests map[string][]requestRecord
+	buckets  map[string]*tokenBucket
+	windows  map[string]*windowCounter
 	blocks   map[string]blockRecord
 }
 
@@ -28,6 +31,8 @@ type MemoryStorage struct {
 func NewMemoryStorage() *MemoryStorage {
 	storage := &MemoryStorage{
 		requests: make(map[string][]requestRecord),
+		buckets:  make(map[string]*tokenBucket),
+		windows:  make(map[string]*windowCounter),
 		blocks:   make(map[string]blockRecord),
 	}
 
@@ -39,6 +44,15 @@ func NewMemoryStorage() *MemoryStorage {
 
 // CheckRateLimit implements the Storage interface
 func (m *MemoryStorage) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (*RateLimitResult, error) {
+	return m.CheckLimit(ctx, key, Limit{Rate: limit, Window: window, BlockDuration: blockDuration})
+}
+
+// CheckLimit implements the Storage interface
+func (m *MemoryStorage) CheckLimit(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -47,7 +61,7 @@ func (m *MemoryStorage) CheckRateLimit(ctx context.Context, key string, limit in
 		if time.Now().Before(block.until) {
 			return &RateLimitResult{
 				Allowed:    false,
-				Limit:      limit,
+				Limit:      limit.Rate,
 				Remaining:  0,
 				RetryAfter: time.Until(block.until),
 			}, nil
@@ -58,7 +72,48 @@ func (m *MemoryStorage) CheckRateLimit(ctx context.Context, key string, limit in
 	}
 
 	now := time.Now()
-	windowStart := now.Add(-window)
+
+	var allowed bool
+	var remaining int
+	var retryAfter time.Duration
+	switch limit.algorithm() {
+	case AlgorithmTokenBucket:
+		bucket, exists := m.buckets[key]
+		if !exists {
+			bucket = &tokenBucket{}
+			m.buckets[key] = bucket
+		}
+		allowed, remaining, retryAfter = bucket.take(limit, now)
+		bucket.expires = bucket.fullAt(limit)
+	case AlgorithmFixedWindow, AlgorithmSlidingWindow:
+		// Both algorithms use window counters, but with different meanings
+		windowKey := string(limit.algorithm()) + ":" + key
+		counter, exists := m.windows[windowKey]
+		if !exists {
+			counter = &windowCounter{}
+			m.windows[windowKey] = counter
+		}
+		if limit.algorithm() == AlgorithmFixedWindow {
+			allowed, remaining, retryAfter = counter.fixed(limit, now)
+		} else {
+			allowed, remaining, retryAfter = counter.sliding(limit, now)
+		}
+		counter.expires = counter.expiresAt(limit)
+	default:
+		allowed, remaining, retryAfter = m.slidingLog(key, limit, now)
+	}
+
+	result := limit.result(allowed, remaining, retryAfter)
+	if !allowed && limit.BlockDuration > 0 {
+		// Block the key
+		m.blocks[key] = blockRecord{until: now.Add(limit.BlockDuration)}
+	}
+	return result, nil
+}
+
+// slidingLog counts the requests recorded inside the window. Must be called with the lock held.
+func (m *MemoryStorage) slidingLog(key string, limit Limit, now time.Time) (bool, int, time.Duration) {
+	windowStart := now.Add(-limit.Window)
 
 	// Get existing requests for this key
 	records := m.requests[key]
@@ -72,31 +127,73 @@ func (m *MemoryStorage) CheckRateLimit(ctx context.Context, key string, limit in
 	}
 
 	currentCount := len(validRecords)
+	if currentCount >= limit.Rate {
+		// Rate limit exceeded, retry when the oldest request leaves the window
+		m.requests[key] = validRecords
+		if currentCount == 0 {
+			return false, 0, limit.Window
+		}
+		return false, 0, validRecords[0].timestamp.Sub(windowStart)
+	}
+
+	// Request allowed, add it to records
+	validRecords = append(validRecords, requestRecord{timestamp: now, expires: now.Add(limit.Window)})
+	m.requests[key] = validRecords
+	return true, limit.Rate - currentCount - 1, 0 // -1 for the current request
+}
 
-	result := &RateLimitResult{
-		Limit:     limit,
-		Remaining: limit - currentCount - 1, // -1 for the current request
+// Inspect implements the Storage interface
+func (m *MemoryStorage) Inspect(ctx context.Context, key string, limit Limit) (*KeyState, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
 	}
 
-	if currentCount >= limit {
-		// Rate limit exceeded
-		result.Allowed = false
-		result.Remaining = 0
-		result.RetryAfter = blockDuration
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-		// Block the key
-		m.blocks[key] = blockRecord{until: now.Add(blockDuration)}
-	} else {
-		// Request allowed, add it to records
-		result.Allowed = true
-		if result.Remaining < 0 {
-			result.Remaining = 0
-		}
-		validRecords = append(validRecords, requestRecord{timestamp: now})
-		m.requests[key] = validRecords
+	now := time.Now()
+	state := &KeyState{}
+	if block, exists := m.blocks[key]; exists && now.Before(block.until) {
+		state.Blocked = true
+		state.BlockedFor = block.until.Sub(now)
 	}
 
-	return result, nil
+	switch limit.algorithm() {
+	case AlgorithmTokenBucket:
+		bucket := tokenBucket{}
+		if existing, exists := m.buckets[key]; exists {
+			bucket = *existing
+		}
+		state.Count, state.Remaining = bucket.state(limit, now)
+	case AlgorithmFixedWindow, AlgorithmSlidingWindow:
+		counter := windowCounter{}
+		if existing, exists := m.windows[string(limit.algorithm())+":"+key]; exists {
+			counter = *existing
+		}
+		state.Count, state.Remaining = counter.state(limit, now)
+	default:
+		windowStart := now.Add(-limit.Window)
+		for _, record := range m.requests[key] {
+			if record.timestamp.After(windowStart) {
+				state.Count++
+			}
+		}
+		state.Remaining = max(limit.Rate-state.Count, 0)
+	}
+	return state, nil
+}
+
+// Reset implements the Storage interface
+func (m *MemoryStorage) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.requests, key)
+	delete(m.buckets, key)
+	delete(m.windows, string(AlgorithmFixedWindow)+":"+key)
+	delete(m.windows, string(AlgorithmSlidingWindow)+":"+key)
+	delete(m.blocks, key)
+	return nil
 }
 
 // IsBlocked checks if a key is currently blocked
@@ -122,6 +219,30 @@ func (m *MemoryStorage) Block(ctx context.Context, key string, duration time.Dur
 	return nil
 }
 
+// Unblock removes the block of a key
+func (m *MemoryStorage) Unblock(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blocks, key)
+	return nil
+}
+
+// ListBlocked returns every blocked key
+func (m *MemoryStorage) ListBlocked(ctx context.Context) ([]BlockedKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var blocked []BlockedKey
+	for key, block := range m.blocks {
+		if now.Before(block.until) {
+			blocked = append(blocked, BlockedKey{Key: key, TTL: block.until.Sub(now)})
+		}
+	}
+	return blocked, nil
+}
+
 // Close closes the storage (no-op for memory storage)
 func (m *MemoryStorage) Close() error {
 	return nil
@@ -138,33 +259,48 @@ func (m *MemoryStorage) cleanup() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		m.mu.Lock()
+		m.removeExpired(time.Now())
+	}
+}
 
-		now := time.Now()
+// removeExpired drops the blocks, records, buckets and counters that no
+// longer affect any limit at now. Each entry expires according to the window
+// of the limit that created it.
+func (m *MemoryStorage) removeExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		// Clean up expired blocks
-		for key, block := range m.blocks {
-			if now.After(block.until) {
-				delete(m.blocks, key)
-			}
+	// Clean up expired blocks
+	for key, block := range m.blocks {
+		if now.After(block.until) {
+			delete(m.blocks, key)
 		}
+	}
 
-		// Clean up old request records (older than 10 minutes)
-		cutoff := now.Add(-10 * time.Minute)
-		for key, records := range m.requests {
-			var validRecords []requestRecord
-			for _, record := range records {
-				if record.timestamp.After(cutoff) {
-					validRecords = append(validRecords, record)
-				}
-			}
-			if len(validRecords) == 0 {
-				delete(m.requests, key)
-			} else {
-				m.requests[key] = validRecords
+	// Clean up request records that left their window
+	for key, records := range m.requests {
+		var validRecords []requestRecord
+		for _, record := range records {
+			if record.expires.After(now) {
+				validRecords = append(validRecords, record)
 			}
 		}
+		if len(validRecords) == 0 {
+			delete(m.requests, key)
+		} else {
+			m.requests[key] = validRecords
+		}
+	}
 
-		m.mu.Unlock()
+	// Clean up full token buckets and window counters of past windows
+	for key, bucket := range m.buckets {
+		if !bucket.expires.After(now) {
+			delete(m.buckets, key)
+		}
+	}
+	for key, counter := range m.windows {
+		if !counter.expires.After(now) {
+			delete(m.windows, key)
+		}
 	}
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -82,6 +83,220 @@ func TestMemoryStorageRateLimit(t *testing.T) {
 	})
 }
 
+func TestMemoryStorageAlgorithms(t *testing.T) {
+	storage := NewMemoryStorage()
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	algorithms := []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			key := "test:" + string(algorithm)
+			limit := Limit{Algorithm: algorithm, Rate: 5, Window: time.Minute, BlockDuration: 100 * time.Millisecond}
+
+			for i := 0; i < limit.Rate; i++ {
+				result, err := storage.CheckLimit(ctx, key, limit)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !result.Allowed {
+					t.Fatalf("Request %d should be allowed", i+1)
+				}
+			}
+
+			result, err := storage.CheckLimit(ctx, key, limit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Allowed || result.RetryAfter != limit.BlockDuration {
+				t.Fatalf("6th request should be blocked for %v, got %+v", limit.BlockDuration, result)
+			}
+
+			if blocked, _, _ := storage.IsBlocked(ctx, key); !blocked {
+				t.Fatal("Key should be blocked after exceeding the limit")
+			}
+		})
+	}
+
+	t.Run("Same key with different algorithms", func(t *testing.T) {
+		key := "test:shared"
+		for _, algorithm := range algorithms {
+			result, err := storage.CheckLimit(ctx, key, Limit{Algorithm: algorithm, Rate: 1, Window: time.Minute})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !result.Allowed {
+				t.Fatalf("First %s request should be allowed", algorithm)
+			}
+		}
+	})
+}
+
+func TestMemoryStorageInvalidLimit(t *testing.T) {
+	storage := NewMemoryStorage()
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	// A zero rate or window would make the token bucket divide by zero
+	invalid := []Limit{
+		{Algorithm: AlgorithmTokenBucket, Rate: 0, Window: time.Second},
+		{Algorithm: AlgorithmTokenBucket, Rate: 5, Window: 0},
+		{Algorithm: AlgorithmTokenBucket, Rate: 5, Window: time.Second, Burst: -1},
+		{Algorithm: AlgorithmFixedWindow, Rate: 5, Window: 500 * time.Nanosecond},
+	}
+	for _, limit := range invalid {
+		if _, err := storage.CheckLimit(ctx, "invalid", limit); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("Expected ErrInvalidLimit from CheckLimit with %+v, got %v", limit, err)
+		}
+		if _, err := storage.Inspect(ctx, "invalid", limit); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("Expected ErrInvalidLimit from Inspect with %+v, got %v", limit, err)
+		}
+	}
+}
+
+func TestMemoryStorageInspectReset(t *testing.T) {
+	storage := NewMemoryStorage()
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	algorithms := []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			key := "inspect:" + string(algorithm)
+			limit := Limit{Algorithm: algorithm, Rate: 3, Window: time.Hour, BlockDuration: time.Minute}
+
+			state, err := storage.Inspect(ctx, key, limit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if state.Count != 0 || state.Remaining != 3 || state.Blocked {
+				t.Fatalf("Expected an empty key, got %+v", state)
+			}
+
+			for i := 0; i < 2; i++ {
+				storage.CheckLimit(ctx, key, limit)
+			}
+			state, _ = storage.Inspect(ctx, key, limit)
+			if state.Count != 2 || state.Remaining != 1 {
+				t.Fatalf("Expected 2 requests counted, got %+v", state)
+			}
+			// Inspecting does not count a request
+			state, _ = storage.Inspect(ctx, key, limit)
+			if state.Count != 2 {
+				t.Fatalf("Expected Inspect to leave the count at 2, got %d", state.Count)
+			}
+
+			for i := 0; i < 2; i++ {
+				storage.CheckLimit(ctx, key, limit)
+			}
+			state, _ = storage.Inspect(ctx, key, limit)
+			if !state.Blocked || state.BlockedFor <= 0 || state.BlockedFor > time.Minute {
+				t.Fatalf("Expected the key to be blocked for up to 1m, got %+v", state)
+			}
+
+			if err := storage.Reset(ctx, key); err != nil {
+				t.Fatalf("Reset should not fail: %v", err)
+			}
+			state, _ = storage.Inspect(ctx, key, limit)
+			if state.Count != 0 || state.Blocked {
+				t.Fatalf("Expected the key to be reset, got %+v", state)
+			}
+			if result, _ := storage.CheckLimit(ctx, key, limit); !result.Allowed {
+				t.Fatal("Request after reset should be allowed")
+			}
+		})
+	}
+}
+
+func TestMemoryStorageListBlocked(t *testing.T) {
+	storage := NewMemoryStorage()
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	blocked, err := storage.ListBlocked(ctx)
+	if err != nil || len(blocked) != 0 {
+		t.Fatalf("Expected no blocked keys, got %v %v", blocked, err)
+	}
+
+	storage.Block(ctx, "ip:10.0.0.1", time.Minute)
+	storage.Block(ctx, "token:abc123", time.Hour)
+	storage.CheckLimit(ctx, "ip:10.0.0.2", Limit{Rate: 1, Window: time.Minute})
+
+	blocked, err = storage.ListBlocked(ctx)
+	if err != nil {
+		t.Fatalf("ListBlocked should not fail: %v", err)
+	}
+	ttls := make(map[string]time.Duration)
+	for _, key := range blocked {
+		ttls[key.Key] = key.TTL
+	}
+	if len(ttls) != 2 || ttls["ip:10.0.0.1"] <= 0 || ttls["ip:10.0.0.1"] > time.Minute || ttls["token:abc123"] <= time.Minute {
+		t.Fatalf("Unexpected blocked keys: %v", blocked)
+	}
+
+	if err := storage.Unblock(ctx, "ip:10.0.0.1"); err != nil {
+		t.Fatalf("Unblock should not fail: %v", err)
+	}
+	if isBlocked, _, _ := storage.IsBlocked(ctx, "ip:10.0.0.1"); isBlocked {
+		t.Fatal("Key should not be blocked after Unblock")
+	}
+	if blocked, _ := storage.ListBlocked(ctx); len(blocked) != 1 || blocked[0].Key != "token:abc123" {
+		t.Fatalf("Expected only token:abc123 to be blocked, got %v", blocked)
+	}
+}
+
+func TestMemoryStorageRemoveExpired(t *testing.T) {
+	storage := NewMemoryStorage()
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	algorithms := []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			hourly := Limit{Algorithm: algorithm, Rate: 3, Window: time.Hour}
+			secondly := Limit{Algorithm: algorithm, Rate: 3, Window: time.Second}
+			hourlyKey, secondlyKey := "hourly:"+string(algorithm), "secondly:"+string(algorithm)
+			for i := 0; i < 2; i++ {
+				storage.CheckLimit(ctx, hourlyKey, hourly)
+				storage.CheckLimit(ctx, secondlyKey, secondly)
+			}
+
+			// Entries are kept as long as their own window needs them, however
+			// long that is: until the fixed window ends and the bucket refills
+			now := time.Now()
+			check := now.Add(20 * time.Minute)
+			if end := windowStart(time.Hour, now).Add(time.Hour - time.Millisecond); end.Before(check) {
+				check = end
+			}
+			storage.removeExpired(check)
+			if state, _ := storage.Inspect(ctx, hourlyKey, hourly); state.Count != 2 {
+				t.Fatalf("Expected the hourly key to keep its 2 requests, got %+v", state)
+			}
+
+			storage.removeExpired(now.Add(3 * time.Second))
+			if _, exists := storage.requests[secondlyKey]; exists {
+				t.Error("Expected the sliding log of the 1s window to be removed")
+			}
+			if _, exists := storage.buckets[secondlyKey]; exists {
+				t.Error("Expected the token bucket of the 1s window to be removed")
+			}
+			if _, exists := storage.windows[string(algorithm)+":"+secondlyKey]; exists {
+				t.Error("Expected the window counter of the 1s window to be removed")
+			}
+
+			storage.removeExpired(now.Add(3 * time.Hour))
+			if state, _ := storage.Inspect(ctx, hourlyKey, hourly); state.Count != 0 {
+				t.Fatalf("Expected the hourly key to be removed, got %+v", state)
+			}
+		})
+	}
+}
+
 func BenchmarkMemoryStorage(b *testing.B) {
 	storage := NewMemoryStorage()
 	defer storage.Close()
@@ -3,7 +3,9 @@ package storage
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"math/rand/v2"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,110 +13,162 @@ import (
 
 // RedisStorage implements the Storage interface using Redis
 type RedisStorage struct {
-	client *redis.Client
+	client  redis.UniversalClient
+	prefix  string
+	cluster bool // wrap the keys in hash tags
 }
 
-// NewRedisStorage creates a new Redis storage instance
+// NewRedisStorage creates a new Redis storage instance connected to a single node
 func NewRedisStorage(addr, password string, db int) (*RedisStorage, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-	})
+	return NewRedisStorageWithOptions(RedisOptions{Addrs: []string{addr}, Password: password, DB: db})
+}
+
+// NewRedisStorageWithOptions creates a new Redis storage instance for a
+// standalone node, a Sentinel setup or a cluster
+func NewRedisStorageWithOptions(opts RedisOptions) (*RedisStorage, error) {
+	r, err := OpenRedisStorage(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := client.Ping(ctx).Err(); err != nil {
+	if err := r.Health(ctx); err != nil {
+		r.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisStorage{client: client}, nil
+	return r, nil
 }
 
-// CheckRateLimit implements the Storage interface
-func (r *RedisStorage) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (*RateLimitResult, error) {
-	// Check if key is blocked first
-	blocked, retryAfter, err := r.IsBlocked(ctx, key)
+// OpenRedisStorage creates a Redis storage without testing the connection, for
+// callers that handle an unreachable Redis themselves, such as a CircuitBreaker.
+// The client connects on the first command.
+func OpenRedisStorage(opts RedisOptions) (*RedisStorage, error) {
+	client, err := newRedisClient(opts)
 	if err != nil {
 		return nil, err
 	}
-	if blocked {
-		return &RateLimitResult{
-			Allowed:    false,
-			Limit:      limit,
-			Remaining:  0,
-			RetryAfter: retryAfter,
-		}, nil
+	return &RedisStorage{client: client, prefix: opts.KeyPrefix, cluster: opts.Mode == RedisCluster}, nil
+}
+
+// CheckRateLimit implements the Storage interface
+func (r *RedisStorage) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (*RateLimitResult, error) {
+	return r.CheckLimit(ctx, key, Limit{Rate: limit, Window: window, BlockDuration: blockDuration})
+}
+
+// CheckLimit implements the Storage interface. The whole decision runs in a
+// single Lua script, so it is atomic across every instance sharing Redis.
+func (r *RedisStorage) CheckLimit(ctx context.Context, key string, limit Limit) (*RateLimitResult, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
 	}
 
-	// Use sliding window rate limiting with Redis
-	now := time.Now()
-	windowStart := now.Add(-window)
+	keys := []string{r.stateKey(key, limit.algorithm()), r.blockKey(key)}
+	args := []interface{}{limit.Rate, limit.Window.Microseconds(), limit.BlockDuration.Microseconds()}
 
-	// Redis pipeline for atomic operations
-	pipe := r.client.Pipeline()
+	var script *redis.Script
+	switch limit.algorithm() {
+	case AlgorithmTokenBucket:
+		script = tokenBucketScript
+		args = append(args, limit.burst())
+	case AlgorithmFixedWindow:
+		script = fixedWindowScript
+	case AlgorithmSlidingWindow:
+		script = slidingWindowScript
+	default:
+		script = slidingLogScript
+		// Requests in the same microsecond still need distinct members
+		args = append(args, fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Uint64()))
+	}
 
-	// Remove old entries (outside the window)
-	countKey := fmt.Sprintf("rate_limit:%s", key)
-	pipe.ZRemRangeByScore(ctx, countKey, "0", strconv.FormatInt(windowStart.UnixNano(), 10))
+	values, err := script.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
 
-	// Count current requests in window
-	pipe.ZCard(ctx, countKey)
+	// The script already accounts for blocks, old and new, in the retry
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Rate,
+		Remaining:  max(int(values[1]), 0),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+	}, nil
+}
 
-	// Add current request
-	pipe.ZAdd(ctx, countKey, redis.Z{
-		Score:  float64(now.UnixNano()),
-		Member: now.UnixNano(),
-	})
+// Inspect implements the Storage interface. It reads the state in a script
+// too, so it uses the same clock as the checks.
+func (r *RedisStorage) Inspect(ctx context.Context, key string, limit Limit) (*KeyState, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
 
-	// Set expiration for the key
-	pipe.Expire(ctx, countKey, window*2) // Keep data a bit longer than window
+	keys := []string{r.stateKey(key, limit.algorithm()), r.blockKey(key)}
+	args := []interface{}{limit.Rate, limit.Window.Microseconds(), limit.BlockDuration.Microseconds(), string(limit.algorithm()), limit.burst()}
 
-	// Execute pipeline
-	results, err := pipe.Exec(ctx)
+	values, err := inspectScript.Run(ctx, r.client, keys, args...).Int64Slice()
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute Redis pipeline: %w", err)
+		return nil, fmt.Errorf("failed to inspect key: %w", err)
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected inspect script result: %v", values)
 	}
 
-	// Get count of requests in current window (before adding the new one)
-	currentCount := int(results[1].(*redis.IntCmd).Val())
+	return &KeyState{
+		Count:      int(values[0]),
+		Remaining:  int(values[1]),
+		Blocked:    values[2] > 0,
+		BlockedFor: time.Duration(values[2]) * time.Microsecond,
+	}, nil
+}
 
-	result := &RateLimitResult{
-		Limit:     limit,
-		Remaining: limit - currentCount - 1, // -1 for the current request
+// Reset implements the Storage interface
+func (r *RedisStorage) Reset(ctx context.Context, key string) error {
+	keys := []string{r.blockKey(key)}
+	for _, algorithm := range []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow} {
+		keys = append(keys, r.stateKey(key, algorithm))
 	}
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to reset key: %w", err)
+	}
+	return nil
+}
 
-	if currentCount >= limit {
-		// Rate limit exceeded, block the key
-		result.Allowed = false
-		result.Remaining = 0
-		result.RetryAfter = blockDuration
-
-		// Block the key
-		if err := r.Block(ctx, key, blockDuration); err != nil {
-			return nil, fmt.Errorf("failed to block key: %w", err)
-		}
+// The Redis keys are <prefix>rate_limit:<key>, <prefix>rate_limit:<algorithm>:<key>
+// and <prefix>blocked:<key>. The sliding log has no algorithm in its key. In a
+// cluster the rate limit key is wrapped in a hash tag, {<key>}, so the state
+// and the block of a key live in the same slot, as the scripts require. Other
+// modes keep the original layout, so existing counters and blocks still apply.
 
-		// Remove the request we just added since it's not allowed
-		pipe = r.client.Pipeline()
-		pipe.ZRem(ctx, countKey, now.UnixNano())
-		_, _ = pipe.Exec(ctx)
-	} else {
-		result.Allowed = true
-		if result.Remaining < 0 {
-			result.Remaining = 0
-		}
+// stateKey is the Redis key holding the state of an algorithm
+func (r *RedisStorage) stateKey(key string, algorithm Algorithm) string {
+	if algorithm == AlgorithmSlidingLog {
+		return fmt.Sprintf("%srate_limit:%s", r.prefix, r.hashTag(key))
 	}
+	return fmt.Sprintf("%srate_limit:%s:%s", r.prefix, algorithm, r.hashTag(key))
+}
+
+// blockKey is the Redis key marking a key as blocked
+func (r *RedisStorage) blockKey(key string) string {
+	return fmt.Sprintf("%sblocked:%s", r.prefix, r.hashTag(key))
+}
 
-	return result, nil
+// hashTag wraps the key in a hash tag in cluster mode
+func (r *RedisStorage) hashTag(key string) string {
+	if r.cluster {
+		return "{" + key + "}"
+	}
+	return key
 }
 
 // IsBlocked checks if a key is currently blocked
 func (r *RedisStorage) IsBlocked(ctx context.Context, key string) (bool, time.Duration, error) {
-	blockKey := fmt.Sprintf("blocked:%s", key)
-	ttl, err := r.client.TTL(ctx, blockKey).Result()
+	ttl, err := r.client.TTL(ctx, r.blockKey(key)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return false, 0, nil
@@ -131,14 +185,80 @@ func (r *RedisStorage) IsBlocked(ctx context.Context, key string) (bool, time.Du
 
 // Block blocks a key for the specified duration
 func (r *RedisStorage) Block(ctx context.Context, key string, duration time.Duration) error {
-	blockKey := fmt.Sprintf("blocked:%s", key)
-	err := r.client.Set(ctx, blockKey, "1", duration).Err()
+	err := r.client.Set(ctx, r.blockKey(key), "1", duration).Err()
 	if err != nil {
 		return fmt.Errorf("failed to block key: %w", err)
 	}
 	return nil
 }
 
+// Unblock removes the block of a key
+func (r *RedisStorage) Unblock(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.blockKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to unblock key: %w", err)
+	}
+	return nil
+}
+
+// ListBlocked returns every blocked key. It walks the block keys with SCAN,
+// so it doesn't stall Redis, and reads their TTLs in one round trip. In a
+// cluster every master is scanned.
+func (r *RedisStorage) ListBlocked(ctx context.Context) ([]BlockedKey, error) {
+	blockPrefix := r.prefix + "blocked:"
+	pattern := globEscaper.Replace(blockPrefix) + "*"
+
+	var mu sync.Mutex
+	var blockKeys []string
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			blockKeys = append(blockKeys, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, r.client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked keys: %w", err)
+	}
+
+	pipe := r.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(blockKeys))
+	for i, blockKey := range blockKeys {
+		ttls[i] = pipe.PTTL(ctx, blockKey)
+	}
+	if len(blockKeys) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read block TTLs: %w", err)
+		}
+	}
+
+	var blocked []BlockedKey
+	for i, blockKey := range blockKeys {
+		// The key may have expired between SCAN and PTTL
+		if ttl := ttls[i].Val(); ttl > 0 {
+			key := strings.TrimPrefix(blockKey, blockPrefix)
+			if r.cluster {
+				key = strings.TrimSuffix(strings.TrimPrefix(key, "{"), "}")
+			}
+			blocked = append(blocked, BlockedKey{Key: key, TTL: ttl})
+		}
+	}
+	return blocked, nil
+}
+
+// globEscaper escapes the SCAN pattern characters of a key prefix
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
 // Close closes the Redis connection
 func (r *RedisStorage) Close() error {
 	return r.client.Close()
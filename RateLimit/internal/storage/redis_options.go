@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisMode selects how RedisStorage finds the Redis servers
+type RedisMode string
+
+const (
+	// RedisStandalone connects to a single Redis node
+	RedisStandalone RedisMode = "standalone"
+	// RedisSentinel asks the Sentinels for the current master and follows failovers
+	RedisSentinel RedisMode = "sentinel"
+	// RedisCluster spreads the keys over the slots of a Redis Cluster
+	RedisCluster RedisMode = "cluster"
+)
+
+// ParseRedisMode validates a Redis mode name. An empty name selects standalone.
+func ParseRedisMode(name string) (RedisMode, error) {
+	mode := RedisMode(strings.ToLower(strings.TrimSpace(name)))
+	switch mode {
+	case "":
+		return RedisStandalone, nil
+	case RedisStandalone, RedisSentinel, RedisCluster:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown Redis mode %q (use standalone, sentinel or cluster)", name)
+	}
+}
+
+// RedisOptions configures the connection of RedisStorage. Zero values use the
+// go-redis defaults.
+type RedisOptions struct {
+	Mode             RedisMode
+	Addrs            []string // the node, the Sentinels or the cluster seed nodes
+	MasterName       string   // Sentinel master name
+	Password         string
+	SentinelPassword string
+	DB               int         // ignored in cluster mode
+	TLS              *tls.Config // nil disables TLS
+	PoolSize         int         // connections per node
+	DialTimeout      time.Duration
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+
+	// KeyPrefix namespaces every key, so several services can share one Redis
+	KeyPrefix string
+}
+
+// newRedisClient creates the client for the mode. It does not connect yet.
+func newRedisClient(opts RedisOptions) (redis.UniversalClient, error) {
+	if len(opts.Addrs) == 0 {
+		return nil, fmt.Errorf("no Redis address")
+	}
+
+	universal := &redis.UniversalOptions{
+		Addrs:            opts.Addrs,
+		MasterName:       opts.MasterName,
+		Password:         opts.Password,
+		SentinelPassword: opts.SentinelPassword,
+		DB:               opts.DB,
+		TLSConfig:        opts.TLS,
+		PoolSize:         opts.PoolSize,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+	}
+
+	switch opts.Mode {
+	case RedisStandalone, "":
+		if len(opts.Addrs) > 1 {
+			return nil, fmt.Errorf("standalone mode takes a single Redis address, got %d", len(opts.Addrs))
+		}
+		return redis.NewClient(universal.Simple()), nil
+	case RedisSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("sentinel mode needs the master name")
+		}
+		return redis.NewFailoverClient(universal.Failover()), nil
+	case RedisCluster:
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("cluster mode only supports DB 0, got %d", opts.DB)
+		}
+		return redis.NewClusterClient(universal.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", opts.Mode)
+	}
+}
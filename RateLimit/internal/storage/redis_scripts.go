@@ -0,0 +1,172 @@
+package storage
+
+import "github.com/redis/go-redis/v9"
+
+// The rate limit scripts run the whole check atomically on the Redis server,
+// so concurrent requests from several instances can't race past the limit.
+// They use the server clock, in microseconds, so every instance agrees on
+// the windows.
+//
+// KEYS[1] is the state of the limit and KEYS[2] the block key.
+// ARGV[1] is the rate, ARGV[2] the window and ARGV[3] the block duration,
+// both in microseconds. Each script returns {allowed, remaining, retry after
+// in microseconds}.
+
+// scriptPrelude returns the current request when the key is blocked, and
+// defines deny, which blocks the key when there is a block duration
+const scriptPrelude = `
+local rate = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local block = tonumber(ARGV[3])
+
+local blocked = redis.call('PTTL', KEYS[2])
+if blocked > 0 then
+	return {0, 0, blocked * 1000}
+end
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local function deny(retry)
+	if block > 0 then
+		redis.call('SET', KEYS[2], '1', 'PX', math.ceil(block / 1000))
+		retry = block
+	end
+	return {0, 0, math.ceil(retry)}
+end
+`
+
+// slidingLogScript keeps one sorted set member per request inside the window.
+// ARGV[4] is a unique member for the current request.
+var slidingLogScript = redis.NewScript(scriptPrelude + `
+-- Lua turns numbers into strings with 14 digits, not enough for microseconds
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', string.format('%.0f', now - window))
+local count = redis.call('ZCARD', KEYS[1])
+if count >= rate then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	local retry = window
+	if oldest[2] then
+		retry = tonumber(oldest[2]) + window - now
+	end
+	return deny(retry)
+end
+
+redis.call('ZADD', KEYS[1], string.format('%.0f', now), ARGV[4])
+redis.call('PEXPIRE', KEYS[1], math.ceil(window * 2 / 1000))
+return {1, rate - count - 1, 0}
+`)
+
+// tokenBucketScript stores the tokens left and the last refill in a hash.
+// ARGV[4] is the bucket capacity.
+var tokenBucketScript = redis.NewScript(scriptPrelude + `
+local capacity = tonumber(ARGV[4])
+local perMicrosecond = rate / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = capacity
+if state[1] then
+	tokens = math.min(capacity, tonumber(state[1]) + math.max(0, now - tonumber(state[2])) * perMicrosecond)
+end
+
+local allowed = tokens >= 1
+if allowed then
+	tokens = tokens - 1
+end
+
+-- A full bucket is the same as no bucket, so the key expires once it refills
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.6f', tokens), 'last', string.format('%.0f', now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity / perMicrosecond) / 1000) + 1000)
+
+if not allowed then
+	return deny((1 - tokens) / perMicrosecond)
+end
+return {1, math.floor(tokens), 0}
+`)
+
+// windowScriptPrelude moves the counter hash to the window that contains now,
+// keeping the count of the previous window
+const windowScriptPrelude = scriptPrelude + `
+local start = now - (now % window)
+local state = redis.call('HMGET', KEYS[1], 'start', 'count')
+local count = 0
+local previous = 0
+if state[1] then
+	local lastStart = tonumber(state[1])
+	if lastStart == start then
+		count = tonumber(state[2])
+		previous = tonumber(redis.call('HGET', KEYS[1], 'previous') or '0')
+	elseif lastStart + window == start then
+		previous = tonumber(state[2])
+	end
+end
+`
+
+// fixedWindowScript counts the requests of each window aligned to the clock
+var fixedWindowScript = redis.NewScript(windowScriptPrelude + `
+if count >= rate then
+	return deny(start + window - now)
+end
+
+redis.call('HSET', KEYS[1], 'start', string.format('%.0f', start), 'count', count + 1, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], math.ceil(window * 2 / 1000))
+return {1, rate - count - 1, 0}
+`)
+
+// slidingWindowScript weights the previous window's count by its overlap
+var slidingWindowScript = redis.NewScript(windowScriptPrelude + `
+local estimate = previous * (1 - (now - start) / window) + count
+if estimate >= rate then
+	return deny(start + window - now)
+end
+
+redis.call('HSET', KEYS[1], 'start', string.format('%.0f', start), 'count', count + 1, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], math.ceil(window * 2 / 1000))
+return {1, rate - math.ceil(estimate) - 1, 0}
+`)
+
+// inspectScript reports the state of a key without counting a request.
+// ARGV[4] is the algorithm and ARGV[5] the token bucket capacity. It returns
+// {requests counted, remaining, block time left in microseconds}.
+var inspectScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local algorithm = ARGV[4]
+
+local blocked = math.max(redis.call('PTTL', KEYS[2]), 0) * 1000
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local count = 0
+if algorithm == 'token_bucket' then
+	local capacity = tonumber(ARGV[5])
+	local perMicrosecond = rate / window
+	local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+	local tokens = capacity
+	if state[1] then
+		tokens = math.min(capacity, tonumber(state[1]) + math.max(0, now - tonumber(state[2])) * perMicrosecond)
+	end
+	return {capacity - math.floor(tokens), math.floor(tokens), blocked}
+elseif algorithm == 'fixed_window' or algorithm == 'sliding_window' then
+	local start = now - (now % window)
+	local state = redis.call('HMGET', KEYS[1], 'start', 'count', 'previous')
+	local current = 0
+	local previous = 0
+	if state[1] then
+		local lastStart = tonumber(state[1])
+		if lastStart == start then
+			current = tonumber(state[2])
+			previous = tonumber(state[3] or '0')
+		elseif lastStart + window == start then
+			previous = tonumber(state[2])
+		end
+	end
+	count = current
+	if algorithm == 'sliding_window' then
+		count = math.ceil(previous * (1 - (now - start) / window) + current)
+	end
+else
+	count = redis.call('ZCOUNT', KEYS[1], '(' .. string.format('%.0f', now - window), '+inf')
+end
+return {count, math.max(rate - count, 0), blocked}
+`)
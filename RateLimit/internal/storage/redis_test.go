@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	storage, err := NewRedisStorage(server.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("Failed to connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	return storage, server
+}
+
+func TestRedisStorageRateLimit(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	algorithms := []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			key := "test:" + string(algorithm)
+			limit := Limit{Algorithm: algorithm, Rate: 5, Window: time.Hour, BlockDuration: time.Minute}
+
+			for i := 0; i < limit.Rate; i++ {
+				result, err := storage.CheckLimit(ctx, key, limit)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !result.Allowed {
+					t.Fatalf("Request %d should be allowed", i+1)
+				}
+				if result.Remaining != limit.Rate-i-1 {
+					t.Errorf("Request %d: expected %d remaining, got %d", i+1, limit.Rate-i-1, result.Remaining)
+				}
+			}
+
+			result, err := storage.CheckLimit(ctx, key, limit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Allowed || result.RetryAfter != limit.BlockDuration {
+				t.Fatalf("6th request should be blocked for %v, got %+v", limit.BlockDuration, result)
+			}
+
+			blocked, retryAfter, err := storage.IsBlocked(ctx, key)
+			if err != nil {
+				t.Fatalf("Failed to check block status: %v", err)
+			}
+			if !blocked || retryAfter <= 0 {
+				t.Fatal("Key should be blocked after exceeding the limit")
+			}
+		})
+	}
+
+	t.Run("Retry after without block", func(t *testing.T) {
+		limit := Limit{Algorithm: AlgorithmFixedWindow, Rate: 1, Window: time.Hour}
+
+		storage.CheckLimit(ctx, "test:no-block", limit)
+		result, err := storage.CheckLimit(ctx, "test:no-block", limit)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > time.Hour {
+			t.Fatalf("Expected a denial until the end of the window, got %+v", result)
+		}
+		if blocked, _, _ := storage.IsBlocked(ctx, "test:no-block"); blocked {
+			t.Fatal("Key should not be blocked without a block duration")
+		}
+	})
+
+	t.Run("Existing block", func(t *testing.T) {
+		if err := storage.Block(ctx, "test:blocked", 30*time.Second); err != nil {
+			t.Fatalf("Failed to block key: %v", err)
+		}
+
+		result, err := storage.CheckRateLimit(ctx, "test:blocked", 10, time.Second, time.Minute)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > 30*time.Second {
+			t.Fatalf("Expected the remaining block as retry, got %+v", result)
+		}
+	})
+}
+
+func TestRedisStorageInspectReset(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	algorithms := []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			key := "inspect:" + string(algorithm)
+			limit := Limit{Algorithm: algorithm, Rate: 3, Window: time.Hour, BlockDuration: time.Minute}
+
+			state, err := storage.Inspect(ctx, key, limit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if state.Count != 0 || state.Remaining != 3 || state.Blocked {
+				t.Fatalf("Expected an empty key, got %+v", state)
+			}
+
+			for i := 0; i < 2; i++ {
+				storage.CheckLimit(ctx, key, limit)
+			}
+			state, _ = storage.Inspect(ctx, key, limit)
+			if state.Count != 2 || state.Remaining != 1 {
+				t.Fatalf("Expected 2 requests counted, got %+v", state)
+			}
+			// Inspecting does not count a request
+			state, _ = storage.Inspect(ctx, key, limit)
+			if state.Count != 2 {
+				t.Fatalf("Expected Inspect to leave the count at 2, got %d", state.Count)
+			}
+
+			for i := 0; i < 2; i++ {
+				storage.CheckLimit(ctx, key, limit)
+			}
+			state, _ = storage.Inspect(ctx, key, limit)
+			if !state.Blocked || state.BlockedFor <= 0 || state.BlockedFor > time.Minute {
+				t.Fatalf("Expected the key to be blocked for up to 1m, got %+v", state)
+			}
+
+			if err := storage.Reset(ctx, key); err != nil {
+				t.Fatalf("Reset should not fail: %v", err)
+			}
+			state, _ = storage.Inspect(ctx, key, limit)
+			if state.Count != 0 || state.Blocked {
+				t.Fatalf("Expected the key to be reset, got %+v", state)
+			}
+			if result, _ := storage.CheckLimit(ctx, key, limit); !result.Allowed {
+				t.Fatal("Request after reset should be allowed")
+			}
+		})
+	}
+}
+
+func TestRedisStorageListBlocked(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	blocked, err := storage.ListBlocked(ctx)
+	if err != nil || len(blocked) != 0 {
+		t.Fatalf("Expected no blocked keys, got %v %v", blocked, err)
+	}
+
+	storage.Block(ctx, "ip:10.0.0.1", time.Minute)
+	storage.Block(ctx, "token:abc123", time.Hour)
+	storage.CheckLimit(ctx, "ip:10.0.0.2", Limit{Rate: 1, Window: time.Minute})
+
+	blocked, err = storage.ListBlocked(ctx)
+	if err != nil {
+		t.Fatalf("ListBlocked should not fail: %v", err)
+	}
+	ttls := make(map[string]time.Duration)
+	for _, key := range blocked {
+		ttls[key.Key] = key.TTL
+	}
+	if len(ttls) != 2 || ttls["ip:10.0.0.1"] <= 0 || ttls["ip:10.0.0.1"] > time.Minute || ttls["token:abc123"] <= time.Minute {
+		t.Fatalf("Unexpected blocked keys: %v", blocked)
+	}
+
+	if err := storage.Unblock(ctx, "ip:10.0.0.1"); err != nil {
+		t.Fatalf("Unblock should not fail: %v", err)
+	}
+	if isBlocked, _, _ := storage.IsBlocked(ctx, "ip:10.0.0.1"); isBlocked {
+		t.Fatal("Key should not be blocked after Unblock")
+	}
+	if blocked, _ := storage.ListBlocked(ctx); len(blocked) != 1 || blocked[0].Key != "token:abc123" {
+		t.Fatalf("Expected only token:abc123 to be blocked, got %v", blocked)
+	}
+}
+
+func TestRedisStorageKeyPrefix(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	newStorage := func(prefix string) *RedisStorage {
+		storage, err := NewRedisStorageWithOptions(RedisOptions{Addrs: []string{server.Addr()}, KeyPrefix: prefix})
+		if err != nil {
+			t.Fatalf("Failed to connect to miniredis: %v", err)
+		}
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	}
+	checkout, search := newStorage("checkout:"), newStorage("search[1]:")
+
+	limit := Limit{Algorithm: AlgorithmFixedWindow, Rate: 1, Window: time.Hour, BlockDuration: time.Minute}
+	checkout.CheckLimit(ctx, "ip:10.0.0.1", limit)
+	checkout.CheckLimit(ctx, "ip:10.0.0.1", limit)
+
+	for _, key := range []string{"checkout:rate_limit:fixed_window:ip:10.0.0.1", "checkout:blocked:ip:10.0.0.1"} {
+		if !server.Exists(key) {
+			t.Errorf("Expected key %s, got %v", key, server.Keys())
+		}
+	}
+
+	// Services with different prefixes don't share keys
+	if result, _ := search.CheckLimit(ctx, "ip:10.0.0.1", limit); !result.Allowed {
+		t.Fatal("Expected another prefix to have its own counter")
+	}
+	if blocked, _ := search.ListBlocked(ctx); len(blocked) != 0 {
+		t.Fatalf("Expected no blocked keys under the search prefix, got %v", blocked)
+	}
+	if blocked, _ := checkout.ListBlocked(ctx); len(blocked) != 1 || blocked[0].Key != "ip:10.0.0.1" {
+		t.Fatalf("Expected ip:10.0.0.1 blocked under the checkout prefix, got %v", blocked)
+	}
+
+	if err := checkout.Reset(ctx, "ip:10.0.0.1"); err != nil {
+		t.Fatalf("Reset should not fail: %v", err)
+	}
+	if keys := server.Keys(); len(keys) != 1 || !strings.HasPrefix(keys[0], "search[1]:") {
+		t.Errorf("Expected only the search key to remain, got %v", keys)
+	}
+}
+
+func TestRedisStorageKeyLayout(t *testing.T) {
+	t.Run("Standalone keeps the original layout", func(t *testing.T) {
+		storage, server := newTestRedisStorage(t)
+		ctx := context.Background()
+
+		// Keys written before the key prefix and cluster support still apply
+		server.Set("blocked:ip:10.0.0.1", "1")
+		server.SetTTL("blocked:ip:10.0.0.1", time.Minute)
+		if result, _ := storage.CheckLimit(ctx, "ip:10.0.0.1", Limit{Rate: 10, Window: time.Second}); result.Allowed {
+			t.Fatal("Expected the existing block to apply")
+		}
+		if blocked, _ := storage.ListBlocked(ctx); len(blocked) != 1 || blocked[0].Key != "ip:10.0.0.1" {
+			t.Fatalf("Expected the existing block to be listed, got %v", blocked)
+		}
+
+		storage.CheckLimit(ctx, "ip:10.0.0.2", Limit{Rate: 10, Window: time.Second})
+		if !server.Exists("rate_limit:ip:10.0.0.2") {
+			t.Errorf("Expected key rate_limit:ip:10.0.0.2, got %v", server.Keys())
+		}
+	})
+
+	t.Run("Cluster wraps the key in a hash tag", func(t *testing.T) {
+		// The hash tag keeps the state and the block of a key in the same slot
+		storage := &RedisStorage{prefix: "checkout:", cluster: true}
+		if key := storage.stateKey("ip:10.0.0.1", AlgorithmFixedWindow); key != "checkout:rate_limit:fixed_window:{ip:10.0.0.1}" {
+			t.Errorf("Unexpected state key %s", key)
+		}
+		if key := storage.stateKey("ip:10.0.0.1", AlgorithmSlidingLog); key != "checkout:rate_limit:{ip:10.0.0.1}" {
+			t.Errorf("Unexpected sliding log key %s", key)
+		}
+		if key := storage.blockKey("ip:10.0.0.1"); key != "checkout:blocked:{ip:10.0.0.1}" {
+			t.Errorf("Unexpected block key %s", key)
+		}
+	})
+}
+
+func TestNewRedisClient(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     RedisOptions
+		expected string
+	}{
+		{"Standalone", RedisOptions{Addrs: []string{"localhost:6379"}}, "*redis.Client"},
+		{"Sentinel", RedisOptions{Mode: RedisSentinel, Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "mymaster"}, "*redis.Client"},
+		{"Cluster", RedisOptions{Mode: RedisCluster, Addrs: []string{"n1:6379", "n2:6379"}}, "*redis.ClusterClient"},
+		{"Single seed cluster", RedisOptions{Mode: RedisCluster, Addrs: []string{"n1:6379"}}, "*redis.ClusterClient"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := newRedisClient(tc.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer client.Close()
+			if got := fmt.Sprintf("%T", client); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+
+	t.Run("Connection options", func(t *testing.T) {
+		client, _ := newRedisClient(RedisOptions{
+			Addrs:        []string{"localhost:6379"},
+			TLS:          &tls.Config{ServerName: "redis.internal"},
+			PoolSize:     42,
+			DialTimeout:  time.Second,
+			ReadTimeout:  2 * time.Second,
+			WriteTimeout: 3 * time.Second,
+		})
+		defer client.Close()
+
+		opts := client.(*redis.Client).Options()
+		if opts.TLSConfig == nil || opts.TLSConfig.ServerName != "redis.internal" || opts.PoolSize != 42 ||
+			opts.DialTimeout != time.Second || opts.ReadTimeout != 2*time.Second || opts.WriteTimeout != 3*time.Second {
+			t.Errorf("Unexpected client options: %+v", opts)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for name, opts := range map[string]RedisOptions{
+			"No address":               {},
+			"Several standalone nodes": {Addrs: []string{"a:6379", "b:6379"}},
+			"Sentinel without master":  {Mode: RedisSentinel, Addrs: []string{"s1:26379"}},
+			"Cluster with DB":          {Mode: RedisCluster, Addrs: []string{"n1:6379"}, DB: 1},
+			"Unknown mode":             {Mode: "replica", Addrs: []string{"a:6379"}},
+		} {
+			if _, err := newRedisClient(opts); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestRedisStorageBlockExpires(t *testing.T) {
+	storage, server := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	if err := storage.Block(ctx, "test:ip", time.Minute); err != nil {
+		t.Fatalf("Failed to block key: %v", err)
+	}
+	server.FastForward(time.Minute)
+
+	blocked, _, err := storage.IsBlocked(ctx, "test:ip")
+	if err != nil {
+		t.Fatalf("Failed to check block status: %v", err)
+	}
+	if blocked {
+		t.Fatal("Key should no longer be blocked")
+	}
+}
+
+// TestRedisStorageConcurrency hammers a single key from many goroutines, as
+// concurrent requests across instances would. No algorithm may let more
+// requests through than the limit.
+func TestRedisStorageConcurrency(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	const workers = 20
+	const requestsPerWorker = 25
+
+	algorithms := []Algorithm{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmFixedWindow, AlgorithmSlidingWindow}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			limit := Limit{Algorithm: algorithm, Rate: 100, Window: time.Hour}
+
+			var allowed atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < requestsPerWorker; j++ {
+						result, err := storage.CheckLimit(ctx, "test:hammer:"+string(algorithm), limit)
+						if err != nil {
+							t.Errorf("Unexpected error: %v", err)
+							return
+						}
+						if result.Allowed {
+							allowed.Add(1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := allowed.Load(); got != int64(limit.Rate) {
+				t.Errorf("Expected exactly %d allowed requests, got %d", limit.Rate, got)
+			}
+		})
+	}
+}
+
+func TestRedisStorageHealth(t *testing.T) {
+	storage, server := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	if err := storage.Health(ctx); err != nil {
+		t.Fatalf("Health check should pass: %v", err)
+	}
+
+	server.Close()
+	if err := storage.Health(ctx); err == nil {
+		t.Fatal("Health check should fail with Redis down")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Assertion kinds, in the order they are reported
+const (
+	assertionStatus  = "status"
+	assertionBody    = "body"
+	assertionJSON    = "json"
+	assertionLatency = "latency"
+)
+
+var assertionKindOrder = []string{assertionStatus, assertionBody, assertionJSON, assertionLatency}
+
+var assertionKindLabels = map[string]string{
+	assertionStatus:  "Status inesperado",
+	assertionBody:    "Corpo da resposta",
+	assertionJSON:    "JSON path",
+	assertionLatency: "Latência acima do máximo",
+}
+
+// Assertions are the checks a response must pass to count as a success.
+// Without assertions only status 200 is accepted.
+type Assertions struct {
+	// Status lists the accepted status codes: exact ("201", or "0" for gRPC)
+	// or by class ("2xx")
+	Status    []string `yaml:"status" json:"status"`
+	Body      string   `yaml:"body" json:"body,omitempty"`
+	BodyRegex string   `yaml:"body_regex" json:"body_regex,omitempty"`
+	// JSON lists paths that must exist ("id") or have a value ("status=open")
+	JSON       []string      `yaml:"json" json:"json,omitempty"`
+	MaxLatency time.Duration `yaml:"max_latency" json:"max_latency_ns,omitempty"`
+
+	bodyRegex *regexp.Regexp
+}
+
+// defaultAssertions keeps the original definition of success: status 200
+var defaultAssertions = &Assertions{Status: []string{"200"}}
+
+// assertionError is the failure of one assertion
+type assertionError struct {
+	kind    string
+	message string
+}
+
+func (e *assertionError) Error() string {
+	return e.message
+}
+
+// compile validates the assertions and compiles the body regex
+func (a *Assertions) compile() error {
+	if len(a.Status) == 0 {
+		a.Status = []string{"200"}
+	}
+	for i, pattern := range a.Status {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if !isStatusPattern(pattern) {
+			return fmt.Errorf("status esperado %q inválido, use um código (200) ou uma classe (2xx)", pattern)
+		}
+		// Códigos exatos são normalizados, já que os códigos gRPC têm um ou dois dígitos
+		if code, err := strconv.Atoi(pattern); err == nil {
+			pattern = strconv.Itoa(code)
+		}
+		a.Status[i] = pattern
+	}
+	if a.BodyRegex != "" {
+		re, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("regex do corpo inválida: %w", err)
+		}
+		a.bodyRegex = re
+	}
+	for _, check := range a.JSON {
+		if path, _, _ := strings.Cut(check, "="); strings.TrimSpace(path) == "" {
+			return fmt.Errorf("verificação JSON %q sem path", check)
+		}
+	}
+	if a.MaxLatency < 0 {
+		return fmt.Errorf("latência máxima não pode ser negativa")
+	}
+	return nil
+}
+
+// isStatusPattern accepts an exact code of up to three digits, or a class of
+// three characters, each a digit or "x"
+func isStatusPattern(pattern string) bool {
+	if !strings.Contains(pattern, "x") {
+		return len(pattern) >= 1 && len(pattern) <= 3 && strings.Trim(pattern, "0123456789") == ""
+	}
+	if len(pattern) != 3 || pattern[0] == 'x' {
+		return false
+	}
+	for _, c := range pattern {
+		if c != 'x' && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func matchStatus(pattern string, statusCode int) bool {
+	if !strings.Contains(pattern, "x") {
+		return pattern == strconv.Itoa(statusCode)
+	}
+	code := fmt.Sprintf("%03d", statusCode)
+	for i := range pattern {
+		if pattern[i] != 'x' && pattern[i] != code[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// needsBody tells whether the response body has to be read for the checks
+func (a *Assertions) needsBody() bool {
+	return a.Body != "" || a.bodyRegex != nil || len(a.JSON) > 0
+}
+
+// check returns an *assertionError for the first assertion the response fails
+func (a *Assertions) check(result Result, resp response) error {
+	accepted := false
+	for _, pattern := range a.Status {
+		if matchStatus(pattern, result.StatusCode) {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		return &assertionError{assertionStatus, fmt.Sprintf("status %d fora de %s", result.StatusCode, strings.Join(a.Status, ","))}
+	}
+
+	if a.Body != "" && !bytes.Contains(resp.body, []byte(a.Body)) {
+		return &assertionError{assertionBody, fmt.Sprintf("corpo não contém %q", a.Body)}
+	}
+	if a.bodyRegex != nil && !a.bodyRegex.Match(resp.body) {
+		return &assertionError{assertionBody, fmt.Sprintf("corpo não corresponde a /%s/", a.BodyRegex)}
+	}
+
+	for _, check := range a.JSON {
+		path, expected, hasValue := strings.Cut(check, "=")
+		path = strings.TrimSpace(path)
+		value, err := jsonPath(resp.body, path)
+		if err != nil {
+			return &assertionError{assertionJSON, fmt.Sprintf("%s: %v", path, err)}
+		}
+		if hasValue && value != strings.TrimSpace(expected) {
+			return &assertionError{assertionJSON, fmt.Sprintf("%s = %q, esperado %q", path, value, strings.TrimSpace(expected))}
+		}
+	}
+
+	if a.MaxLatency > 0 && result.Duration > a.MaxLatency {
+		return &assertionError{assertionLatency, fmt.Sprintf("latência %v acima de %v", result.Duration.Round(time.Microsecond), a.MaxLatency)}
+	}
+
+	return nil
+}
+
+// descriptions lists the assertions in a readable form for the report
+func (a *Assertions) descriptions() []string {
+	descriptions := []string{"status " + strings.Join(a.Status, ",")}
+	if a.Body != "" {
+		descriptions = append(descriptions, fmt.Sprintf("corpo contém %q", a.Body))
+	}
+	if a.BodyRegex != "" {
+		descriptions = append(descriptions, fmt.Sprintf("corpo corresponde a /%s/", a.BodyRegex))
+	}
+	for _, check := range a.JSON {
+		if path, expected, hasValue := strings.Cut(check, "="); hasValue {
+			descriptions = append(descriptions, fmt.Sprintf("json %s = %s", strings.TrimSpace(path), strings.TrimSpace(expected)))
+		} else {
+			descriptions = append(descriptions, fmt.Sprintf("json %s existe", strings.TrimSpace(path)))
+		}
+	}
+	if a.MaxLatency > 0 {
+		descriptions = append(descriptions, fmt.Sprintf("latência <= %v", a.MaxLatency))
+	}
+	return descriptions
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAssertionsCompile(t *testing.T) {
+	tests := []struct {
+		name       string
+		assertions Assertions
+		wantErr    bool
+	}{
+		{"Default status", Assertions{}, false},
+		{"Status class", Assertions{Status: []string{"200", " 2XX "}}, false},
+		{"gRPC status", Assertions{Status: []string{"0", "14"}}, false},
+		{"Invalid status", Assertions{Status: []string{"2000"}}, true},
+		{"Short class", Assertions{Status: []string{"2x"}}, true},
+		{"Class without digit", Assertions{Status: []string{"xxx"}}, true},
+		{"Invalid regex", Assertions{BodyRegex: "("}, true},
+		{"JSON without path", Assertions{JSON: []string{"=1"}}, true},
+		{"Negative latency", Assertions{MaxLatency: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.assertions.compile()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAssertionsCheck(t *testing.T) {
+	body := []byte(`{"id":"abc","status":"open","items":[1,2]}`)
+
+	tests := []struct {
+		name       string
+		assertions Assertions
+		result     Result
+		wantKind   string
+	}{
+		{"Default accepts 200", Assertions{}, Result{StatusCode: 200}, ""},
+		{"Default rejects 201", Assertions{}, Result{StatusCode: 201}, assertionStatus},
+		{"Status class", Assertions{Status: []string{"2xx"}}, Result{StatusCode: 204}, ""},
+		{"Status list", Assertions{Status: []string{"200", "404"}}, Result{StatusCode: 404}, ""},
+		{"gRPC OK", Assertions{Status: []string{"0"}}, Result{StatusCode: 0}, ""},
+		{"gRPC code", Assertions{Status: []string{"0"}}, Result{StatusCode: 5}, assertionStatus},
+		{"Body contains", Assertions{Body: `"open"`}, Result{StatusCode: 200}, ""},
+		{"Body missing", Assertions{Body: "closed"}, Result{StatusCode: 200}, assertionBody},
+		{"Body regex", Assertions{BodyRegex: `"id":"[a-z]+"`}, Result{StatusCode: 200}, ""},
+		{"Body regex mismatch", Assertions{BodyRegex: `"id":\d+`}, Result{StatusCode: 200}, assertionBody},
+		{"JSON exists", Assertions{JSON: []string{"items.1"}}, Result{StatusCode: 200}, ""},
+		{"JSON value", Assertions{JSON: []string{"status=open"}}, Result{StatusCode: 200}, ""},
+		{"JSON wrong value", Assertions{JSON: []string{"status=closed"}}, Result{StatusCode: 200}, assertionJSON},
+		{"JSON missing path", Assertions{JSON: []string{"owner"}}, Result{StatusCode: 200}, assertionJSON},
+		{"Latency", Assertions{MaxLatency: 100 * time.Millisecond}, Result{StatusCode: 200, Duration: 50 * time.Millisecond}, ""},
+		{"Latency exceeded", Assertions{MaxLatency: 100 * time.Millisecond}, Result{StatusCode: 200, Duration: 150 * time.Millisecond}, assertionLatency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.assertions.compile(); err != nil {
+				t.Fatalf("Unexpected compile error: %v", err)
+			}
+			err := tt.assertions.check(tt.result, response{body: body})
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Errorf("Expected no failure, got %v", err)
+				}
+				return
+			}
+			failure, ok := err.(*assertionError)
+			if !ok || failure.kind != tt.wantKind {
+				t.Errorf("Expected %s failure, got %v", tt.wantKind, err)
+			}
+		})
+	}
+}
+
+func TestRunStressTestWithAssertions(t *testing.T) {
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusCreated)
+		// Uma em cada quatro respostas vem sem o campo id
+		if count%4 == 0 {
+			fmt.Fprint(w, `{"error":"unavailable"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%d}`, count)
+	}))
+	defer server.Close()
+
+	assertions := &Assertions{Status: []string{"2xx"}, JSON: []string{"id"}}
+	if err := assertions.compile(); err != nil {
+		t.Fatal(err)
+	}
+	threshold, err := parseThreshold("errors<10%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := runStressTest(Config{
+		URL:         server.URL,
+		Requests:    20,
+		Concurrency: 1,
+		Assertions:  assertions,
+		Thresholds:  []Threshold{threshold},
+	})
+
+	if report.SuccessCount != 15 {
+		t.Errorf("Expected 15 successful requests, got %d", report.SuccessCount)
+	}
+	if report.StatusCounts[201] != 20 {
+		t.Errorf("Expected 20 responses with status 201, got %d", report.StatusCounts[201])
+	}
+	if len(report.AssertionFailures) != 1 || report.AssertionFailures[0].Kind != assertionJSON || report.AssertionFailures[0].Count != 5 {
+		t.Errorf("Expected 5 json assertion failures, got %+v", report.AssertionFailures)
+	}
+	if len(report.Assertions) != 2 {
+		t.Errorf("Expected 2 assertion descriptions, got %v", report.Assertions)
+	}
+	if report.ThresholdsPassed() {
+		t.Errorf("Expected errors<10%% to fail with 25%% of errors: %+v", report.Thresholds)
+	}
+}
+
+func TestParseFlagsAssertionsAndThresholds(t *testing.T) {
+	config := parseFlagsFromArgs([]string{
+		"--url=http://localhost:8080",
+		"--requests=10",
+		"--expect-status=200,2xx",
+		"--expect-json=id",
+		"--expect-json=status=open",
+		"--max-latency=500ms",
+		"--threshold=p95<300ms",
+		"--threshold=errors<1%",
+	})
+
+	if config.Assertions == nil {
+		t.Fatal("Expected assertions to be set")
+	}
+	if len(config.Assertions.Status) != 2 || len(config.Assertions.JSON) != 2 || config.Assertions.MaxLatency != 500*time.Millisecond {
+		t.Errorf("Unexpected assertions: %+v", config.Assertions)
+	}
+	if len(config.Thresholds) != 2 || config.Thresholds[1].Metric != "errors" {
+		t.Errorf("Unexpected thresholds: %+v", config.Thresholds)
+	}
+
+	// Sem parâmetros de verificação o padrão (status 200) é usado
+	config = parseFlagsFromArgs([]string{"--url=http://localhost:8080", "--requests=10"})
+	if config.Assertions != nil || config.Thresholds != nil {
+		t.Errorf("Expected no assertions or thresholds, got %+v %+v", config.Assertions, config.Thresholds)
+	}
+}
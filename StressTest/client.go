@@ -0,0 +1,351 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultRequestTimeout is the per-request timeout when none is configured
+const defaultRequestTimeout = 30 * time.Second
+
+// minIdleConnsPerHost keeps connections reusable in the open model, where
+// the number of requests in flight is not bounded by a worker count
+const minIdleConnsPerHost = 100
+
+// HTTP versions accepted by --http-version
+const (
+	httpVersionAuto = ""
+	httpVersion11   = "1.1"
+	httpVersion2    = "2"
+)
+
+// Connection phases, in the order they are reported
+const (
+	phaseDNS     = "dns"
+	phaseConnect = "connect"
+	phaseTLS     = "tls"
+	phaseTTFB    = "ttfb"
+)
+
+var phaseOrder = []string{phaseDNS, phaseConnect, phaseTLS, phaseTTFB}
+
+var phaseLabels = map[string]string{
+	phaseDNS:     "DNS",
+	phaseConnect: "Conexão TCP",
+	phaseTLS:     "Handshake TLS",
+	phaseTTFB:    "Primeiro byte (TTFB)",
+}
+
+// ClientOptions tunes the HTTP client shared by the workers of a run.
+// The zero value behaves like the default Go client with a 30s timeout.
+type ClientOptions struct {
+	Timeout            time.Duration `json:"timeout_ns,omitempty"`
+	DisableKeepAlives  bool          `json:"disable_keep_alives,omitempty"`
+	MaxConnsPerHost    int           `json:"max_conns_per_host,omitempty"`
+	HTTPVersion        string        `json:"http_version,omitempty"`
+	InsecureSkipVerify bool          `json:"insecure_skip_verify,omitempty"`
+
+	maxIdleConnsPerHost int
+}
+
+func isValidHTTPVersion(version string) bool {
+	switch version {
+	case httpVersionAuto, httpVersion11, httpVersion2:
+		return true
+	}
+	return false
+}
+
+// clientOptions returns the client options of the run, keeping one idle
+// connection per worker so keep-alive connections are actually reused
+func (c Config) clientOptions() ClientOptions {
+	options := c.Client
+	workers := c.Concurrency
+	for _, stage := range c.Stages {
+		if stage.Target > workers {
+			workers = stage.Target
+		}
+	}
+	if workers < minIdleConnsPerHost {
+		workers = minIdleConnsPerHost
+	}
+	options.maxIdleConnsPerHost = workers
+	return options
+}
+
+func newHTTPClient(options ClientOptions) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = options.DisableKeepAlives
+	transport.MaxConnsPerHost = options.MaxConnsPerHost
+	if options.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConns = 0
+		transport.MaxIdleConnsPerHost = options.maxIdleConnsPerHost
+	}
+	if options.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	var roundTripper http.RoundTripper = transport
+	switch options.HTTPVersion {
+	case httpVersion11:
+		// Um TLSNextProto vazio (e não nil) desativa o HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	case httpVersion2:
+		transport.ForceAttemptHTTP2 = true
+		roundTripper = requireHTTP2{transport}
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	return &http.Client{Transport: roundTripper, Timeout: timeout}
+}
+
+// requireHTTP2 fails the responses that were not served over HTTP/2, since
+// the transport silently falls back to HTTP/1.1 when the server does not
+// negotiate it
+type requireHTTP2 struct {
+	transport http.RoundTripper
+}
+
+func (r requireHTTP2) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("servidor respondeu com %s, esperado HTTP/2 (--http-version=2)", resp.Proto)
+	}
+	return resp, nil
+}
+
+// Timings holds the connection-level phases of a request, measured with
+// httptrace. Phases that did not happen, such as DNS, connect and TLS on a
+// reused connection, are zero. TTFB is measured from the moment the request
+// was sent, so it does not include any scheduling delay.
+type Timings struct {
+	DNS     time.Duration `json:"dns_ns,omitempty"`
+	Connect time.Duration `json:"connect_ns,omitempty"`
+	TLS     time.Duration `json:"tls_ns,omitempty"`
+	TTFB    time.Duration `json:"ttfb_ns,omitempty"`
+	Reused  bool          `json:"reused,omitempty"`
+}
+
+// timingTrace records the instants reported by httptrace. The callbacks can
+// run on the transport goroutines, hence the mutex.
+type timingTrace struct {
+	mu        sync.Mutex
+	sent      time.Time
+	dnsStart  time.Time
+	dnsDone   time.Time
+	dialStart time.Time
+	dialDone  time.Time
+	tlsStart  time.Time
+	tlsDone   time.Time
+	firstByte time.Time
+	gotConn   bool
+	reused    bool
+}
+
+func newTimingTrace(sent time.Time) *timingTrace {
+	return &timingTrace{sent: sent}
+}
+
+// record stores the current instant in field, keeping the first one when a
+// callback runs more than once (ex: dialing several addresses)
+func (t *timingTrace) record(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.record(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.record(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.record(&t.dialStart) },
+		ConnectDone:       func(string, string, error) { t.record(&t.dialDone) },
+		TLSHandshakeStart: func() { t.record(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.record(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = true
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { t.record(&t.firstByte) },
+	}
+}
+
+// timings returns the measured phases, or nil if no connection was obtained
+func (t *timingTrace) timings() *Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.gotConn {
+		return nil
+	}
+	return &Timings{
+		DNS:     phaseDuration(t.dnsStart, t.dnsDone),
+		Connect: phaseDuration(t.dialStart, t.dialDone),
+		TLS:     phaseDuration(t.tlsStart, t.tlsDone),
+		TTFB:    phaseDuration(t.sent, t.firstByte),
+		Reused:  t.reused,
+	}
+}
+
+func phaseDuration(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// ConnectionStats summarizes the connections used by a group of requests
+// and the duration of each connection phase
+type ConnectionStats struct {
+	New    int          `json:"new"`
+	Reused int          `json:"reused"`
+	Phases []PhaseStats `json:"phases"`
+}
+
+// PhaseStats summarizes one connection phase over the requests that went through it
+type PhaseStats struct {
+	Phase   string        `json:"phase"`
+	Count   int           `json:"count"`
+	Average time.Duration `json:"average_ns"`
+	P50     time.Duration `json:"p50_ns"`
+	P95     time.Duration `json:"p95_ns"`
+	P99     time.Duration `json:"p99_ns"`
+	Max     time.Duration `json:"max_ns"`
+	// Distribution is only kept in the reports of the agents, to be merged
+	Distribution latencyDistribution `json:"distribution,omitempty"`
+}
+
+// connectionBuilder accumulates the timings of a group of results
+type connectionBuilder struct {
+	stats  ConnectionStats
+	phases map[string][]time.Duration
+}
+
+func newConnectionBuilder() *connectionBuilder {
+	return &connectionBuilder{phases: make(map[string][]time.Duration)}
+}
+
+func (b *connectionBuilder) add(timings *Timings) {
+	if timings.Reused {
+		b.stats.Reused++
+	} else {
+		b.stats.New++
+	}
+	b.addPhase(phaseDNS, timings.DNS)
+	b.addPhase(phaseConnect, timings.Connect)
+	b.addPhase(phaseTLS, timings.TLS)
+	b.addPhase(phaseTTFB, timings.TTFB)
+}
+
+func (b *connectionBuilder) addPhase(phase string, d time.Duration) {
+	if d > 0 {
+		b.phases[phase] = append(b.phases[phase], d)
+	}
+}
+
+// build returns the connection stats, or nil if no request was traced
+func (b *connectionBuilder) build() *ConnectionStats {
+	if b.stats.New+b.stats.Reused == 0 {
+		return nil
+	}
+	stats := b.stats
+	stats.Phases = nil
+	for _, phase := range phaseOrder {
+		if durations := b.phases[phase]; len(durations) > 0 {
+			stats.Phases = append(stats.Phases, newPhaseStats(phase, durations))
+		}
+	}
+	return &stats
+}
+
+// newPhaseStats summarizes the durations of a phase. The slice is sorted in place.
+func newPhaseStats(phase string, durations []time.Duration) PhaseStats {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return PhaseStats{
+		Phase:        phase,
+		Count:        len(durations),
+		Average:      total / time.Duration(len(durations)),
+		P50:          percentileOf(durations, 50),
+		P95:          percentileOf(durations, 95),
+		P99:          percentileOf(durations, 99),
+		Max:          durations[len(durations)-1],
+		Distribution: newLatencyDistribution(durations),
+	}
+}
+
+// mergeConnectionStats combines the connection stats of two groups of
+// requests. The percentiles are estimated from the merged distributions.
+func mergeConnectionStats(a, b *ConnectionStats) *ConnectionStats {
+	if a == nil && b == nil {
+		return nil
+	}
+	merged := &ConnectionStats{}
+	phases := make(map[string]PhaseStats)
+	for _, stats := range []*ConnectionStats{a, b} {
+		if stats == nil {
+			continue
+		}
+		merged.New += stats.New
+		merged.Reused += stats.Reused
+		for _, phase := range stats.Phases {
+			phases[phase.Phase] = mergePhaseStats(phases[phase.Phase], phase)
+		}
+	}
+	for _, phase := range phaseOrder {
+		if stats, ok := phases[phase]; ok {
+			merged.Phases = append(merged.Phases, stats)
+		}
+	}
+	return merged
+}
+
+func mergePhaseStats(a, b PhaseStats) PhaseStats {
+	merged := PhaseStats{
+		Phase:        b.Phase,
+		Count:        a.Count + b.Count,
+		Average:      weightedAverage(a.Average, a.Count, b.Average, b.Count),
+		Max:          max(a.Max, b.Max),
+		Distribution: make(latencyDistribution),
+	}
+	merged.Distribution.merge(a.Distribution)
+	merged.Distribution.merge(b.Distribution)
+	merged.P50 = merged.Distribution.percentile(50, 0, merged.Max)
+	merged.P95 = merged.Distribution.percentile(95, 0, merged.Max)
+	merged.P99 = merged.Distribution.percentile(99, 0, merged.Max)
+	return merged
+}
+
+// Phase returns the stats of a phase, if any request went through it
+func (s *ConnectionStats) Phase(phase string) (PhaseStats, bool) {
+	if s == nil {
+		return PhaseStats{}, false
+	}
+	for _, stats := range s.Phases {
+		if stats.Phase == phase {
+			return stats, true
+		}
+	}
+	return PhaseStats{}, false
+}
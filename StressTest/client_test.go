@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepAliveReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		keepAlive  bool
+		wantNew    int
+		wantReused int
+	}{
+		{"Keep-alive", true, 1, 9},
+		{"No keep-alive", false, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := runStressTest(Config{
+				URL:         server.URL,
+				Requests:    10,
+				Concurrency: 1,
+				Client:      ClientOptions{DisableKeepAlives: !tt.keepAlive},
+			})
+
+			if report.Connections == nil {
+				t.Fatal("Expected connection stats")
+			}
+			if report.Connections.New != tt.wantNew || report.Connections.Reused != tt.wantReused {
+				t.Errorf("Expected %d new and %d reused connections, got %+v", tt.wantNew, tt.wantReused, report.Connections)
+			}
+			connect, ok := report.Connections.Phase(phaseConnect)
+			if !ok || connect.Count != tt.wantNew {
+				t.Errorf("Expected %d connect timings, got %+v", tt.wantNew, connect)
+			}
+			if ttfb, ok := report.Connections.Phase(phaseTTFB); !ok || ttfb.Count != 10 || ttfb.P95 <= 0 {
+				t.Errorf("Expected a TTFB for every request, got %+v", ttfb)
+			}
+		})
+	}
+}
+
+func TestMaxConnsPerHost(t *testing.T) {
+	var inFlight, maxInFlight int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	report := runStressTest(Config{
+		URL:         server.URL,
+		Requests:    30,
+		Concurrency: 6,
+		Client:      ClientOptions{MaxConnsPerHost: 2},
+	})
+
+	if report.SuccessCount != 30 {
+		t.Errorf("Expected 30 successful requests, got %d", report.SuccessCount)
+	}
+	if got := atomic.LoadInt64(&maxInFlight); got > 2 {
+		t.Errorf("Expected at most 2 concurrent connections, got %d", got)
+	}
+}
+
+func TestHTTPVersion(t *testing.T) {
+	var proto atomic.Value
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto.Store(r.Proto)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	plainServer := httptest.NewServer(handler)
+	defer plainServer.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		options   ClientOptions
+		wantProto string
+		wantErr   bool
+	}{
+		{"Auto negotiates HTTP/2", tlsServer.URL, ClientOptions{InsecureSkipVerify: true}, "HTTP/2.0", false},
+		{"Forced HTTP/1.1", tlsServer.URL, ClientOptions{InsecureSkipVerify: true, HTTPVersion: httpVersion11}, "HTTP/1.1", false},
+		{"Forced HTTP/2", tlsServer.URL, ClientOptions{InsecureSkipVerify: true, HTTPVersion: httpVersion2}, "HTTP/2.0", false},
+		{"Forced HTTP/2 without support", plainServer.URL, ClientOptions{HTTPVersion: httpVersion2}, "", true},
+		{"Self-signed certificate", tlsServer.URL, ClientOptions{}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proto.Store("")
+			result := doRequest(newHTTPClient(tt.options), RequestSpec{URL: tt.url}, time.Now())
+			if (result.Error != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, result.Error)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := proto.Load(); got != tt.wantProto {
+				t.Errorf("Expected %s, got %s", tt.wantProto, got)
+			}
+			if result.Timings == nil || result.Timings.TLS <= 0 || result.Timings.TTFB <= 0 {
+				t.Errorf("Expected TLS and TTFB timings, got %+v", result.Timings)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	result := doRequest(newHTTPClient(ClientOptions{Timeout: 50 * time.Millisecond}), RequestSpec{URL: server.URL}, time.Now())
+	if result.Error == nil || classifyError(result.Error) != errorTimeout {
+		t.Errorf("Expected a timeout, got %v", result.Error)
+	}
+}
+
+func TestParseFlagsClientOptions(t *testing.T) {
+	config := parseFlagsFromArgs([]string{
+		"--url=https://localhost:8443",
+		"--requests=10",
+		"--timeout=5s",
+		"--keep-alive=false",
+		"--max-conns-per-host=20",
+		"--http-version=1.1",
+		"--insecure",
+	})
+
+	expected := ClientOptions{
+		Timeout:            5 * time.Second,
+		DisableKeepAlives:  true,
+		MaxConnsPerHost:    20,
+		HTTPVersion:        httpVersion11,
+		InsecureSkipVerify: true,
+	}
+	if config.Client != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config.Client)
+	}
+
+	config = parseFlagsFromArgs([]string{"--url=http://localhost", "--requests=1"})
+	if config.Client.Timeout != defaultRequestTimeout || config.Client.DisableKeepAlives {
+		t.Errorf("Unexpected default client options: %+v", config.Client)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Summary holds the request counters and latency of a group of results. The
+// latency covers only the requests that got a response.
+type Summary struct {
+	TotalRequests int          `json:"total_requests"`
+	StatusCounts  map[int]int  `json:"status_counts"`
+	SuccessCount  int          `json:"success_count"`
+	FailureCount  int          `json:"failure_count"`
+	Errors        []ErrorGroup `json:"errors,omitempty"`
+	// AssertionFailures groups the responses that failed an assertion
+	AssertionFailures []ErrorGroup `json:"assertion_failures,omitempty"`
+	// Connections is nil when no request obtained a connection
+	Connections *ConnectionStats `json:"connections,omitempty"`
+	LatencyStats
+}
+
+// ErrorRate returns the share of requests that did not succeed
+func (s Summary) ErrorRate() float64 {
+	if s.TotalRequests == 0 {
+		return 0
+	}
+	return float64(s.TotalRequests-s.SuccessCount) / float64(s.TotalRequests)
+}
+
+// StepReport holds the results of a single scenario step
+type StepReport struct {
+	Name string `json:"name"`
+	Summary
+}
+
+// summaryBuilder accumulates results into a Summary
+type summaryBuilder struct {
+	summary     Summary
+	errors      errorGroups
+	assertions  errorGroups
+	connections *connectionBuilder
+	durations   []time.Duration
+}
+
+func newSummaryBuilder() *summaryBuilder {
+	return &summaryBuilder{
+		summary:     Summary{StatusCounts: make(map[int]int)},
+		errors:      make(errorGroups),
+		assertions:  make(errorGroups),
+		connections: newConnectionBuilder(),
+	}
+}
+
+func (b *summaryBuilder) add(result Result) {
+	b.summary.TotalRequests++
+	if result.Error == nil {
+		b.summary.StatusCounts[result.StatusCode]++
+		if result.succeeded() {
+			b.summary.SuccessCount++
+		} else {
+			kind := assertionStatus
+			var failure *assertionError
+			if errors.As(result.AssertionError, &failure) {
+				kind = failure.kind
+			}
+			b.assertions.addKind(kind, result.AssertionError.Error())
+		}
+	} else {
+		b.summary.FailureCount++
+		b.errors.add(result.Error)
+	}
+	if result.Timings != nil {
+		b.connections.add(result.Timings)
+	}
+	// Transport errors (timeouts, resets) are left out of the latency: their
+	// duration is how long it took to give up, not how long the server took
+	if result.Error == nil {
+		b.durations = append(b.durations, result.Duration)
+	}
+}
+
+func (b *summaryBuilder) build() Summary {
+	summary := b.summary
+	summary.Errors = b.errors.list()
+	summary.AssertionFailures = b.assertions.list()
+	summary.Connections = b.connections.build()
+	summary.LatencyStats = computeLatencyStats(b.durations)
+	return summary
+}
+
+// mergeSummaries combines the summaries of two groups of requests
+func mergeSummaries(a, b Summary) Summary {
+	merged := Summary{
+		TotalRequests: a.TotalRequests + b.TotalRequests,
+		StatusCounts:  make(map[int]int),
+		SuccessCount:  a.SuccessCount + b.SuccessCount,
+		FailureCount:  a.FailureCount + b.FailureCount,
+		Connections:   mergeConnectionStats(a.Connections, b.Connections),
+		LatencyStats:  mergeLatencyStats(a.LatencyStats, b.LatencyStats),
+	}
+	errors, assertions := make(errorGroups), make(errorGroups)
+	for _, summary := range []Summary{a, b} {
+		for status, count := range summary.StatusCounts {
+			merged.StatusCounts[status] += count
+		}
+		errors.merge(summary.Errors)
+		assertions.merge(summary.AssertionFailures)
+	}
+	merged.Errors = errors.list()
+	merged.AssertionFailures = assertions.list()
+	return merged
+}
+
+// dropDistributions removes the latency distributions, which are only needed
+// to merge the summary with others
+func (s *Summary) dropDistributions() {
+	s.Distribution = nil
+	if s.Connections != nil {
+		for i := range s.Connections.Phases {
+			s.Connections.Phases[i].Distribution = nil
+		}
+	}
+}
+
+// mergeReports adds the results of other, a run that started together with
+// report, to report. The percentiles are estimated from the latency
+// distributions, within 1% of the ones of a single run with every request.
+func mergeReports(report, other Report) Report {
+	report.Summary = mergeSummaries(report.Summary, other.Summary)
+
+	steps := make(map[string]int)
+	for i, step := range report.Steps {
+		steps[step.Name] = i
+	}
+	report.Steps = append([]StepReport(nil), report.Steps...)
+	for _, step := range other.Steps {
+		if i, ok := steps[step.Name]; ok {
+			report.Steps[i].Summary = mergeSummaries(report.Steps[i].Summary, step.Summary)
+		} else {
+			steps[step.Name] = len(report.Steps)
+			report.Steps = append(report.Steps, step)
+		}
+	}
+
+	report.Stages = mergeStageReports(report.Stages, other.Stages)
+	report.TimeSeries = mergeTimeSeries(report.TimeSeries, other.TimeSeries)
+	return report
+}
+
+// dropDistributions removes the latency distributions from every summary of the report
+func (r *Report) dropDistributions() {
+	r.Summary.dropDistributions()
+	for i := range r.Steps {
+		r.Steps[i].dropDistributions()
+	}
+	for i := range r.Stages {
+		r.Stages[i].dropDistributions()
+	}
+	for i := range r.Agents {
+		r.Agents[i].dropDistributions()
+	}
+	for i := range r.TimeSeries {
+		r.TimeSeries[i].Distribution = nil
+	}
+}
+
+// collector aggregates the results of a run into a Report
+type collector struct {
+	url       string
+	protocol  string
+	startTime time.Time
+	total     *summaryBuilder
+	steps     map[string]*summaryBuilder
+	stepOrder []string
+	stages    *stageReports
+	series    *timeSeries
+}
+
+func newCollector(config Config, startTime time.Time) *collector {
+	c := &collector{
+		url:       config.URL,
+		startTime: startTime,
+		total:     newSummaryBuilder(),
+		steps:     make(map[string]*summaryBuilder),
+		series:    newTimeSeries(startTime),
+	}
+	if config.GRPC != nil {
+		c.url = config.GRPC.address()
+		c.protocol = protocolGRPC
+	}
+	if config.Replay != nil && c.url == "" {
+		c.url = "replay:" + config.Replay.Source
+	}
+	if len(config.Stages) > 0 {
+		c.stages = newStageReports(config.Stages, config.Concurrency)
+	}
+	return c
+}
+
+func (c *collector) add(result Result) {
+	c.total.add(result)
+	c.series.add(result)
+
+	if result.Step != "" {
+		step, ok := c.steps[result.Step]
+		if !ok {
+			step = newSummaryBuilder()
+			c.steps[result.Step] = step
+			c.stepOrder = append(c.stepOrder, result.Step)
+		}
+		step.add(result)
+	}
+
+	if c.stages != nil {
+		c.stages.add(result.Start.Sub(c.startTime), result)
+	}
+}
+
+// report returns the aggregated report. TotalTime is left for the caller.
+func (c *collector) report() Report {
+	report := Report{
+		URL:        c.url,
+		Protocol:   c.protocol,
+		StartedAt:  c.startTime,
+		Summary:    c.total.build(),
+		TimeSeries: c.series.points(),
+	}
+	for _, name := range c.stepOrder {
+		report.Steps = append(report.Steps, StepReport{Name: name, Summary: c.steps[name].build()})
+	}
+	if c.stages != nil {
+		report.Stages = c.stages.reports()
+	}
+	return report
+}
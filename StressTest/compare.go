@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exitRegression is the exit code of compare when the candidate regressed
+const exitRegression = 4
+
+// Comparison holds the deltas between a baseline and a candidate run
+type Comparison struct {
+	Baseline  string        `json:"baseline"`
+	Candidate string        `json:"candidate"`
+	Alpha     float64       `json:"alpha"`
+	Tolerance float64       `json:"tolerance"`
+	Metrics   []MetricDelta `json:"metrics"`
+}
+
+// MetricDelta compares one metric of the two runs. Values are in the unit of
+// the metric, as in thresholds: nanoseconds for latencies, a ratio for errors.
+// Change is relative to the baseline, except for errors, where it is the
+// difference of the ratios.
+type MetricDelta struct {
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Change    float64 `json:"change"`
+	// PValue is the one-sided p-value of the candidate being worse, nil when
+	// the reports do not have enough data for the test
+	PValue     *float64 `json:"p_value,omitempty"`
+	Regression bool     `json:"regression"`
+}
+
+// Regressions returns the metrics flagged as regressions
+func (c Comparison) Regressions() []MetricDelta {
+	var regressions []MetricDelta
+	for _, metric := range c.Metrics {
+		if metric.Regression {
+			regressions = append(regressions, metric)
+		}
+	}
+	return regressions
+}
+
+// runCompare implements "stresstest compare baseline.json candidate.json"
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("stresstest compare", flag.ExitOnError)
+	alpha := fs.Float64("alpha", 0.05, "Nível de significância dos testes estatísticos")
+	tolerance := fs.String("tolerance", "5%", "Piora mínima, relativa ao baseline, para uma métrica contar como regressão")
+	format := fs.String("format", formatText, "Formato da comparação: text ou json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Uso: stresstest compare [opções] baseline.json candidato.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *alpha <= 0 || *alpha >= 1 {
+		log.Fatal("Parâmetro --alpha deve estar entre 0 e 1")
+	}
+	toleranceValue, err := parseRatio(*tolerance)
+	if err != nil || toleranceValue < 0 {
+		log.Fatalf("Parâmetro --tolerance inválido: %s", *tolerance)
+	}
+	if *format != formatText && *format != formatJSON {
+		log.Fatalf("Parâmetro --format inválido: %s (use text ou json)", *format)
+	}
+
+	baseline, err := loadReport(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Erro ao carregar o baseline: %v", err)
+	}
+	candidate, err := loadReport(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("Erro ao carregar o candidato: %v", err)
+	}
+
+	comparison := compareReports(baseline, candidate, *alpha, toleranceValue)
+	comparison.Baseline = fs.Arg(0)
+	comparison.Candidate = fs.Arg(1)
+
+	if *format == formatJSON {
+		err = writeJSONComparison(os.Stdout, comparison)
+	} else {
+		err = writeTextComparison(os.Stdout, comparison)
+	}
+	if err != nil {
+		log.Fatalf("Erro ao gerar a comparação: %v", err)
+	}
+
+	if len(comparison.Regressions()) > 0 {
+		os.Exit(exitRegression)
+	}
+}
+
+// loadReport reads a report saved with --format=json
+func loadReport(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, fmt.Errorf("%s não é um relatório JSON: %w", path, err)
+	}
+	if report.TotalRequests == 0 {
+		return Report{}, fmt.Errorf("%s não tem requests", path)
+	}
+	return report, nil
+}
+
+// parseRatio parses "5%" or "0.05"
+func parseRatio(raw string) (float64, error) {
+	if percent, found := strings.CutSuffix(strings.TrimSpace(raw), "%"); found {
+		value, err := strconv.ParseFloat(percent, 64)
+		return value / 100, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(raw), 64)
+}
+
+// compareReports compares throughput, error rate and latency. A metric is a
+// regression when it got worse by more than the tolerance and the test of
+// its samples rejects, at level alpha, that the candidate is not worse:
+//   - rps: Welch's t-test on the requests of each full second of the time series
+//   - errors: two-proportion z-test (any significant increase counts)
+//   - latencies: Mann-Whitney U test on the latency histograms
+func compareReports(baseline, candidate Report, alpha, tolerance float64) Comparison {
+	comparison := Comparison{Alpha: alpha, Tolerance: tolerance}
+
+	rps := newMetricDelta("rps", baseline.RequestsPerSecond(), candidate.RequestsPerSecond())
+	if p, ok := welchTTest(secondlyRequests(candidate), secondlyRequests(baseline)); ok {
+		rps.PValue = &p
+		rps.Regression = p < alpha && -rps.Change > tolerance
+	}
+	comparison.Metrics = append(comparison.Metrics, rps)
+
+	errorRate := MetricDelta{Metric: "errors", Baseline: baseline.ErrorRate(), Candidate: candidate.ErrorRate()}
+	errorRate.Change = errorRate.Candidate - errorRate.Baseline
+	if p, ok := twoProportionTest(baseline.TotalRequests-baseline.SuccessCount, baseline.TotalRequests,
+		candidate.TotalRequests-candidate.SuccessCount, candidate.TotalRequests); ok {
+		errorRate.PValue = &p
+		errorRate.Regression = p < alpha
+	}
+	comparison.Metrics = append(comparison.Metrics, errorRate)
+
+	// Um único teste sobre a distribuição inteira vale para todas as latências
+	latencyP, latencyTested := mannWhitneyTest(baseline.Histogram, candidate.Histogram)
+	addLatency := func(metric string, base, cand time.Duration) {
+		delta := newMetricDelta(metric, float64(base), float64(cand))
+		if latencyTested {
+			delta.PValue = &latencyP
+			delta.Regression = latencyP < alpha && delta.Change > tolerance
+		}
+		comparison.Metrics = append(comparison.Metrics, delta)
+	}
+	addLatency("avg", baseline.AverageDuration, candidate.AverageDuration)
+	for _, q := range reportQuantiles {
+		base, baseOK := baseline.Percentile(q)
+		cand, candOK := candidate.Percentile(q)
+		if baseOK && candOK {
+			addLatency(quantileLabel(q), base, cand)
+		}
+	}
+
+	return comparison
+}
+
+func newMetricDelta(metric string, baseline, candidate float64) MetricDelta {
+	delta := MetricDelta{Metric: metric, Baseline: baseline, Candidate: candidate}
+	if baseline != 0 {
+		delta.Change = (candidate - baseline) / baseline
+	}
+	return delta
+}
+
+// secondlyRequests returns the requests of each second of the time series,
+// leaving out the last one, which usually covers only part of a second
+func secondlyRequests(report Report) []float64 {
+	if len(report.TimeSeries) < 2 {
+		return nil
+	}
+	last := report.TimeSeries[len(report.TimeSeries)-1].Second
+	counts := make([]float64, last)
+	for _, point := range report.TimeSeries {
+		if point.Second < last {
+			counts[point.Second] = float64(point.Requests)
+		}
+	}
+	return counts
+}
+
+// welchTTest returns the one-sided p-value of the mean of a being lower than
+// the mean of b. It needs at least two samples on each side.
+func welchTTest(a, b []float64) (float64, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	seA, seB := varA/float64(len(a)), varB/float64(len(b))
+	if seA+seB == 0 {
+		// Sem variação, a diferença das médias é exata
+		if meanA < meanB {
+			return 0, true
+		}
+		return 1, true
+	}
+
+	t := (meanA - meanB) / math.Sqrt(seA+seB)
+	df := (seA + seB) * (seA + seB) / (seA*seA/float64(len(a)-1) + seB*seB/float64(len(b)-1))
+	return studentTCDF(t, df), true
+}
+
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
+
+// twoProportionTest returns the one-sided p-value of the rate x2/n2 being
+// higher than x1/n1
+func twoProportionTest(x1, n1, x2, n2 int) (float64, bool) {
+	if n1 == 0 || n2 == 0 {
+		return 0, false
+	}
+	p1, p2 := float64(x1)/float64(n1), float64(x2)/float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		// Nenhum erro (ou só erros) nas duas execuções
+		return 1, true
+	}
+	return 1 - normalCDF((p2-p1)/se), true
+}
+
+// mannWhitneyTest returns the one-sided p-value of the latencies of the
+// candidate being stochastically greater than those of the baseline. The
+// requests of a histogram bucket are ties, ranked by their midrank.
+func mannWhitneyTest(baseline, candidate []HistogramBucket) (float64, bool) {
+	if len(baseline) != len(candidate) {
+		return 0, false
+	}
+	var n1, n2 float64
+	for i := range baseline {
+		if baseline[i].From != candidate[i].From || baseline[i].To != candidate[i].To {
+			return 0, false
+		}
+		n1 += float64(baseline[i].Count)
+		n2 += float64(candidate[i].Count)
+	}
+	if n1 == 0 || n2 == 0 {
+		return 0, false
+	}
+
+	n := n1 + n2
+	var rankSum, ties, below float64
+	for i := range baseline {
+		count := float64(baseline[i].Count + candidate[i].Count)
+		midrank := below + (count+1)/2
+		rankSum += float64(candidate[i].Count) * midrank
+		ties += count*count*count - count
+		below += count
+	}
+
+	u := rankSum - n2*(n2+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		// Todas as requests caíram na mesma faixa
+		return 1, true
+	}
+	// Correção de continuidade
+	z := (u - mean - 0.5) / math.Sqrt(variance)
+	return 1 - normalCDF(z), true
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// studentTCDF returns P(T <= t) for Student's t distribution with df degrees of freedom
+func studentTCDF(t, df float64) float64 {
+	tail := 0.5 * regularizedBeta(df/(df+t*t), df/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// regularizedBeta computes the regularized incomplete beta function I_x(a, b)
+// with its continued fraction (Numerical Recipes, betacf)
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// A fração converge rápido para x < (a+1)/(a+b+2); do contrário usa a simetria
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(1-x, b, a)/b
+	}
+	return front * betaContinuedFraction(x, a, b) / a
+}
+
+func betaContinuedFraction(x, a, b float64) float64 {
+	const maxIterations = 200
+	const epsilon = 1e-12
+	const tiny = 1e-300
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= maxIterations; m++ {
+		// Termo par
+		numerator := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// Termo ímpar
+		numerator = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+func writeJSONComparison(w io.Writer, comparison Comparison) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(comparison)
+}
+
+func writeTextComparison(w io.Writer, comparison Comparison) error {
+	fmt.Fprintln(w, "=== COMPARAÇÃO DE EXECUÇÕES ===")
+	fmt.Fprintf(w, "Baseline: %s\n", comparison.Baseline)
+	fmt.Fprintf(w, "Candidato: %s\n", comparison.Candidate)
+	fmt.Fprintf(w, "Significância: %.2f | Tolerância: %.1f%%\n\n", comparison.Alpha, comparison.Tolerance*100)
+
+	fmt.Fprintf(w, "  %-8s %12s %12s %10s %9s\n", "métrica", "baseline", "candidato", "variação", "p-valor")
+	for _, metric := range comparison.Metrics {
+		pValue := "n/d"
+		if metric.PValue != nil {
+			pValue = fmt.Sprintf("%.4f", *metric.PValue)
+		}
+		line := fmt.Sprintf("  %-8s %12s %12s %10s %9s", metric.Metric,
+			formatMetricValue(metric.Metric, metric.Baseline), formatMetricValue(metric.Metric, metric.Candidate),
+			formatMetricChange(metric), pValue)
+		if metric.Regression {
+			line += "  REGRESSÃO"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	regressions := comparison.Regressions()
+	if len(regressions) == 0 {
+		fmt.Fprintln(w, "\nNenhuma regressão significativa")
+	} else {
+		var names []string
+		for _, metric := range regressions {
+			names = append(names, metric.Metric)
+		}
+		fmt.Fprintf(w, "\nRegressões significativas: %s\n", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func formatMetricValue(metric string, value float64) string {
+	switch metric {
+	case "rps":
+		return fmt.Sprintf("%.2f", value)
+	case "errors":
+		return fmt.Sprintf("%.2f%%", value*100)
+	}
+	return time.Duration(value).Round(time.Microsecond).String()
+}
+
+// formatMetricChange formats the relative change, or the difference in
+// percentage points for the error rate
+func formatMetricChange(metric MetricDelta) string {
+	if metric.Metric == "errors" {
+		return fmt.Sprintf("%+.2fpp", metric.Change*100)
+	}
+	if metric.Baseline == 0 {
+		return "n/d"
+	}
+	return fmt.Sprintf("%+.1f%%", metric.Change*100)
+}
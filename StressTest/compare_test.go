@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// comparisonReport builds a report whose latencies are spread over the
+// histogram buckets given by counts, with rps[i] requests in second i
+func comparisonReport(counts map[time.Duration]int, failures int, rps []int) Report {
+	var durations []time.Duration
+	for d, n := range counts {
+		for i := 0; i < n; i++ {
+			durations = append(durations, d)
+		}
+	}
+	// O tempo total faz o throughput do relatório bater com a série temporal
+	sum := 0
+	for _, requests := range rps {
+		sum += requests
+	}
+	report := Report{TotalTime: time.Second}
+	if sum > 0 {
+		report.TotalTime = time.Duration(float64(len(durations)) / float64(sum) * float64(len(rps)) * float64(time.Second))
+	}
+	report.StatusCounts = map[int]int{200: len(durations) - failures}
+	report.TotalRequests = len(durations)
+	report.SuccessCount = len(durations) - failures
+	report.LatencyStats = computeLatencyStats(durations)
+	for second, requests := range rps {
+		report.TimeSeries = append(report.TimeSeries, TimeSeriesPoint{Second: second, Requests: requests})
+	}
+	return report
+}
+
+func TestStudentTCDF(t *testing.T) {
+	tests := []struct {
+		t, df, want float64
+	}{
+		{0, 5, 0.5},
+		{2.0, 10, 0.96331},
+		{-2.0, 10, 0.03669},
+		{1.0, 1, 0.75},
+		{2.576, 10000, 0.995},
+	}
+
+	for _, tt := range tests {
+		if got := studentTCDF(tt.t, tt.df); math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("studentTCDF(%v, %v): expected %.5f, got %.5f", tt.t, tt.df, tt.want, got)
+		}
+	}
+}
+
+func TestMannWhitneyTest(t *testing.T) {
+	fast := comparisonReport(map[time.Duration]int{3 * time.Millisecond: 500, 8 * time.Millisecond: 500}, 0, nil)
+	slow := comparisonReport(map[time.Duration]int{8 * time.Millisecond: 500, 15 * time.Millisecond: 500}, 0, nil)
+
+	if p, ok := mannWhitneyTest(fast.Histogram, fast.Histogram); !ok || p < 0.4 {
+		t.Errorf("Expected identical histograms not to differ, got p=%v", p)
+	}
+	if p, ok := mannWhitneyTest(fast.Histogram, slow.Histogram); !ok || p > 0.001 {
+		t.Errorf("Expected a slower candidate to be significant, got p=%v", p)
+	}
+	if p, ok := mannWhitneyTest(slow.Histogram, fast.Histogram); !ok || p < 0.999 {
+		t.Errorf("Expected a faster candidate not to be a regression, got p=%v", p)
+	}
+	if _, ok := mannWhitneyTest(fast.Histogram, nil); ok {
+		t.Error("Expected histograms with different buckets not to be compared")
+	}
+}
+
+func TestCompareReports(t *testing.T) {
+	steady := []int{100, 102, 98, 101, 99, 100, 103, 97, 100, 50}
+	baseline := comparisonReport(map[time.Duration]int{3 * time.Millisecond: 500, 8 * time.Millisecond: 500}, 2, steady)
+
+	t.Run("Same run", func(t *testing.T) {
+		comparison := compareReports(baseline, baseline, 0.05, 0.05)
+		if regressions := comparison.Regressions(); len(regressions) != 0 {
+			t.Errorf("Expected no regressions, got %+v", regressions)
+		}
+	})
+
+	t.Run("Regressed run", func(t *testing.T) {
+		candidate := comparisonReport(map[time.Duration]int{8 * time.Millisecond: 500, 15 * time.Millisecond: 500}, 40,
+			[]int{80, 82, 78, 81, 79, 80, 83, 77, 80, 40})
+		comparison := compareReports(baseline, candidate, 0.05, 0.05)
+
+		regressed := make(map[string]bool)
+		for _, metric := range comparison.Regressions() {
+			regressed[metric.Metric] = true
+		}
+		for _, metric := range []string{"rps", "errors", "avg", "p50", "p99"} {
+			if !regressed[metric] {
+				t.Errorf("Expected %s to regress, got %+v", metric, comparison.Metrics)
+			}
+		}
+	})
+
+	t.Run("Change within tolerance", func(t *testing.T) {
+		// Mais lento de forma significativa, mas o p50 sobe menos que a tolerância
+		candidate := comparisonReport(map[time.Duration]int{3 * time.Millisecond: 450, 8 * time.Millisecond: 550}, 2, steady)
+		comparison := compareReports(baseline, candidate, 0.05, 5)
+		if regressions := comparison.Regressions(); len(regressions) != 0 {
+			t.Errorf("Expected no regressions within a 500%% tolerance, got %+v", regressions)
+		}
+	})
+}
+
+func TestLoadReportAndWriteComparison(t *testing.T) {
+	report := comparisonReport(map[time.Duration]int{3 * time.Millisecond: 10}, 0, []int{5, 5, 5})
+	path := filepath.Join(t.TempDir(), "baseline.json")
+
+	var saved bytes.Buffer
+	if err := writeJSONReport(&saved, report); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, saved.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadReport(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loaded.TotalRequests != 10 || len(loaded.TimeSeries) != 3 || len(loaded.Histogram) != len(report.Histogram) {
+		t.Errorf("Report not preserved: %+v", loaded)
+	}
+
+	comparison := compareReports(loaded, loaded, 0.05, 0.05)
+	comparison.Baseline, comparison.Candidate = path, path
+	var out bytes.Buffer
+	if err := writeTextComparison(&out, comparison); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "p99.9") || !strings.Contains(out.String(), "Nenhuma regressão significativa") {
+		t.Errorf("Unexpected comparison:\n%s", out.String())
+	}
+
+	if err := os.WriteFile(path, []byte(`{"total_requests": 0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadReport(path); err == nil {
+		t.Error("Expected an error for a report without requests")
+	}
+}
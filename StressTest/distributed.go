@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// agentJob is the share of the test that the coordinator sends to an agent
+type agentJob struct {
+	URL         string        `json:"url"`
+	Method      string        `json:"method"`
+	Headers     http.Header   `json:"headers,omitempty"`
+	Body        []byte        `json:"body,omitempty"`
+	Requests    int           `json:"requests"`
+	Concurrency int           `json:"concurrency"`
+	Duration    time.Duration `json:"duration_ns"`
+	Rate        float64       `json:"rate,omitempty"`
+	Stages      []Stage       `json:"stages,omitempty"`
+	Assertions  *Assertions   `json:"assertions,omitempty"`
+	Client      ClientOptions `json:"client"`
+	GRPC        *GRPCSpec     `json:"grpc,omitempty"`
+	Replay      *Replay       `json:"replay,omitempty"`
+	// Scenario is the scenario in YAML, parsed again by the agent
+	Scenario string `json:"scenario,omitempty"`
+}
+
+// AgentReport holds the results of the requests made by one agent
+type AgentReport struct {
+	Address string `json:"address"`
+	Error   string `json:"error,omitempty"`
+	Summary
+}
+
+// parseAgents parses "host1:9100,http://host2:9100" into agent base URLs
+func parseAgents(raw string) []string {
+	var agents []string
+	for _, agent := range strings.Split(raw, ",") {
+		agent = strings.TrimSuffix(strings.TrimSpace(agent), "/")
+		if agent == "" {
+			continue
+		}
+		if !strings.Contains(agent, "://") {
+			agent = "http://" + agent
+		}
+		agents = append(agents, agent)
+	}
+	return agents
+}
+
+// splitCount returns the share of total assigned to part i of n, spreading
+// the remainder over the first parts
+func splitCount(total, n, i int) int {
+	share := total / n
+	if i < total%n {
+		share++
+	}
+	return share
+}
+
+// agentJobs splits the test described by config among its agents
+func agentJobs(config Config) ([]agentJob, error) {
+	var scenario string
+	if config.Scenario != nil {
+		data, err := yaml.Marshal(config.Scenario)
+		if err != nil {
+			return nil, err
+		}
+		scenario = string(data)
+	}
+
+	replay := config.Replay
+	if replay != nil && config.Requests > 0 && config.Requests < len(replay.Entries) {
+		limited := *replay
+		limited.Entries = replay.Entries[:config.Requests]
+		replay = &limited
+	}
+
+	n := len(config.Agents)
+	// Uma parte com 0 requests rodaria sem limite
+	if replay == nil && config.Requests > 0 && config.Requests < n {
+		return nil, fmt.Errorf("%d requests não podem ser divididos entre %d agentes", config.Requests, n)
+	}
+	jobs := make([]agentJob, n)
+	for i := range jobs {
+		job := agentJob{
+			URL:         config.URL,
+			Method:      config.Method,
+			Headers:     config.Headers,
+			Body:        config.Body,
+			Requests:    splitCount(config.Requests, n, i),
+			Concurrency: splitCount(config.Concurrency, n, i),
+			Duration:    config.Duration,
+			Rate:        config.Rate / float64(n),
+			Assertions:  config.Assertions,
+			Client:      config.Client,
+			GRPC:        config.GRPC,
+			Scenario:    scenario,
+		}
+		if replay != nil {
+			job.Replay = replay.share(n, i)
+		}
+		for _, stage := range config.Stages {
+			job.Stages = append(job.Stages, Stage{Duration: stage.Duration, Target: splitCount(stage.Target, n, i)})
+		}
+		jobs[i] = job
+	}
+	return jobs, nil
+}
+
+// config rebuilds, on the agent, the Config of its share of the test
+func (j agentJob) config() (Config, error) {
+	config := Config{
+		URL:         j.URL,
+		Method:      j.Method,
+		Headers:     j.Headers,
+		Body:        j.Body,
+		Requests:    j.Requests,
+		Concurrency: j.Concurrency,
+		Duration:    j.Duration,
+		Rate:        j.Rate,
+		Stages:      j.Stages,
+		Assertions:  j.Assertions,
+		Client:      j.Client,
+		GRPC:        j.GRPC,
+		Replay:      j.Replay,
+	}
+	if config.Assertions != nil {
+		if err := config.Assertions.compile(); err != nil {
+			return Config{}, err
+		}
+	}
+	if j.Scenario != "" {
+		scenario, err := parseScenario([]byte(j.Scenario))
+		if err != nil {
+			return Config{}, err
+		}
+		config.Scenario = scenario
+	}
+	if config.GRPC != nil {
+		if config.GRPC.Assertions != nil {
+			if err := config.GRPC.Assertions.compile(); err != nil {
+				return Config{}, err
+			}
+		}
+		if err := config.GRPC.prepare(); err != nil {
+			return Config{}, err
+		}
+	}
+	if config.URL == "" && config.Scenario == nil && config.GRPC == nil && config.Replay == nil {
+		return Config{}, fmt.Errorf("job sem url")
+	}
+	if config.Replay != nil {
+		// O coordenador já limitou o replay a --requests antes de dividi-lo
+		config.Requests = 0
+		if len(config.Replay.Entries) == 0 {
+			return Config{}, fmt.Errorf("job de replay sem requests")
+		}
+	} else if config.Requests == 0 && config.Duration == 0 {
+		return Config{}, fmt.Errorf("job sem requests ou duração")
+	}
+	if config.Concurrency <= 0 && config.Rate <= 0 && len(config.Stages) == 0 && config.Replay == nil {
+		return Config{}, fmt.Errorf("job sem workers")
+	}
+	return config, nil
+}
+
+// runAgent serves the agent API until the process is stopped
+func runAgent(args []string) {
+	fs := flag.NewFlagSet("stresstest agent", flag.ExitOnError)
+	listen := fs.String("listen", ":9100", "Endereço onde o agente recebe os jobs do coordenador")
+	metricsAddr := fs.String("metrics-addr", "", "Endereço onde /metrics é exposto no formato Prometheus durante os jobs (ex: :9090)")
+	fs.Parse(args)
+
+	agent := &agentHandler{}
+	if *metricsAddr != "" {
+		agent.metrics = newLiveMetrics()
+		stopMetrics, err := agent.metrics.serve(*metricsAddr)
+		if err != nil {
+			log.Fatalf("Erro ao expor as métricas: %v", err)
+		}
+		defer stopMetrics()
+		log.Printf("Métricas em http://%s/metrics", *metricsAddr)
+	}
+
+	log.Printf("Agente aguardando jobs em %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, agent.handler()))
+}
+
+func newAgentHandler() http.Handler {
+	return (&agentHandler{}).handler()
+}
+
+// agentHandler runs the jobs sent by a coordinator, one at a time
+type agentHandler struct {
+	mu sync.Mutex
+	// metrics, when set, exposes the results of the jobs as they arrive
+	metrics *liveMetrics
+}
+
+func (a *agentHandler) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", a.run)
+	return mux
+}
+
+// run executes a job and answers with its report once it finishes. The
+// results are aggregated here, so the coordinator only merges one report per agent.
+func (a *agentHandler) run(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.mu.TryLock() {
+		http.Error(w, "agente ocupado com outro teste", http.StatusConflict)
+		return
+	}
+	defer a.mu.Unlock()
+
+	var job agentJob
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(w, "job inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	config, err := job.config()
+	if err != nil {
+		http.Error(w, "job inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// O job é interrompido se o coordenador desconectar
+	config.stop = r.Context().Done()
+	config.metrics = a.metrics
+
+	log.Printf("Executando job: %d requests, %d workers, duração %v", config.Requests, config.Concurrency, config.Duration)
+
+	startTime := time.Now()
+	results := make(chan Result, resultBufferSize(config))
+	go func() {
+		runLoad(config, startTime, results)
+		close(results)
+	}()
+
+	c := newCollector(config, startTime)
+	for result := range results {
+		c.add(result)
+		config.metrics.add(result)
+	}
+	if r.Context().Err() != nil {
+		log.Printf("Job interrompido após %v: coordenador desconectou", time.Since(startTime))
+		return
+	}
+	log.Printf("Job concluído em %v", time.Since(startTime))
+
+	report := c.report()
+	report.TotalTime = time.Since(startTime)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Erro ao enviar o relatório: %v", err)
+	}
+}
+
+// runDistributed splits the test among the agents and merges the reports
+// they return into report, which holds no results yet
+func runDistributed(config Config, report Report) Report {
+	agents := make([]AgentReport, len(config.Agents))
+	for i, agent := range config.Agents {
+		agents[i] = AgentReport{Address: agent, Summary: newSummaryBuilder().build()}
+	}
+
+	jobs, err := agentJobs(config)
+	if err != nil {
+		for i := range agents {
+			agents[i].Error = err.Error()
+		}
+		report.Agents = agents
+		return report
+	}
+
+	reports := make([]Report, len(config.Agents))
+	var wg sync.WaitGroup
+	for i, agent := range config.Agents {
+		wg.Add(1)
+		go func(i int, agent string, job agentJob) {
+			defer wg.Done()
+			agentReport, err := runAgentJob(agent, job)
+			if err != nil {
+				agents[i].Error = err.Error()
+				return
+			}
+			reports[i] = agentReport
+			agents[i].Summary = agentReport.Summary
+		}(i, agent, jobs[i])
+	}
+	wg.Wait()
+
+	merged := false
+	for i, agentReport := range reports {
+		if agents[i].Error != "" {
+			continue
+		}
+		if merged {
+			report = mergeReports(report, agentReport)
+		} else {
+			report.Summary = agentReport.Summary
+			report.Steps = agentReport.Steps
+			report.Stages = agentReport.Stages
+			report.TimeSeries = agentReport.TimeSeries
+			merged = true
+		}
+	}
+	report.Agents = agents
+	return report
+}
+
+// runAgentJob sends a job to an agent and returns the report of its share
+func runAgentJob(agent string, job agentJob) (Report, error) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// Sem timeout: a resposta só chega no fim do teste
+	resp, err := http.Post(agent+"/run", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return Report{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Report{}, fmt.Errorf("agente respondeu %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var report Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return Report{}, fmt.Errorf("relatório do agente inválido: %w", err)
+	}
+	return report, nil
+}
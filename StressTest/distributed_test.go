@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSplitCount(t *testing.T) {
+	tests := []struct {
+		total, n int
+		expected []int
+	}{
+		{10, 2, []int{5, 5}},
+		{10, 3, []int{4, 3, 3}},
+		{2, 3, []int{1, 1, 0}},
+		{0, 2, []int{0, 0}},
+	}
+
+	for _, tt := range tests {
+		sum := 0
+		for i, want := range tt.expected {
+			got := splitCount(tt.total, tt.n, i)
+			if got != want {
+				t.Errorf("splitCount(%d, %d, %d): expected %d, got %d", tt.total, tt.n, i, want, got)
+			}
+			sum += got
+		}
+		if sum != tt.total {
+			t.Errorf("splitCount(%d, %d): shares add up to %d", tt.total, tt.n, sum)
+		}
+	}
+}
+
+func TestParseAgents(t *testing.T) {
+	agents := parseAgents("host1:9100, http://host2:9100/ ,")
+	if len(agents) != 2 || agents[0] != "http://host1:9100" || agents[1] != "http://host2:9100" {
+		t.Errorf("Unexpected agents: %v", agents)
+	}
+}
+
+func TestAgentJobs(t *testing.T) {
+	scenario, err := parseScenario([]byte(`
+flows:
+  - steps:
+      - url: http://localhost/items
+        think_time: 150ms
+        expect:
+          status: [2xx]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{
+		Requests:    7,
+		Concurrency: 3,
+		Stages:      []Stage{{Duration: time.Second, Target: 5}},
+		Scenario:    scenario,
+		Agents:      []string{"http://a", "http://b"},
+	}
+	jobs, err := agentJobs(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jobs[0].Requests != 4 || jobs[1].Requests != 3 || jobs[0].Concurrency != 2 || jobs[1].Concurrency != 1 {
+		t.Errorf("Unexpected split: %+v / %+v", jobs[0], jobs[1])
+	}
+	if jobs[0].Stages[0].Target != 3 || jobs[1].Stages[0].Target != 2 {
+		t.Errorf("Unexpected stage split: %+v / %+v", jobs[0].Stages, jobs[1].Stages)
+	}
+
+	// The agent parses the scenario again from the YAML sent by the coordinator
+	agentConfig, err := jobs[1].config()
+	if err != nil {
+		t.Fatalf("Unexpected error rebuilding the job: %v", err)
+	}
+	step := agentConfig.Scenario.Flows[0].Steps[0]
+	if step.ThinkTime != 150*time.Millisecond || step.Expect == nil || step.Expect.Status[0] != "2xx" {
+		t.Errorf("Scenario not preserved: %+v", step)
+	}
+}
+
+func TestAgentJobsTooFewRequests(t *testing.T) {
+	config := Config{
+		URL:      "http://localhost",
+		Requests: 2,
+		Duration: time.Minute,
+		Rate:     10,
+		Agents:   []string{"http://a", "http://b", "http://c"},
+	}
+	if _, err := agentJobs(config); err == nil {
+		t.Fatal("Expected an error when an agent would get no requests")
+	}
+
+	config.Requests = 0
+	jobs, err := agentJobs(config)
+	if err != nil {
+		t.Fatalf("Unexpected error for a duration-only run: %v", err)
+	}
+	if jobs[2].Duration != time.Minute || jobs[2].Rate == 0 {
+		t.Errorf("Unexpected job: %+v", jobs[2])
+	}
+}
+
+func TestRunStressTestDistributed(t *testing.T) {
+	var served int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&served, 1)%5 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	agent1 := httptest.NewServer(newAgentHandler())
+	defer agent1.Close()
+	agent2 := httptest.NewServer(newAgentHandler())
+	defer agent2.Close()
+
+	report := runStressTest(Config{
+		URL:         target.URL,
+		Requests:    50,
+		Concurrency: 4,
+		Agents:      []string{agent1.URL, agent2.URL},
+	})
+
+	if report.TotalRequests != 50 {
+		t.Fatalf("Expected 50 requests, got %d", report.TotalRequests)
+	}
+	if report.StatusCounts[200] != 40 || report.StatusCounts[500] != 10 || report.SuccessCount != 40 {
+		t.Errorf("Unexpected status counts: %v (success %d)", report.StatusCounts, report.SuccessCount)
+	}
+	if len(report.AssertionFailures) != 1 || report.AssertionFailures[0].Count != 10 {
+		t.Errorf("Expected 10 status assertion failures, got %+v", report.AssertionFailures)
+	}
+	if len(report.Agents) != 2 || report.Agents[0].TotalRequests != 25 || report.Agents[1].TotalRequests != 25 {
+		t.Errorf("Expected 25 requests per agent, got %+v", report.Agents)
+	}
+
+	histogramTotal := 0
+	for _, bucket := range report.Histogram {
+		histogramTotal += bucket.Count
+	}
+	if histogramTotal != 50 {
+		t.Errorf("Expected merged histogram to count 50 requests, got %d", histogramTotal)
+	}
+	if len(report.TimeSeries) == 0 {
+		t.Error("Expected time series from the agents' results")
+	}
+	if p50, ok := report.Percentile(50); !ok || p50 <= 0 {
+		t.Errorf("Expected the merged p50, got %v", p50)
+	}
+	if report.Distribution != nil || report.Agents[0].Distribution != nil {
+		t.Error("Expected the latency distributions to be left out of the final report")
+	}
+}
+
+func TestRunStressTestDistributedAgentFailure(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	agent := httptest.NewServer(newAgentHandler())
+	defer agent.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := "http://" + listener.Addr().String()
+	listener.Close()
+
+	report := runStressTest(Config{
+		URL:         target.URL,
+		Requests:    10,
+		Concurrency: 2,
+		Agents:      []string{agent.URL, offline},
+	})
+
+	if report.TotalRequests != 5 {
+		t.Errorf("Expected only the live agent's 5 requests, got %d", report.TotalRequests)
+	}
+	if report.Agents[0].Error != "" || report.Agents[1].Error == "" {
+		t.Errorf("Expected only the offline agent to report an error, got %+v", report.Agents)
+	}
+}
+
+func TestAgentRejectsConcurrentJobs(t *testing.T) {
+	handler := &agentHandler{}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	recorder := httptest.NewRecorder()
+	handler.run(recorder, httptest.NewRequest(http.MethodPost, "/run", nil))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while busy, got %d", recorder.Code)
+	}
+}
+
+func TestAgentStopsWhenCoordinatorDisconnects(t *testing.T) {
+	for name, job := range map[string]agentJob{
+		"closed": {Concurrency: 2},
+		"rate":   {Rate: 50},
+		"stages": {Stages: []Stage{{Target: 2}, {Duration: time.Minute, Target: 2}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var served int64
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt64(&served, 1)
+				time.Sleep(5 * time.Millisecond)
+			}))
+			defer target.Close()
+
+			agent := httptest.NewServer(newAgentHandler())
+			defer agent.Close()
+
+			job.URL = target.URL
+			job.Method = http.MethodGet
+			job.Duration = time.Minute
+			payload, _ := json.Marshal(job)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, agent.URL+"/run", bytes.NewReader(payload))
+			done := make(chan error, 1)
+			go func() {
+				_, err := http.DefaultClient.Do(req)
+				done <- err
+			}()
+			// Wait for the job to reach the target before disconnecting
+			for atomic.LoadInt64(&served) == 0 {
+				time.Sleep(5 * time.Millisecond)
+			}
+			cancel()
+			if err := <-done; err == nil {
+				t.Fatal("Expected the request to the agent to be cancelled")
+			}
+
+			// The agent accepts a new job only after the previous one stopped
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				resp, err := http.Post(agent.URL+"/run", "application/json", bytes.NewReader([]byte("{")))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusConflict {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("Expected the agent to stop the job after the coordinator disconnected")
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			stopped := atomic.LoadInt64(&served)
+			time.Sleep(100 * time.Millisecond)
+			if now := atomic.LoadInt64(&served); now != stopped {
+				t.Errorf("Expected no requests after the job stopped, got %d more", now-stopped)
+			}
+		})
+	}
+}
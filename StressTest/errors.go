@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Error kinds, in the order they are reported
+const (
+	errorTimeout           = "timeout"
+	errorConnectionRefused = "connection_refused"
+	errorConnectionReset   = "connection_reset"
+	errorDNS               = "dns"
+	errorTLS               = "tls"
+	errorOther             = "other"
+)
+
+var errorKindOrder = []string{errorTimeout, errorConnectionRefused, errorConnectionReset, errorDNS, errorTLS, errorOther}
+
+var errorKindLabels = map[string]string{
+	errorTimeout:           "Timeout",
+	errorConnectionRefused: "Conexão recusada",
+	errorConnectionReset:   "Conexão encerrada pelo servidor",
+	errorDNS:               "DNS",
+	errorTLS:               "TLS",
+	errorOther:             "Outros",
+}
+
+// ErrorGroup counts the failed requests of one kind, keeping the first message as a sample
+type ErrorGroup struct {
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	Sample string `json:"sample"`
+}
+
+// classifyError maps a transport error to one of the error kinds
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return errorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorTimeout
+	case isTLSError(err):
+		return errorTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errorConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errorConnectionReset
+	default:
+		return errorOther
+	}
+}
+
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return true
+	}
+	// Alguns erros do handshake só são expostos como texto
+	return strings.Contains(err.Error(), "tls: ")
+}
+
+// errorGroups accumulates failed requests by kind. It also groups failed
+// assertions, keyed by assertion kind.
+type errorGroups map[string]*ErrorGroup
+
+func (g errorGroups) add(err error) {
+	g.addKind(classifyError(err), err.Error())
+}
+
+func (g errorGroups) addKind(kind, sample string) {
+	group, ok := g[kind]
+	if !ok {
+		group = &ErrorGroup{Kind: kind, Sample: sample}
+		g[kind] = group
+	}
+	group.Count++
+}
+
+// merge adds groups already counted elsewhere, keeping the first sample of each kind
+func (g errorGroups) merge(groups []ErrorGroup) {
+	for _, group := range groups {
+		if existing, ok := g[group.Kind]; ok {
+			existing.Count += group.Count
+		} else {
+			copied := group
+			g[group.Kind] = &copied
+		}
+	}
+}
+
+// list returns the groups in the report order
+func (g errorGroups) list() []ErrorGroup {
+	groups := make([]ErrorGroup, 0, len(g))
+	for _, group := range g {
+		groups = append(groups, *group)
+	}
+	order := make(map[string]int)
+	for i, kind := range append(errorKindOrder, assertionKindOrder...) {
+		order[kind] = i
+	}
+	sort.Slice(groups, func(i, j int) bool { return order[groups[i].Kind] < order[groups[j].Kind] })
+	return groups
+}
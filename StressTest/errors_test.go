@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"deadline", fmt.Errorf("request: %w", context.DeadlineExceeded), errorTimeout},
+		{"dns", &net.DNSError{Err: "no such host", Name: "invalid.local", IsNotFound: true}, errorDNS},
+		{"dns timeout", &net.DNSError{Err: "i/o timeout", Name: "slow.local", IsTimeout: true}, errorDNS},
+		{"other", errors.New("something else"), errorOther},
+	}
+
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// requestError makes one request and returns its transport error
+func requestError(t *testing.T, client *http.Client, url string) error {
+	t.Helper()
+	result := doRequest(client, RequestSpec{URL: url}, time.Now())
+	if result.Error == nil {
+		t.Fatalf("Expected request to %s to fail", url)
+	}
+	return result.Error
+}
+
+func TestClassifyTransportErrors(t *testing.T) {
+	t.Run("Connection refused", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := listener.Addr().String()
+		listener.Close()
+
+		err = requestError(t, newHTTPClient(ClientOptions{}), "http://"+addr)
+		if kind := classifyError(err); kind != errorConnectionRefused {
+			t.Errorf("Expected %s, got %s (%v)", errorConnectionRefused, kind, err)
+		}
+	})
+
+	t.Run("Connection reset", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			// SO_LINGER 0 faz o Close enviar um RST
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+		}))
+		defer server.Close()
+
+		err := requestError(t, newHTTPClient(ClientOptions{}), server.URL)
+		if kind := classifyError(err); kind != errorConnectionReset {
+			t.Errorf("Expected %s, got %s (%v)", errorConnectionReset, kind, err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		err := requestError(t, &http.Client{Timeout: 20 * time.Millisecond}, server.URL)
+		if kind := classifyError(err); kind != errorTimeout {
+			t.Errorf("Expected %s, got %s (%v)", errorTimeout, kind, err)
+		}
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		// Certificado autoassinado não é aceito pelo client padrão
+		err := requestError(t, newHTTPClient(ClientOptions{}), server.URL)
+		if kind := classifyError(err); kind != errorTLS {
+			t.Errorf("Expected %s, got %s (%v)", errorTLS, kind, err)
+		}
+	})
+}
+
+func TestSummaryErrorGroups(t *testing.T) {
+	builder := newSummaryBuilder()
+	builder.add(Result{Error: errors.New("first other")})
+	builder.add(Result{Error: errors.New("second other")})
+	builder.add(Result{Error: context.DeadlineExceeded})
+	builder.add(Result{StatusCode: 200})
+
+	summary := builder.build()
+
+	if len(summary.Errors) != 2 {
+		t.Fatalf("Expected 2 error groups, got %d", len(summary.Errors))
+	}
+	if summary.Errors[0].Kind != errorTimeout || summary.Errors[0].Count != 1 {
+		t.Errorf("Expected timeout group first, got %+v", summary.Errors[0])
+	}
+	if summary.Errors[1].Kind != errorOther || summary.Errors[1].Count != 2 || summary.Errors[1].Sample != "first other" {
+		t.Errorf("Unexpected other group: %+v", summary.Errors[1])
+	}
+}
+
+func TestSummaryLatencyExcludesErrors(t *testing.T) {
+	builder := newSummaryBuilder()
+	builder.add(Result{StatusCode: 200, Duration: 10 * time.Millisecond})
+	builder.add(Result{StatusCode: 500, Duration: 30 * time.Millisecond, AssertionError: errors.New("status 500")})
+	builder.add(Result{Error: context.DeadlineExceeded, Duration: 5 * time.Second})
+
+	summary := builder.build()
+
+	if summary.TotalRequests != 3 || summary.FailureCount != 1 {
+		t.Fatalf("Expected 3 requests with 1 failure, got %+v", summary)
+	}
+	// The timeout does not count in the latency, the failed assertion does
+	if summary.MaxDuration != 30*time.Millisecond || summary.AverageDuration != 20*time.Millisecond {
+		t.Errorf("Expected latency over the 2 responses, got max %v and average %v", summary.MaxDuration, summary.AverageDuration)
+	}
+	if p99, _ := summary.Percentile(99); p99 != 30*time.Millisecond {
+		t.Errorf("Expected p99 30ms, got %v", p99)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protocolGRPC marks the reports of gRPC runs, whose status codes are gRPC codes
+const protocolGRPC = "grpc"
+
+// defaultGRPCAssertions accepts only the OK status, the gRPC counterpart of 200
+var defaultGRPCAssertions = &Assertions{Status: []string{"0"}}
+
+// GRPCSpec describes the unary gRPC call sent on every iteration. The request
+// and response types are discovered through server reflection, so the
+// message is given in JSON and no generated code is needed.
+type GRPCSpec struct {
+	Target string `json:"target"`
+	// Method is the full method name: "pb.OrderService/CreateOrder"
+	Method             string        `json:"method"`
+	Message            []byte        `json:"message,omitempty"`
+	Metadata           http.Header   `json:"metadata,omitempty"`
+	TLS                bool          `json:"tls,omitempty"`
+	InsecureSkipVerify bool          `json:"insecure_skip_verify,omitempty"`
+	Timeout            time.Duration `json:"timeout_ns,omitempty"`
+	Assertions         *Assertions   `json:"assertions,omitempty"`
+
+	once       sync.Once
+	err        error
+	conn       *grpc.ClientConn
+	fullMethod string
+	method     protoreflect.MethodDescriptor
+	request    proto.Message
+	metadata   metadata.MD
+}
+
+// address identifies the call in the report
+func (s *GRPCSpec) address() string {
+	return "grpc://" + s.Target + "/" + strings.TrimPrefix(s.Method, "/")
+}
+
+// prepare connects to the target and resolves the method and request message.
+// It runs once; later calls return the same error.
+func (s *GRPCSpec) prepare() error {
+	s.once.Do(func() {
+		s.err = s.connect()
+	})
+	return s.err
+}
+
+func (s *GRPCSpec) connect() error {
+	service, method, err := splitGRPCMethod(s.Method)
+	if err != nil {
+		return err
+	}
+	s.fullMethod = "/" + service + "/" + method
+
+	creds := insecure.NewCredentials()
+	if s.TLS {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: s.InsecureSkipVerify})
+	}
+	s.conn, err = grpc.NewClient(s.Target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+	s.method, err = resolveGRPCMethod(ctx, s.conn, service, method)
+	if err != nil {
+		s.conn.Close()
+		return err
+	}
+
+	request := dynamicpb.NewMessage(s.method.Input())
+	if len(s.Message) > 0 {
+		if err := protojson.Unmarshal(s.Message, request); err != nil {
+			s.conn.Close()
+			return fmt.Errorf("mensagem inválida para %s: %w", s.method.Input().FullName(), err)
+		}
+	}
+	s.request = request
+
+	if len(s.Metadata) > 0 {
+		s.metadata = metadata.MD{}
+		for key, values := range s.Metadata {
+			s.metadata.Append(key, values...)
+		}
+	}
+	return nil
+}
+
+func (s *GRPCSpec) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func (s *GRPCSpec) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return defaultRequestTimeout
+	}
+	return s.Timeout
+}
+
+// Execute makes the call once. The HTTP client is not used: every worker
+// shares the gRPC connection, which multiplexes the calls.
+func (s *GRPCSpec) Execute(_ *http.Client, start time.Time, results chan<- Result) {
+	results <- s.call(start)
+}
+
+// call makes the call and checks the status and the response, in JSON,
+// against the assertions. The status code takes the place of the HTTP status.
+func (s *GRPCSpec) call(start time.Time) Result {
+	if err := s.prepare(); err != nil {
+		return Result{Start: start, Duration: time.Since(start), Error: err}
+	}
+
+	assertions := s.Assertions
+	if assertions == nil {
+		assertions = defaultGRPCAssertions
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+	if s.metadata != nil {
+		ctx = metadata.NewOutgoingContext(ctx, s.metadata)
+	}
+
+	reply := dynamicpb.NewMessage(s.method.Output())
+	err := s.conn.Invoke(ctx, s.fullMethod, s.request, reply)
+	result := Result{Start: start, Duration: time.Since(start), StatusCode: int(status.Code(err))}
+
+	var resp response
+	if err == nil && assertions.needsBody() {
+		body, marshalErr := protojson.Marshal(reply)
+		if marshalErr != nil {
+			result.Error = marshalErr
+			return result
+		}
+		resp.body = body
+	}
+
+	result.AssertionError = assertions.check(result, resp)
+	var failure *assertionError
+	if err != nil && errors.As(result.AssertionError, &failure) && failure.kind == assertionStatus {
+		// A mensagem do status explica a falha melhor que o código
+		failure.message = fmt.Sprintf("%s: %s", status.Code(err), status.Convert(err).Message())
+	}
+	return result
+}
+
+// splitGRPCMethod accepts "pkg.Service/Method", "/pkg.Service/Method" and
+// "pkg.Service.Method"
+func splitGRPCMethod(fullMethod string) (string, string, error) {
+	name := strings.TrimPrefix(strings.TrimSpace(fullMethod), "/")
+	service, method, found := strings.Cut(name, "/")
+	if !found {
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			service, method, found = name[:i], name[i+1:], true
+		}
+	}
+	if !found || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", fmt.Errorf("método gRPC inválido %q, use o formato pacote.Servico/Metodo", fullMethod)
+	}
+	return service, method, nil
+}
+
+// resolveGRPCMethod asks the server, through reflection, for the file that
+// declares the service and finds the method in it
+func resolveGRPCMethod(ctx context.Context, conn *grpc.ClientConn, service, method string) (protoreflect.MethodDescriptor, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reflexão do servidor indisponível: %w", err)
+	}
+	defer stream.CloseSend()
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reflexão do servidor indisponível: %w", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("reflexão do servidor indisponível: %w", err)
+	}
+	if failure := resp.GetErrorResponse(); failure != nil {
+		return nil, fmt.Errorf("serviço %s não encontrado: %s", service, failure.GetErrorMessage())
+	}
+
+	// A resposta traz o arquivo do serviço e todas as suas dependências
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, file); err != nil {
+			return nil, fmt.Errorf("descritor inválido recebido do servidor: %w", err)
+		}
+		set.File = append(set.File, file)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("descritor inválido recebido do servidor: %w", err)
+	}
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("serviço %s não encontrado: %w", service, err)
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s não é um serviço", service)
+	}
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(method))
+	if methodDescriptor == nil {
+		return nil, fmt.Errorf("método %s não encontrado no serviço %s", method, service)
+	}
+	if methodDescriptor.IsStreamingClient() || methodDescriptor.IsStreamingServer() {
+		return nil, fmt.Errorf("método %s/%s é de streaming, apenas chamadas unárias são suportadas", service, method)
+	}
+	return methodDescriptor, nil
+}
+
+// grpcStatusLabel formats a gRPC status code with its name: "14 (Unavailable)"
+func grpcStatusLabel(code int) string {
+	return fmt.Sprintf("%d (%s)", code, codes.Code(code))
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+// grpcTestServer serves the health service with reflection, recording the
+// metadata of the calls
+type grpcTestServer struct {
+	address string
+
+	mu       sync.Mutex
+	metadata metadata.MD
+}
+
+func startGRPCServer(t *testing.T) *grpcTestServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &grpcTestServer{address: listener.Addr().String()}
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		s.mu.Lock()
+		s.metadata = md
+		s.mu.Unlock()
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return s
+}
+
+func TestSplitGRPCMethod(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantService string
+		wantMethod  string
+		wantErr     bool
+	}{
+		{"pb.OrderService/CreateOrder", "pb.OrderService", "CreateOrder", false},
+		{"/pb.OrderService/CreateOrder", "pb.OrderService", "CreateOrder", false},
+		{"pb.OrderService.CreateOrder", "pb.OrderService", "CreateOrder", false},
+		{"CreateOrder", "", "", true},
+		{"pb.OrderService/", "", "", true},
+		{"a/b/c", "", "", true},
+	}
+
+	for _, tt := range tests {
+		service, method, err := splitGRPCMethod(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if service != tt.wantService || method != tt.wantMethod {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.input, tt.wantService, tt.wantMethod, service, method)
+		}
+	}
+}
+
+func TestGRPCStressTest(t *testing.T) {
+	server := startGRPCServer(t)
+
+	spec := &GRPCSpec{
+		Target:   server.address,
+		Method:   "grpc.health.v1.Health/Check",
+		Message:  []byte(`{"service": ""}`),
+		Metadata: http.Header{"Api_key": []string{"abc123"}},
+	}
+	if err := spec.prepare(); err != nil {
+		t.Fatalf("Unexpected prepare error: %v", err)
+	}
+
+	report := runStressTest(Config{Requests: 20, Concurrency: 4, GRPC: spec})
+
+	if report.Protocol != protocolGRPC || report.URL != "grpc://"+server.address+"/grpc.health.v1.Health/Check" {
+		t.Errorf("Unexpected report target: %s %s", report.Protocol, report.URL)
+	}
+	if report.TotalRequests != 20 || report.SuccessCount != 20 {
+		t.Errorf("Expected 20 successful calls, got %d of %d", report.SuccessCount, report.TotalRequests)
+	}
+	if report.StatusCounts[int(codes.OK)] != 20 {
+		t.Errorf("Expected 20 OK statuses, got %v", report.StatusCounts)
+	}
+
+	var out bytes.Buffer
+	if err := writeTextReport(&out, report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "status gRPC:\n  0 (OK): 20 requests") {
+		t.Errorf("Expected gRPC status codes in the report, got:\n%s", out.String())
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if got := server.metadata.Get("api_key"); len(got) != 1 || got[0] != "abc123" {
+		t.Errorf("Expected the api_key metadata, got %v", server.metadata)
+	}
+}
+
+func TestGRPCStatusAndAssertions(t *testing.T) {
+	server := startGRPCServer(t)
+
+	tests := []struct {
+		name       string
+		message    string
+		assertions *Assertions
+		wantStatus codes.Code
+		wantKind   string
+		wantSample string
+	}{
+		{"OK", `{}`, nil, codes.OK, "", ""},
+		{"Unknown service", `{"service": "missing"}`, nil, codes.NotFound, assertionStatus, "NotFound: unknown service"},
+		{"Expected status", `{"service": "missing"}`, &Assertions{Status: []string{"5"}}, codes.NotFound, "", ""},
+		{"JSON assertion", `{}`, &Assertions{Status: []string{"0"}, JSON: []string{"status=SERVING"}}, codes.OK, "", ""},
+		{"JSON assertion fails", `{}`, &Assertions{Status: []string{"0"}, JSON: []string{"status=NOT_SERVING"}}, codes.OK, assertionJSON, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.assertions != nil {
+				if err := tt.assertions.compile(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			spec := &GRPCSpec{
+				Target:     server.address,
+				Method:     "grpc.health.v1.Health/Check",
+				Message:    []byte(tt.message),
+				Assertions: tt.assertions,
+			}
+			defer spec.close()
+
+			result := spec.call(time.Now())
+			if result.Error != nil {
+				t.Fatalf("Unexpected error: %v", result.Error)
+			}
+			if codes.Code(result.StatusCode) != tt.wantStatus {
+				t.Errorf("Expected status %s, got %d", tt.wantStatus, result.StatusCode)
+			}
+			if tt.wantKind == "" {
+				if result.AssertionError != nil {
+					t.Errorf("Expected no failure, got %v", result.AssertionError)
+				}
+				return
+			}
+			failure, ok := result.AssertionError.(*assertionError)
+			if !ok || failure.kind != tt.wantKind {
+				t.Fatalf("Expected %s failure, got %v", tt.wantKind, result.AssertionError)
+			}
+			if !strings.Contains(failure.message, tt.wantSample) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantSample, failure.message)
+			}
+		})
+	}
+}
+
+func TestGRPCPrepareErrors(t *testing.T) {
+	server := startGRPCServer(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		message string
+		want    string
+	}{
+		{"Unknown service", "pb.OrderService/CreateOrder", "", "pb.OrderService"},
+		{"Unknown method", "grpc.health.v1.Health/Missing", "", "Missing"},
+		{"Streaming method", "grpc.health.v1.Health/Watch", "", "streaming"},
+		{"Invalid message", "grpc.health.v1.Health/Check", `{"unknown": 1}`, "mensagem inválida"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &GRPCSpec{Target: server.address, Method: tt.method, Message: []byte(tt.message)}
+			err := spec.prepare()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected error containing %q, got %v", tt.want, err)
+			}
+
+			// Cada chamada reporta o mesmo erro, sem tentar de novo
+			if result := spec.call(time.Now()); result.Error != err {
+				t.Errorf("Expected the prepare error, got %v", result.Error)
+			}
+		})
+	}
+}
+
+func TestParseFlagsGRPC(t *testing.T) {
+	config := parseFlagsFromArgs([]string{
+		"--grpc=localhost:50051",
+		"--grpc-method=pb.OrderService/CreateOrder",
+		`--body={"id":"a","price":10,"tax":1}`,
+		"--header=API_KEY: abc123",
+		"--expect-json=id",
+		"--timeout=2s",
+		"--requests=10",
+	})
+
+	spec := config.GRPC
+	if spec == nil {
+		t.Fatal("Expected gRPC mode")
+	}
+	if spec.Target != "localhost:50051" || spec.Method != "pb.OrderService/CreateOrder" || spec.TLS {
+		t.Errorf("Unexpected gRPC spec: %+v", spec)
+	}
+	if string(spec.Message) != `{"id":"a","price":10,"tax":1}` || spec.Metadata.Get("API_KEY") != "abc123" {
+		t.Errorf("Unexpected message or metadata: %s %v", spec.Message, spec.Metadata)
+	}
+	if spec.Timeout != 2*time.Second {
+		t.Errorf("Expected 2s timeout, got %v", spec.Timeout)
+	}
+	// Sem --expect-status o modo gRPC aceita apenas o status OK
+	if spec.Assertions == nil || len(spec.Assertions.Status) != 1 || spec.Assertions.Status[0] != "0" {
+		t.Errorf("Expected the OK status to be required, got %+v", spec.Assertions)
+	}
+	if _, ok := config.executor().(*GRPCSpec); !ok {
+		t.Errorf("Expected the gRPC executor, got %T", config.executor())
+	}
+}
+
+func TestRunStressTestDistributedGRPC(t *testing.T) {
+	server := startGRPCServer(t)
+
+	agent1 := httptest.NewServer(newAgentHandler())
+	defer agent1.Close()
+	agent2 := httptest.NewServer(newAgentHandler())
+	defer agent2.Close()
+
+	report := runStressTest(Config{
+		Requests:    10,
+		Concurrency: 2,
+		Agents:      []string{agent1.URL, agent2.URL},
+		GRPC:        &GRPCSpec{Target: server.address, Method: "grpc.health.v1.Health/Check"},
+	})
+
+	if report.Protocol != protocolGRPC || report.StatusCounts[int(codes.OK)] != 10 || report.SuccessCount != 10 {
+		t.Errorf("Expected 10 OK calls, got %v (success %d)", report.StatusCounts, report.SuccessCount)
+	}
+	for _, agent := range report.Agents {
+		if agent.Error != "" || agent.TotalRequests != 5 {
+			t.Errorf("Expected 5 calls per agent, got %+v", agent)
+		}
+	}
+}
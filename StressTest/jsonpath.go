@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath looks up a value in a JSON document using a simple dotted path,
+// such as "data.items[0].id", "$.id" or "0.id" for a top-level array.
+// Strings are returned as is; numbers, booleans, objects and arrays are JSON encoded.
+func jsonPath(document []byte, path string) (string, error) {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(document)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("resposta não é um JSON válido: %w", err)
+	}
+
+	for _, key := range splitJSONPath(path) {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("campo %q não encontrado em %q", key, path)
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", fmt.Errorf("índice %q inválido em %q", key, path)
+			}
+			value = node[index]
+		default:
+			return "", fmt.Errorf("caminho %q não existe no JSON", path)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "null", nil
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
+
+// splitJSONPath turns "$.data[0].id" into ["data", "0", "id"]
+func splitJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+
+	var keys []string
+	for _, key := range strings.Split(path, ".") {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestJSONPath(t *testing.T) {
+	document := []byte(`{"id": "abc", "total": 12.5, "active": true, "items": [{"id": 7}, {"id": 8}], "meta": {"tags": ["a"]}}`)
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"id", "abc"},
+		{"$.id", "abc"},
+		{"total", "12.5"},
+		{"active", "true"},
+		{"items[1].id", "8"},
+		{"items.0.id", "7"},
+		{"meta.tags", `["a"]`},
+	}
+
+	for _, tt := range tests {
+		got, err := jsonPath(document, tt.path)
+		if err != nil {
+			t.Errorf("jsonPath(%q) returned error: %v", tt.path, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("jsonPath(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+
+	for _, path := range []string{"missing", "items[5].id", "id.nested"} {
+		if _, err := jsonPath(document, path); err == nil {
+			t.Errorf("Expected error for path %q", path)
+		}
+	}
+
+	if got, err := jsonPath([]byte(`[{"id": "first"}]`), "[0].id"); err != nil || got != "first" {
+		t.Errorf("Expected top-level array lookup to return first, got %q (%v)", got, err)
+	}
+
+	if _, err := jsonPath([]byte("not json"), "id"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,38 +16,192 @@ import (
 // Config holds the CLI parameters
 type Config struct {
 	URL         string
+	Method      string
+	Headers     http.Header
+	Body        []byte
 	Requests    int
 	Concurrency int
+	Duration    time.Duration
+	Rate        float64
+	Stages      []Stage
+	Progress    bool
+	Assertions  *Assertions
+	Thresholds  []Threshold
+	Agents      []string
+	Client      ClientOptions
+	Scenario    *Scenario
+	GRPC        *GRPCSpec
+	Replay      *Replay
+	Format      string
+	Output      string
+	MetricsAddr string
+
+	// metrics is set while the /metrics endpoint is exposed
+	metrics *liveMetrics
+	// stop, when closed, ends the test before its requests or duration run out
+	stop <-chan struct{}
 }
 
 // Result holds the response information
 type Result struct {
+	Start      time.Time
+	Step       string
 	StatusCode int
 	Duration   time.Duration
 	Error      error
+	// AssertionError is set when the response arrived but failed an assertion
+	AssertionError error
+	// Timings is nil when no connection was obtained
+	Timings *Timings
+}
+
+// succeeded tells whether the request got a response that passed the assertions
+func (r Result) succeeded() bool {
+	return r.Error == nil && r.AssertionError == nil
 }
 
 // Report holds the test statistics
 type Report struct {
-	TotalTime       time.Duration
-	TotalRequests   int
-	StatusCounts    map[int]int
-	SuccessCount    int
-	FailureCount    int
-	AverageDuration time.Duration
+	URL string `json:"url"`
+	// Protocol is "grpc" when StatusCounts holds gRPC status codes
+	Protocol  string        `json:"protocol,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	TotalTime time.Duration `json:"total_time_ns"`
+	Summary
+	Assertions []string          `json:"assertions,omitempty"`
+	Thresholds []ThresholdResult `json:"thresholds,omitempty"`
+	Agents     []AgentReport     `json:"agents,omitempty"`
+	Steps      []StepReport      `json:"steps,omitempty"`
+	Stages     []StageReport     `json:"stages,omitempty"`
+	TimeSeries []TimeSeriesPoint `json:"time_series,omitempty"`
+}
+
+// Executor runs one iteration of the test, sending a Result for every request made
+type Executor interface {
+	Execute(client *http.Client, start time.Time, results chan<- Result)
+}
+
+// RequestsPerSecond returns the overall throughput of the run
+func (r Report) RequestsPerSecond() float64 {
+	if r.TotalTime <= 0 {
+		return 0
+	}
+	return float64(r.TotalRequests) / r.TotalTime.Seconds()
+}
+
+// ThresholdsPassed tells whether every threshold of the run was met
+func (r Report) ThresholdsPassed() bool {
+	for _, threshold := range r.Thresholds {
+		if !threshold.Passed {
+			return false
+		}
+	}
+	return true
 }
 
+// exitThresholdsFailed is the exit code when the run does not meet its thresholds
+const exitThresholdsFailed = 3
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "target" {
+		runTarget(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		runCompare(os.Args[2:])
+		return
+	}
+
 	config := parseFlags()
 
-	fmt.Printf("Starting stress test...\n")
-	fmt.Printf("URL: %s\n", config.URL)
-	fmt.Printf("Total requests: %d\n", config.Requests)
-	fmt.Printf("Concurrency: %d\n", config.Concurrency)
-	fmt.Println("---")
+	// No modo distribuído cada agente se conecta ao alvo gRPC por conta própria
+	if config.GRPC != nil && len(config.Agents) == 0 {
+		if err := config.GRPC.prepare(); err != nil {
+			log.Fatalf("Erro ao preparar a chamada gRPC: %v", err)
+		}
+	}
+
+	// Relatórios em formatos de máquina no stdout não podem ser misturados com o cabeçalho
+	info := os.Stdout
+	if config.Format != formatText && config.Output == "" {
+		info = os.Stderr
+	}
+
+	fmt.Fprintf(info, "Starting stress test...\n")
+	if config.Scenario != nil {
+		fmt.Fprintf(info, "Scenario: %s (%d flows)\n", config.Scenario.Name, len(config.Scenario.Flows))
+	}
+	if config.URL != "" {
+		fmt.Fprintf(info, "URL: %s\n", config.URL)
+	}
+	if config.GRPC != nil {
+		fmt.Fprintf(info, "gRPC: %s %s\n", config.GRPC.Target, config.GRPC.Method)
+	}
+	if config.Replay != nil {
+		fmt.Fprintf(info, "Replay: %s (%d requests em %v, velocidade %gx)\n",
+			config.Replay.Source, len(config.Replay.Entries), config.Replay.Duration(), config.Replay.Speed)
+		if config.Replay.Skipped > 0 {
+			fmt.Fprintf(info, "Replay: %d linhas ignoradas fora do formato Common/Combined\n", config.Replay.Skipped)
+		}
+	}
+	if config.Method != "" && config.Method != http.MethodGet {
+		fmt.Fprintf(info, "Method: %s\n", config.Method)
+	}
+	if config.Requests > 0 {
+		fmt.Fprintf(info, "Total requests: %d\n", config.Requests)
+	}
+	if config.Duration > 0 {
+		fmt.Fprintf(info, "Duration: %v\n", config.Duration)
+	}
+	if len(config.Agents) > 0 {
+		fmt.Fprintf(info, "Agents: %d\n", len(config.Agents))
+	}
+	if config.Rate > 0 {
+		fmt.Fprintf(info, "Rate: %.2f req/s\n", config.Rate)
+	} else if len(config.Stages) > 0 {
+		fmt.Fprintf(info, "Stages: %d (starting with %d workers)\n", len(config.Stages), config.Concurrency)
+	} else if config.Replay == nil {
+		// No replay o ritmo vem da gravação
+		fmt.Fprintf(info, "Concurrency: %d\n", config.Concurrency)
+	}
+	if config.MetricsAddr != "" {
+		config.metrics = newLiveMetrics()
+		stopMetrics, err := config.metrics.serve(config.MetricsAddr)
+		if err != nil {
+			log.Fatalf("Erro ao expor as métricas: %v", err)
+		}
+		defer stopMetrics()
+		fmt.Fprintf(info, "Metrics: http://%s/metrics\n", config.MetricsAddr)
+	}
+	fmt.Fprintln(info, "---")
 
 	report := runStressTest(config)
-	printReport(report)
+
+	out := os.Stdout
+	if config.Output != "" {
+		file, err := os.Create(config.Output)
+		if err != nil {
+			log.Fatalf("Erro ao criar arquivo de saída: %v", err)
+		}
+		out = file
+	}
+
+	err := writeReport(out, report, config.Format)
+	if config.Output != "" {
+		out.Close()
+	}
+	if err != nil {
+		log.Fatalf("Erro ao gerar relatório: %v", err)
+	}
+
+	if !report.ThresholdsPassed() {
+		fmt.Fprintln(os.Stderr, "Thresholds não atendidos")
+		os.Exit(exitThresholdsFailed)
+	}
 }
 
 func parseFlags() Config {
@@ -53,26 +210,213 @@ func parseFlags() Config {
 
 func parseFlagsFromArgs(args []string) Config {
 	var config Config
+	var headers headerFlag
+	var expectJSON, thresholds listFlag
+	var body, bodyFile, scenarioFile, stages, stepTest, agents string
+	var grpcTarget, grpcMethod string
+	var replayFile, replayFormat string
+	var replaySpeed float64
+	var expectStatus, expectBody, expectBodyRegex string
+	var maxLatency time.Duration
+	var keepAlive, insecure, grpcTLS bool
 
 	fs := flag.NewFlagSet("stresstest", flag.ExitOnError)
 	fs.StringVar(&config.URL, "url", "", "URL do serviço a ser testado")
-	fs.IntVar(&config.Requests, "requests", 0, "Número total de requests")
+	fs.StringVar(&config.Method, "method", http.MethodGet, "Método HTTP das requests")
+	fs.Var(&headers, "header", "Header HTTP no formato \"Nome: valor\" (pode ser repetido)")
+	fs.StringVar(&body, "body", "", "Corpo das requests")
+	fs.StringVar(&bodyFile, "body-file", "", "Arquivo com o corpo das requests")
+	fs.StringVar(&grpcTarget, "grpc", "", "Endereço do serviço gRPC a ser testado (ex: localhost:50051)")
+	fs.StringVar(&grpcMethod, "grpc-method", "", "Método gRPC no formato pacote.Servico/Metodo (ex: pb.OrderService/CreateOrder)")
+	fs.BoolVar(&grpcTLS, "grpc-tls", false, "Usa TLS na conexão gRPC")
+	fs.StringVar(&replayFile, "replay", "", "Arquivo HAR ou access log (Common/Combined) cujo tráfego será reproduzido")
+	fs.StringVar(&replayFormat, "replay-format", replayFormatAuto, "Formato do arquivo de --replay: auto, har ou log")
+	fs.Float64Var(&replaySpeed, "replay-speed", 1, "Velocidade do replay em relação ao tráfego original (ex: 2 reproduz em metade do tempo)")
+	fs.StringVar(&scenarioFile, "scenario", "", "Arquivo YAML/JSON com um cenário de múltiplas etapas")
+	fs.IntVar(&config.Requests, "requests", 0, "Número total de requests (iterações, no modo cenário)")
 	fs.IntVar(&config.Concurrency, "concurrency", 1, "Número de chamadas simultâneas")
+	fs.DurationVar(&config.Duration, "duration", 0, "Duração do teste (ex: 30s, 5m)")
+	fs.Float64Var(&config.Rate, "rate", 0, "Taxa constante de requests por segundo (modelo aberto)")
+	fs.StringVar(&stages, "stages", "", "Estágios de carga duração:workers separados por vírgula (ex: 30s:50,2m:50,10s:200)")
+	fs.StringVar(&stepTest, "step-test", "", "Step test inicio:incremento:maximo:duracao (ex: 10:10:100:30s)")
+	fs.StringVar(&expectStatus, "expect-status", "", "Status aceitos como sucesso separados por vírgula (ex: 200,201,2xx; padrão: 200, ou 0 no modo gRPC)")
+	fs.StringVar(&expectBody, "expect-body", "", "Texto que o corpo da resposta deve conter")
+	fs.StringVar(&expectBodyRegex, "expect-body-regex", "", "Regex que o corpo da resposta deve satisfazer")
+	fs.Var(&expectJSON, "expect-json", "JSON path que deve existir (ex: id) ou ter um valor (ex: status=ok) (pode ser repetido)")
+	fs.DurationVar(&maxLatency, "max-latency", 0, "Latência máxima para um request contar como sucesso (ex: 500ms)")
+	fs.Var(&thresholds, "threshold", "Critério de aprovação do teste (ex: p95<300ms, errors<1%) (pode ser repetido)")
+	fs.DurationVar(&config.Client.Timeout, "timeout", defaultRequestTimeout, "Timeout de cada request")
+	fs.BoolVar(&keepAlive, "keep-alive", true, "Reutiliza conexões entre requests (keep-alive)")
+	fs.IntVar(&config.Client.MaxConnsPerHost, "max-conns-per-host", 0, "Máximo de conexões simultâneas por host (0: sem limite)")
+	fs.StringVar(&config.Client.HTTPVersion, "http-version", httpVersionAuto, "Força a versão do HTTP: 1.1 ou 2 (padrão: negociada)")
+	fs.BoolVar(&insecure, "insecure", false, "Não verifica o certificado TLS do servidor (certificados autoassinados)")
+	fs.StringVar(&agents, "agents", "", "Agentes que executam o teste, separados por vírgula (ex: host1:9100,host2:9100)")
+	fs.BoolVar(&config.Progress, "progress", true, "Exibe o progresso do teste no stderr a cada segundo")
+	fs.StringVar(&config.Format, "format", formatText, "Formato do relatório: text, json, csv ou junit")
+	fs.StringVar(&config.Output, "output", "", "Arquivo onde o relatório será gravado (padrão: stdout)")
+	fs.StringVar(&config.MetricsAddr, "metrics-addr", "", "Endereço onde /metrics é exposto no formato Prometheus durante o teste (ex: :9090)")
 
 	fs.Parse(args)
 
-	if config.URL == "" {
+	if scenarioFile != "" {
+		scenario, err := loadScenario(scenarioFile)
+		if err != nil {
+			log.Fatalf("Erro ao carregar --scenario: %v", err)
+		}
+		config.Scenario = scenario
+		if config.URL == "" {
+			config.URL = scenario.BaseURL
+		}
+	}
+	if grpcTarget != "" {
+		if config.URL != "" || config.Scenario != nil {
+			log.Fatal("Parâmetro --grpc não pode ser combinado com --url ou --scenario")
+		}
+		if grpcMethod == "" {
+			log.Fatal("Parâmetro --grpc-method é obrigatório no modo gRPC")
+		}
+		if _, _, err := splitGRPCMethod(grpcMethod); err != nil {
+			log.Fatalf("Erro em --grpc-method: %v", err)
+		}
+		config.GRPC = &GRPCSpec{Target: grpcTarget, Method: grpcMethod, TLS: grpcTLS}
+	}
+	if replayFile != "" {
+		if config.Scenario != nil || config.GRPC != nil {
+			log.Fatal("Parâmetro --replay não pode ser combinado com --scenario ou --grpc")
+		}
+		if replaySpeed <= 0 {
+			log.Fatal("Parâmetro --replay-speed deve ser maior que 0")
+		}
+		replay, err := loadReplay(replayFile, replayFormat)
+		if err != nil {
+			log.Fatalf("Erro ao carregar --replay: %v", err)
+		}
+		// Com --url o tráfego gravado é enviado para outro serviço
+		if err := replay.rebase(config.URL); err != nil {
+			log.Fatalf("Erro em --replay: %v", err)
+		}
+		replay.Speed = replaySpeed
+		config.Replay = replay
+	}
+	if config.URL == "" && config.Scenario == nil && config.GRPC == nil && config.Replay == nil {
 		log.Fatal("Parâmetro --url é obrigatório")
 	}
-	if config.Requests <= 0 {
-		log.Fatal("Parâmetro --requests deve ser maior que 0")
+	if expectStatus != "" || expectBody != "" || expectBodyRegex != "" || len(expectJSON) > 0 || maxLatency != 0 {
+		assertions := &Assertions{
+			Body:       expectBody,
+			BodyRegex:  expectBodyRegex,
+			JSON:       expectJSON,
+			MaxLatency: maxLatency,
+		}
+		if expectStatus != "" {
+			assertions.Status = strings.Split(expectStatus, ",")
+		} else if config.GRPC != nil {
+			assertions.Status = defaultGRPCAssertions.Status
+		}
+		if err := assertions.compile(); err != nil {
+			log.Fatalf("Erro nas verificações: %v", err)
+		}
+		config.Assertions = assertions
+		// No modo cenário as verificações valem para as etapas sem "expect" próprio
+		if config.Scenario != nil && config.Scenario.Expect == nil {
+			config.Scenario.Expect = assertions
+		}
+	}
+	for _, raw := range thresholds {
+		threshold, err := parseThreshold(raw)
+		if err != nil {
+			log.Fatalf("Erro em --threshold: %v", err)
+		}
+		config.Thresholds = append(config.Thresholds, threshold)
+	}
+	config.Method = strings.ToUpper(config.Method)
+	config.Headers = headers.header
+	if body != "" && bodyFile != "" {
+		log.Fatal("Use apenas um dos parâmetros --body ou --body-file")
+	}
+	if body != "" {
+		config.Body = []byte(body)
+	}
+	if bodyFile != "" {
+		data, err := os.ReadFile(bodyFile)
+		if err != nil {
+			log.Fatalf("Erro ao ler --body-file: %v", err)
+		}
+		config.Body = data
+	}
+	if stages != "" && stepTest != "" {
+		log.Fatal("Use apenas um dos parâmetros --stages ou --step-test")
+	}
+	if stages != "" || stepTest != "" {
+		var err error
+		if stages != "" {
+			config.Stages, err = parseStages(stages)
+		} else {
+			config.Stages, err = parseStepTest(stepTest)
+		}
+		if err != nil {
+			log.Fatalf("Erro nos estágios de carga: %v", err)
+		}
+		if config.Rate > 0 || config.Duration > 0 || config.Replay != nil {
+			log.Fatal("Estágios de carga não podem ser combinados com --rate, --duration ou --replay")
+		}
+		config.Duration = stagesDuration(config.Stages)
+	}
+	if config.Requests < 0 || config.Duration < 0 {
+		log.Fatal("Parâmetros --requests e --duration não podem ser negativos")
+	}
+	if config.Requests == 0 && config.Duration == 0 && config.Replay == nil {
+		log.Fatal("Parâmetro --requests deve ser maior que 0 (ou informe --duration)")
 	}
 	if config.Concurrency <= 0 {
 		log.Fatal("Parâmetro --concurrency deve ser maior que 0")
 	}
-	if config.Concurrency > config.Requests {
+	if config.Rate < 0 {
+		log.Fatal("Parâmetro --rate não pode ser negativo")
+	}
+	if config.Replay != nil && config.Rate > 0 {
+		log.Fatal("Parâmetro --rate não pode ser combinado com --replay (use --replay-speed)")
+	}
+	config.Client.DisableKeepAlives = !keepAlive
+	config.Client.InsecureSkipVerify = insecure
+	if config.Client.Timeout <= 0 {
+		log.Fatal("Parâmetro --timeout deve ser maior que 0")
+	}
+	if config.Client.MaxConnsPerHost < 0 {
+		log.Fatal("Parâmetro --max-conns-per-host não pode ser negativo")
+	}
+	if !isValidHTTPVersion(config.Client.HTTPVersion) {
+		log.Fatalf("Parâmetro --http-version inválido: %s (use 1.1 ou 2)", config.Client.HTTPVersion)
+	}
+	if !isValidFormat(config.Format) {
+		log.Fatalf("Parâmetro --format inválido: %s", config.Format)
+	}
+	if config.GRPC != nil {
+		// A mensagem e a metadata da chamada vêm de --body e --header
+		config.GRPC.Message = config.Body
+		config.GRPC.Metadata = config.Headers
+		config.GRPC.Timeout = config.Client.Timeout
+		config.GRPC.InsecureSkipVerify = config.Client.InsecureSkipVerify
+		config.GRPC.Assertions = config.Assertions
+	}
+	if config.Requests > 0 && len(config.Stages) == 0 && config.Concurrency > config.Requests {
 		config.Concurrency = config.Requests
 	}
+	if agents != "" {
+		config.Agents = parseAgents(agents)
+		// Cada agente precisa de pelo menos um worker
+		if config.Rate == 0 && config.Replay == nil && config.Concurrency < len(config.Agents) {
+			log.Fatalf("Parâmetro --concurrency (e --requests) deve ser pelo menos o número de agentes (%d)", len(config.Agents))
+		}
+		// E de pelo menos um request, já que 0 requests significa sem limite
+		if config.Replay == nil && config.Requests > 0 && config.Requests < len(config.Agents) {
+			log.Fatalf("Parâmetro --requests deve ser pelo menos o número de agentes (%d)", len(config.Agents))
+		}
+		// Os resultados só chegam ao coordenador no fim do teste
+		if config.MetricsAddr != "" {
+			log.Fatal("Parâmetro --metrics-addr não é suportado com --agents; use o --metrics-addr de cada agente")
+		}
+	}
 
 	return config
 }
@@ -80,97 +424,282 @@ func parseFlagsFromArgs(args []string) Config {
 func runStressTest(config Config) Report {
 	startTime := time.Now()
 
+	var report Report
+	if len(config.Agents) > 0 {
+		// Os agentes devolvem o relatório de sua parte no fim do teste
+		report = runDistributed(config, newCollector(config, startTime).report())
+	} else {
+		report = runLocal(config, startTime)
+	}
+	report.TotalTime = time.Since(startTime)
+	report.dropDistributions()
+
+	if config.Assertions != nil {
+		report.Assertions = config.Assertions.descriptions()
+	}
+	report.Thresholds = evaluateThresholds(config.Thresholds, report)
+
+	return report
+}
+
+// runLocal runs the test in this process, collecting the results as they arrive
+func runLocal(config Config, startTime time.Time) Report {
+	// Channel para coletar resultados, consumido enquanto o teste executa
+	results := make(chan Result, resultBufferSize(config))
+	collected := make(chan Report)
+
+	var tracker *progress
+	var stopProgress, progressDone chan struct{}
+	if config.Progress {
+		tracker = newProgress(startTime)
+		stopProgress, progressDone = make(chan struct{}), make(chan struct{})
+		go tracker.run(os.Stderr, time.Second, stopProgress, progressDone)
+	}
+
+	go func() {
+		c := newCollector(config, startTime)
+		for result := range results {
+			c.add(result)
+			if tracker != nil {
+				tracker.add(result)
+			}
+			config.metrics.add(result)
+		}
+		collected <- c.report()
+	}()
+
+	runLoad(config, startTime, results)
+	close(results)
+
+	if tracker != nil {
+		close(stopProgress)
+		<-progressDone
+	}
+
+	return <-collected
+}
+
+// runLoad runs the load model chosen by the config, sending every result to results
+func runLoad(config Config, startTime time.Time, results chan<- Result) {
+	if config.GRPC != nil {
+		defer config.GRPC.close()
+	}
+
+	switch {
+	case config.Replay != nil:
+		runReplay(config, startTime, results)
+	case config.Rate > 0:
+		runOpenModel(config, startTime, results)
+	case len(config.Stages) > 0:
+		runStagedModel(config, startTime, results)
+	default:
+		runClosedModel(config, results)
+	}
+}
+
+// runClosedModel keeps config.Concurrency workers busy: a new request only
+// starts after a worker finishes the previous one
+func runClosedModel(config Config, results chan<- Result) {
 	// Channel para distribuir trabalho
-	jobs := make(chan int, config.Requests)
-	// Channel para coletar resultados
-	results := make(chan Result, config.Requests)
+	jobs := make(chan int)
 
-	// Criar workers
+	// Criar workers, que compartilham o client e suas conexões
+	client := newHTTPClient(config.clientOptions())
+	config.metrics.setWorkers(config.Concurrency)
+	defer config.metrics.setWorkers(0)
 	var wg sync.WaitGroup
 	for w := 0; w < config.Concurrency; w++ {
 		wg.Add(1)
-		go worker(config.URL, jobs, results, &wg)
+		go worker(client, config.executor(), jobs, results, &wg)
 	}
 
-	// Enviar jobs
-	go func() {
-		for i := 0; i < config.Requests; i++ {
-			jobs <- i
-		}
-		close(jobs)
-	}()
+	go produceJobs(config, jobs)
 
 	// Aguardar workers terminarem
 	wg.Wait()
-	close(results)
+}
 
-	// Processar resultados
-	report := Report{
-		TotalTime:     time.Since(startTime),
-		TotalRequests: config.Requests,
-		StatusCounts:  make(map[int]int),
-	}
+// produceJobs sends jobs until the request count or the duration is reached
+func produceJobs(config Config, jobs chan<- int) {
+	defer close(jobs)
 
-	var totalDuration time.Duration
+	var deadline <-chan time.Time
+	if config.Duration > 0 {
+		timer := time.NewTimer(config.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
 
-	for result := range results {
-		if result.Error == nil {
-			report.StatusCounts[result.StatusCode]++
-			if result.StatusCode == 200 {
-				report.SuccessCount++
-			}
-			totalDuration += result.Duration
-		} else {
-			report.FailureCount++
+	for i := 0; config.Requests == 0 || i < config.Requests; i++ {
+		select {
+		case jobs <- i:
+		case <-deadline:
+			return
+		case <-config.stop:
+			return
 		}
 	}
+}
 
-	if report.TotalRequests > 0 {
-		report.AverageDuration = totalDuration / time.Duration(report.TotalRequests)
+// runOpenModel fires requests at a constant rate, independent of how long the
+// responses take. Each request runs in its own goroutine and its latency is
+// measured from the instant it was scheduled, so a slow server cannot delay
+// the next requests and hide its own latency (coordinated omission).
+func runOpenModel(config Config, startTime time.Time, results chan<- Result) {
+	client := newHTTPClient(config.clientOptions())
+	executor := config.executor()
+	interval := time.Duration(float64(time.Second) / config.Rate)
+
+	var wg sync.WaitGroup
+	for i := 0; config.Requests == 0 || i < config.Requests; i++ {
+		scheduled := startTime.Add(time.Duration(i) * interval)
+		if config.Duration > 0 && scheduled.Sub(startTime) >= config.Duration {
+			break
+		}
+
+		// Se o agendador estiver atrasado o request sai imediatamente,
+		// mas a latência continua sendo medida a partir do horário agendado
+		if !waitUntil(scheduled, config.stop) {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			executor.Execute(client, scheduled, results)
+		}()
 	}
 
-	return report
+	wg.Wait()
 }
 
-func worker(url string, jobs <-chan int, results chan<- Result, wg *sync.WaitGroup) {
-	defer wg.Done()
+// waitUntil sleeps until t. It returns false, without waiting further, if
+// stop is closed first.
+func waitUntil(t time.Time, stop <-chan struct{}) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	select {
+	case <-stop:
+		return false
+	default:
 	}
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
 
-	for range jobs {
-		start := time.Now()
+func worker(client *http.Client, executor Executor, jobs <-chan int, results chan<- Result, wg *sync.WaitGroup) {
+	stoppableWorker(client, executor, jobs, nil, results, wg)
+}
 
-		resp, err := client.Get(url)
-		duration := time.Since(start)
+// stoppableWorker runs jobs until the jobs channel is closed or stop is closed
+func stoppableWorker(client *http.Client, executor Executor, jobs <-chan int, stop <-chan struct{}, results chan<- Result, wg *sync.WaitGroup) {
+	defer wg.Done()
 
-		result := Result{
-			Duration: duration,
-			Error:    err,
+	for {
+		select {
+		case <-stop:
+			return
+		case _, ok := <-jobs:
+			if !ok {
+				return
+			}
+			executor.Execute(client, time.Now(), results)
 		}
+	}
+}
 
-		if err == nil {
-			result.StatusCode = resp.StatusCode
-			resp.Body.Close()
-		}
+// executor returns what each iteration runs: the scenario or the gRPC call,
+// if one was given, or the single request described by the CLI parameters
+func (c Config) executor() Executor {
+	var executor Executor = c.requestSpec()
+	if c.Scenario != nil {
+		executor = c.Scenario
+	} else if c.GRPC != nil {
+		executor = c.GRPC
+	}
+	return c.instrument(executor)
+}
 
-		results <- result
+// instrument wraps the executor to update the live metrics, when exposed
+func (c Config) instrument(executor Executor) Executor {
+	if c.metrics != nil {
+		return instrumentedExecutor{Executor: executor, metrics: c.metrics}
 	}
+	return executor
+}
+
+// requestSpec returns the request described by the CLI parameters
+func (c Config) requestSpec() RequestSpec {
+	return RequestSpec{
+		Method:     c.Method,
+		URL:        c.URL,
+		Header:     c.Headers,
+		Body:       c.Body,
+		Assertions: c.Assertions,
+	}
+}
+
+// doRequest performs a single request, measuring its duration from start
+func doRequest(client *http.Client, spec RequestSpec, start time.Time) Result {
+	result, _ := performRequest(client, spec, start, false)
+	return result
 }
 
-func printReport(report Report) {
-	fmt.Println("\n=== RELATÓRIO DO TESTE DE CARGA ===")
-	fmt.Printf("Tempo total de execução: %v\n", report.TotalTime)
-	fmt.Printf("Total de requests realizados: %d\n", report.TotalRequests)
-	fmt.Printf("Requests com status 200 (sucesso): %d\n", report.SuccessCount)
-	fmt.Printf("Tempo médio por request: %v\n", report.AverageDuration)
-	fmt.Printf("Requests por segundo: %.2f\n", float64(report.TotalRequests)/report.TotalTime.Seconds())
+// response keeps the parts of an HTTP response needed after the request
+type response struct {
+	header http.Header
+	body   []byte
+}
 
-	fmt.Println("\nDistribuição de códigos de status HTTP:")
-	for statusCode, count := range report.StatusCounts {
-		fmt.Printf("  %d: %d requests\n", statusCode, count)
+// performRequest sends the request and checks the response against the
+// assertions of the spec. The body is read before the duration is measured
+// when keepBody is set or an assertion needs it.
+func performRequest(client *http.Client, spec RequestSpec, start time.Time, keepBody bool) (Result, response) {
+	assertions := spec.Assertions
+	if assertions == nil {
+		assertions = defaultAssertions
 	}
 
-	fmt.Println("\n=== FIM DO RELATÓRIO ===")
+	req, err := spec.newRequest()
+	if err != nil {
+		return Result{Start: start, Duration: time.Since(start), Error: err}, response{}
+	}
+
+	trace := newTimingTrace(time.Now())
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Start: start, Duration: time.Since(start), Error: err, Timings: trace.timings()}, response{}
+	}
+	defer resp.Body.Close()
+
+	result := Result{Start: start, StatusCode: resp.StatusCode, Timings: trace.timings()}
+	kept := response{header: resp.Header}
+	if keepBody || assertions.needsBody() {
+		kept.body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			result.Error = err
+		}
+	}
+	result.Duration = time.Since(start)
+
+	if result.Error == nil {
+		result.AssertionError = assertions.check(result, kept)
+	}
+
+	return result, kept
+}
+
+// resultBufferSize sizes the results channel so workers rarely wait on the collector
+func resultBufferSize(config Config) int {
+	if config.Requests > 0 && config.Requests < 10000 {
+		return config.Requests
+	}
+	return 10000
 }
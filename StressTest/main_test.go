@@ -33,6 +33,14 @@ func TestParseFlagsFromArgs(t *testing.T) {
 			expectedCon: 5, // Should be adjusted to requests count
 			shouldFail:  false,
 		},
+		{
+			name:        "Duration without requests",
+			args:        []string{"--url=http://example.com", "--duration=10s", "--concurrency=10"},
+			expectedURL: "http://example.com",
+			expectedReq: 0,
+			expectedCon: 10, // Not limited when there is no request count
+			shouldFail:  false,
+		},
 		{
 			name:        "Default concurrency",
 			args:        []string{"--url=http://example.com", "--requests=100"},
@@ -147,6 +155,95 @@ func TestRunStressTest(t *testing.T) {
 	}
 }
 
+func TestRunStressTestDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := Config{
+		URL:         server.URL,
+		Duration:    300 * time.Millisecond,
+		Concurrency: 2,
+	}
+
+	report := runStressTest(config)
+
+	if report.TotalRequests == 0 {
+		t.Fatal("Expected requests to be made during the test duration")
+	}
+	if report.SuccessCount != report.TotalRequests {
+		t.Errorf("Expected all %d requests to succeed, got %d", report.TotalRequests, report.SuccessCount)
+	}
+	if report.TotalTime < config.Duration {
+		t.Errorf("Expected the test to run for at least %v, got %v", config.Duration, report.TotalTime)
+	}
+	if report.TotalTime > config.Duration+time.Second {
+		t.Errorf("Expected the test to stop shortly after %v, got %v", config.Duration, report.TotalTime)
+	}
+
+	seriesTotal := 0
+	for _, point := range report.TimeSeries {
+		seriesTotal += point.Requests
+	}
+	if seriesTotal != report.TotalRequests {
+		t.Errorf("Expected the time series to hold %d requests, got %d", report.TotalRequests, seriesTotal)
+	}
+}
+
+func TestRunStressTestRate(t *testing.T) {
+	// Slow server: a closed model with one worker would take 10 * 300ms
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := Config{
+		URL:         server.URL,
+		Requests:    10,
+		Rate:        20,
+		Concurrency: 1,
+	}
+
+	report := runStressTest(config)
+
+	if report.TotalRequests != config.Requests {
+		t.Fatalf("Expected %d total requests, got %d", config.Requests, report.TotalRequests)
+	}
+	if report.SuccessCount != config.Requests {
+		t.Errorf("Expected %d successful requests, got %d", config.Requests, report.SuccessCount)
+	}
+	// 9 intervals of 50ms plus the server latency, far from the 3s of a closed loop
+	if report.TotalTime > 2*time.Second {
+		t.Errorf("Expected requests to be fired on schedule, test took %v", report.TotalTime)
+	}
+	if report.MinDuration < 300*time.Millisecond {
+		t.Errorf("Expected latency to include the server delay, got min %v", report.MinDuration)
+	}
+}
+
+func TestRunStressTestRateWithDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := Config{
+		URL:      server.URL,
+		Duration: 500 * time.Millisecond,
+		Rate:     40,
+	}
+
+	report := runStressTest(config)
+
+	// 500ms at 40 req/s schedules exactly 20 requests
+	if report.TotalRequests != 20 {
+		t.Errorf("Expected 20 requests, got %d", report.TotalRequests)
+	}
+}
+
 func TestWorker(t *testing.T) {
 	// Create a test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -169,7 +266,7 @@ func TestWorker(t *testing.T) {
 	go func() {
 		var wg sync.WaitGroup
 		wg.Add(1)
-		worker(server.URL, jobs, results, &wg)
+		worker(newHTTPClient(ClientOptions{}), RequestSpec{URL: server.URL}, jobs, results, &wg)
 		wg.Wait()
 		close(results)
 	}()
@@ -214,7 +311,7 @@ func TestWorkerWithTimeout(t *testing.T) {
 	go func() {
 		var wg sync.WaitGroup
 		wg.Add(1)
-		worker(server.URL, jobs, results, &wg)
+		worker(newHTTPClient(ClientOptions{}), RequestSpec{URL: server.URL}, jobs, results, &wg)
 		wg.Wait()
 		close(results)
 	}()
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// statusError is the status label of the requests that got no response
+const statusError = "error"
+
+// liveMetrics exposes the results of a running test in the Prometheus
+// format. A nil *liveMetrics is valid and records nothing, so the load
+// models can update it unconditionally.
+type liveMetrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec
+	errors            *prometheus.CounterVec
+	assertionFailures *prometheus.CounterVec
+	duration          *prometheus.HistogramVec
+	inFlight          prometheus.Gauge
+	workers           prometheus.Gauge
+}
+
+func newLiveMetrics() *liveMetrics {
+	buckets := make([]float64, len(histogramBounds))
+	for i, bound := range histogramBounds {
+		buckets[i] = bound.Seconds()
+	}
+
+	m := &liveMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stresstest_requests_total",
+			Help: "Requests finalizados, por status (\"error\" quando não houve resposta) e etapa do cenário",
+		}, []string{"status", "step"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stresstest_errors_total",
+			Help: "Requests sem resposta, por tipo de erro",
+		}, []string{"kind"}),
+		assertionFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stresstest_assertion_failures_total",
+			Help: "Respostas reprovadas nas verificações, por tipo de verificação",
+		}, []string{"kind"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "stresstest_request_duration_seconds",
+			Help:    "Latência dos requests que tiveram resposta, por etapa do cenário",
+			Buckets: buckets,
+		}, []string{"step"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "stresstest_in_flight",
+			Help: "Iterações em andamento (requests, ou fluxos no modo cenário)",
+		}),
+		workers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "stresstest_workers",
+			Help: "Workers ativos (modelo fechado e estágios)",
+		}),
+	}
+	m.registry.MustRegister(m.requests, m.errors, m.assertionFailures, m.duration, m.inFlight, m.workers)
+	return m
+}
+
+func (m *liveMetrics) add(result Result) {
+	if m == nil {
+		return
+	}
+
+	status := statusError
+	if result.Error != nil {
+		m.errors.WithLabelValues(classifyError(result.Error)).Inc()
+	} else {
+		status = strconv.Itoa(result.StatusCode)
+		if result.AssertionError != nil {
+			kind := assertionStatus
+			var failure *assertionError
+			if errors.As(result.AssertionError, &failure) {
+				kind = failure.kind
+			}
+			m.assertionFailures.WithLabelValues(kind).Inc()
+		}
+		m.duration.WithLabelValues(result.Step).Observe(result.Duration.Seconds())
+	}
+	m.requests.WithLabelValues(status, result.Step).Inc()
+}
+
+func (m *liveMetrics) setWorkers(n int) {
+	if m != nil {
+		m.workers.Set(float64(n))
+	}
+}
+
+func (m *liveMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// serve exposes /metrics on addr until the returned function is called
+func (m *liveMetrics) serve(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.handler())
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Erro no endpoint de métricas: %v", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// instrumentedExecutor keeps the in-flight gauge up to date
+type instrumentedExecutor struct {
+	Executor
+	metrics *liveMetrics
+}
+
+func (e instrumentedExecutor) Execute(client *http.Client, start time.Time, results chan<- Result) {
+	e.metrics.inFlight.Inc()
+	defer e.metrics.inFlight.Dec()
+	e.Executor.Execute(client, start, results)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func scrapeMetrics(t *testing.T, metrics *liveMetrics) string {
+	t.Helper()
+
+	server := httptest.NewServer(metrics.handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestLiveMetrics(t *testing.T) {
+	var calls int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1)%4 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	metrics := newLiveMetrics()
+	runStressTest(Config{URL: target.URL, Requests: 20, Concurrency: 1, metrics: metrics})
+
+	body := scrapeMetrics(t, metrics)
+	expected := []string{
+		`stresstest_requests_total{status="200",step=""} 15`,
+		`stresstest_requests_total{status="500",step=""} 5`,
+		`stresstest_assertion_failures_total{kind="status"} 5`,
+		`stresstest_request_duration_seconds_count{step=""} 20`,
+		`stresstest_request_duration_seconds_bucket{step="",le="30"} 20`,
+		`stresstest_in_flight 0`,
+		`stresstest_workers 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in the metrics:\n%s", line, body)
+		}
+	}
+}
+
+func TestLiveMetricsInFlight(t *testing.T) {
+	arrived := make(chan struct{}, 3)
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	metrics := newLiveMetrics()
+	done := make(chan struct{})
+	go func() {
+		runStressTest(Config{URL: target.URL, Requests: 3, Concurrency: 3, metrics: metrics})
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		<-arrived
+	}
+	body := scrapeMetrics(t, metrics)
+	close(release)
+	<-done
+
+	for _, line := range []string{"stresstest_in_flight 3", "stresstest_workers 3"} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q while the requests are running:\n%s", line, body)
+		}
+	}
+	if body := scrapeMetrics(t, metrics); !strings.Contains(body, "stresstest_in_flight 0") {
+		t.Errorf("Expected no requests in flight after the run:\n%s", body)
+	}
+}
+
+func TestLiveMetricsErrors(t *testing.T) {
+	metrics := newLiveMetrics()
+	metrics.add(Result{Error: context.DeadlineExceeded})
+
+	body := scrapeMetrics(t, metrics)
+	for _, line := range []string{`stresstest_errors_total{kind="timeout"} 1`, `stresstest_requests_total{status="error",step=""} 1`} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in the metrics:\n%s", line, body)
+		}
+	}
+
+	// Um *liveMetrics nil não registra nada
+	var disabled *liveMetrics
+	disabled.add(Result{})
+	disabled.setWorkers(1)
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported report formats
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatJUnit = "junit"
+)
+
+// reportMetric is a single named value of the report, used by the flat formats
+type reportMetric struct {
+	Name  string
+	Value string
+}
+
+func isValidFormat(format string) bool {
+	switch format {
+	case formatText, formatJSON, formatCSV, formatJUnit:
+		return true
+	}
+	return false
+}
+
+// writeReport writes the report to w in the requested format
+func writeReport(w io.Writer, report Report, format string) error {
+	switch format {
+	case formatText:
+		return writeTextReport(w, report)
+	case formatJSON:
+		return writeJSONReport(w, report)
+	case formatCSV:
+		return writeCSVReport(w, report)
+	case formatJUnit:
+		return writeJUnitReport(w, report)
+	default:
+		return fmt.Errorf("formato de relatório desconhecido: %s", format)
+	}
+}
+
+func writeTextReport(w io.Writer, report Report) error {
+	fmt.Fprintln(w, "\n=== RELATÓRIO DO TESTE DE CARGA ===")
+	fmt.Fprintf(w, "Tempo total de execução: %v\n", report.TotalTime)
+	fmt.Fprintf(w, "Total de requests realizados: %d\n", report.TotalRequests)
+	if len(report.Assertions) == 0 && report.Protocol == protocolGRPC {
+		fmt.Fprintf(w, "Chamadas com status OK (sucesso): %d\n", report.SuccessCount)
+	} else if len(report.Assertions) == 0 {
+		fmt.Fprintf(w, "Requests com status 200 (sucesso): %d\n", report.SuccessCount)
+	} else {
+		fmt.Fprintf(w, "Requests com sucesso: %d\n", report.SuccessCount)
+		fmt.Fprintf(w, "Verificações: %s\n", strings.Join(report.Assertions, "; "))
+	}
+	fmt.Fprintf(w, "Tempo médio por request: %v\n", report.AverageDuration)
+	fmt.Fprintf(w, "Requests por segundo: %.2f\n", report.RequestsPerSecond())
+
+	fmt.Fprintln(w, "\nLatência:")
+	fmt.Fprintf(w, "  Mínima: %v\n", report.MinDuration)
+	fmt.Fprintf(w, "  Máxima: %v\n", report.MaxDuration)
+	for _, p := range report.Percentiles {
+		fmt.Fprintf(w, "  %s: %v\n", quantileLabel(p.Quantile), p.Value)
+	}
+
+	fmt.Fprintln(w, "\nHistograma de latência:")
+	for _, bucket := range report.Histogram {
+		if bucket.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-18s %8d %s\n", bucketLabel(bucket), bucket.Count, histogramBar(bucket.Count, report.TotalRequests))
+	}
+
+	if report.Connections != nil {
+		fmt.Fprintf(w, "\nConexões: %d novas, %d reutilizadas\n", report.Connections.New, report.Connections.Reused)
+		fmt.Fprintf(w, "  %-22s %9s %12s %12s %12s %12s %12s\n", "fase", "requests", "média", "p50", "p95", "p99", "máxima")
+		for _, phase := range report.Connections.Phases {
+			fmt.Fprintf(w, "  %-22s %9d %12v %12v %12v %12v %12v\n", phaseLabels[phase.Phase], phase.Count,
+				phase.Average.Round(time.Microsecond), phase.P50.Round(time.Microsecond), phase.P95.Round(time.Microsecond),
+				phase.P99.Round(time.Microsecond), phase.Max.Round(time.Microsecond))
+		}
+	}
+
+	if report.Protocol == protocolGRPC {
+		fmt.Fprintln(w, "\nDistribuição de códigos de status gRPC:")
+		for _, statusCode := range sortedStatusCodes(report.StatusCounts) {
+			fmt.Fprintf(w, "  %s: %d requests\n", grpcStatusLabel(statusCode), report.StatusCounts[statusCode])
+		}
+	} else {
+		fmt.Fprintln(w, "\nDistribuição de códigos de status HTTP:")
+		for _, statusCode := range sortedStatusCodes(report.StatusCounts) {
+			fmt.Fprintf(w, "  %d: %d requests\n", statusCode, report.StatusCounts[statusCode])
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(w, "\nErros por tipo:")
+		for _, group := range report.Errors {
+			fmt.Fprintf(w, "  %s: %d requests\n", errorKindLabels[group.Kind], group.Count)
+			fmt.Fprintf(w, "    exemplo: %s\n", group.Sample)
+		}
+	}
+
+	if len(report.AssertionFailures) > 0 {
+		fmt.Fprintln(w, "\nFalhas de verificação:")
+		for _, group := range report.AssertionFailures {
+			fmt.Fprintf(w, "  %s: %d requests\n", assertionKindLabels[group.Kind], group.Count)
+			fmt.Fprintf(w, "    exemplo: %s\n", group.Sample)
+		}
+	}
+
+	if len(report.Agents) > 0 {
+		fmt.Fprintln(w, "\nResultados por agente:")
+		for _, agent := range report.Agents {
+			p95, _ := agent.Percentile(95)
+			fmt.Fprintf(w, "  %s\n", agent.Address)
+			fmt.Fprintf(w, "    requests: %d | sucesso: %d | falhas: %d | média: %v | p95: %v\n",
+				agent.TotalRequests, agent.SuccessCount, agent.FailureCount, agent.AverageDuration, p95)
+			if agent.Error != "" {
+				fmt.Fprintf(w, "    erro do agente: %s\n", agent.Error)
+			}
+		}
+	}
+
+	if len(report.Stages) > 0 {
+		fmt.Fprintln(w, "\nResultados por estágio:")
+		for _, stage := range report.Stages {
+			p95, _ := stage.Percentile(95)
+			p99, _ := stage.Percentile(99)
+			fmt.Fprintf(w, "  %d. %d → %d workers em %v\n", stage.Index, stage.From, stage.To, stage.Duration)
+			fmt.Fprintf(w, "    requests: %d | req/s: %.2f | erros: %.2f%% | média: %v | p95: %v | p99: %v\n",
+				stage.TotalRequests, stage.RequestsPerSecond, stage.ErrorRate()*100, stage.AverageDuration, p95, p99)
+		}
+	}
+
+	if len(report.TimeSeries) > 0 {
+		fmt.Fprintln(w, "\nSérie temporal (por segundo):")
+		fmt.Fprintf(w, "  %5s %9s %7s %12s %12s  %s\n", "seg", "requests", "erros", "média", "p95", "status")
+		for _, point := range report.TimeSeries {
+			fmt.Fprintf(w, "  %5d %9d %7d %12v %12v  %s\n", point.Second, point.Requests, point.Requests-point.SuccessCount,
+				point.AverageDuration.Round(time.Microsecond), point.P95.Round(time.Microsecond), formatStatusCounts(point.StatusCounts))
+		}
+	}
+
+	if len(report.Steps) > 0 {
+		fmt.Fprintln(w, "\nResultados por etapa:")
+		for _, step := range report.Steps {
+			p95, _ := step.Percentile(95)
+			p99, _ := step.Percentile(99)
+			fmt.Fprintf(w, "  %s\n", step.Name)
+			fmt.Fprintf(w, "    requests: %d | sucesso: %d | falhas: %d | média: %v | p95: %v | p99: %v\n",
+				step.TotalRequests, step.SuccessCount, step.FailureCount, step.AverageDuration, p95, p99)
+		}
+	}
+
+	if len(report.Thresholds) > 0 {
+		fmt.Fprintln(w, "\nThresholds:")
+		for _, threshold := range report.Thresholds {
+			status := "OK"
+			if !threshold.Passed {
+				status = "FALHOU"
+			}
+			fmt.Fprintf(w, "  [%s] %s (atual: %s)\n", status, threshold.Expression, threshold.Actual)
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "\n=== FIM DO RELATÓRIO ===")
+	return err
+}
+
+func writeJSONReport(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeCSVReport writes one "metric,value" row per report metric
+func writeCSVReport(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"metric", "value"}); err != nil {
+		return err
+	}
+	for _, metric := range reportMetrics(report) {
+		if err := writer.Write([]string{metric.Name, metric.Value}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// JUnit XML structures, following the schema understood by most CI servers
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// writeJUnitReport writes a JUnit summary where the run is a test suite,
+// the report metrics are suite properties, and the request outcome and each
+// threshold are test cases
+func writeJUnitReport(w io.Writer, report Report) error {
+	testCase := junitTestCase{
+		ClassName: "stresstest",
+		Name:      "requests",
+		Time:      formatSeconds(report.TotalTime),
+	}
+	if failed := report.TotalRequests - report.SuccessCount; failed > 0 {
+		content := fmt.Sprintf("erros de transporte: %d, falhas de verificação: %d", report.FailureCount, failed-report.FailureCount)
+		for _, group := range report.Errors {
+			content += fmt.Sprintf("\n%s: %d (%s)", group.Kind, group.Count, group.Sample)
+		}
+		for _, group := range report.AssertionFailures {
+			content += fmt.Sprintf("\nassertion %s: %d (%s)", group.Kind, group.Count, group.Sample)
+		}
+		testCase.Failure = &junitFailure{
+			Message: fmt.Sprintf("%d de %d requests sem sucesso", failed, report.TotalRequests),
+			Type:    "RequestFailure",
+			Content: content,
+		}
+	}
+
+	suite := junitTestSuite{
+		Name:      "stresstest",
+		Time:      formatSeconds(report.TotalTime),
+		Timestamp: report.StartedAt.Format(time.RFC3339),
+		TestCases: []junitTestCase{testCase},
+	}
+	for _, threshold := range report.Thresholds {
+		tc := junitTestCase{ClassName: "stresstest.thresholds", Name: threshold.Expression, Time: "0"}
+		if !threshold.Passed {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%s não atendido (atual: %s)", threshold.Expression, threshold.Actual),
+				Type:    "ThresholdFailure",
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	for _, metric := range reportMetrics(report) {
+		suite.Properties = append(suite.Properties, junitProperty{Name: metric.Name, Value: metric.Value})
+	}
+	for _, tc := range suite.TestCases {
+		suite.Tests++
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// reportMetrics flattens the report into named values. Durations are in milliseconds.
+func reportMetrics(report Report) []reportMetric {
+	metrics := []reportMetric{
+		{"url", report.URL},
+		{"started_at", report.StartedAt.Format(time.RFC3339)},
+		{"total_time_ms", formatMillis(report.TotalTime)},
+		{"total_requests", strconv.Itoa(report.TotalRequests)},
+		{"success_count", strconv.Itoa(report.SuccessCount)},
+		{"failure_count", strconv.Itoa(report.FailureCount)},
+		{"requests_per_second", strconv.FormatFloat(report.RequestsPerSecond(), 'f', 2, 64)},
+		{"average_duration_ms", formatMillis(report.AverageDuration)},
+		{"min_duration_ms", formatMillis(report.MinDuration)},
+		{"max_duration_ms", formatMillis(report.MaxDuration)},
+	}
+
+	for _, p := range report.Percentiles {
+		metrics = append(metrics, reportMetric{quantileLabel(p.Quantile) + "_ms", formatMillis(p.Value)})
+	}
+
+	for _, statusCode := range sortedStatusCodes(report.StatusCounts) {
+		metrics = append(metrics, reportMetric{"status_" + strconv.Itoa(statusCode), strconv.Itoa(report.StatusCounts[statusCode])})
+	}
+
+	for _, group := range report.Errors {
+		metrics = append(metrics, reportMetric{"errors." + group.Kind, strconv.Itoa(group.Count)})
+	}
+
+	if report.Connections != nil {
+		metrics = append(metrics,
+			reportMetric{"connections.new", strconv.Itoa(report.Connections.New)},
+			reportMetric{"connections.reused", strconv.Itoa(report.Connections.Reused)},
+		)
+		for _, phase := range report.Connections.Phases {
+			prefix := "phase." + phase.Phase + "."
+			metrics = append(metrics,
+				reportMetric{prefix + "count", strconv.Itoa(phase.Count)},
+				reportMetric{prefix + "average_ms", formatMillis(phase.Average)},
+				reportMetric{prefix + "p50_ms", formatMillis(phase.P50)},
+				reportMetric{prefix + "p95_ms", formatMillis(phase.P95)},
+				reportMetric{prefix + "p99_ms", formatMillis(phase.P99)},
+				reportMetric{prefix + "max_ms", formatMillis(phase.Max)},
+			)
+		}
+	}
+
+	for _, group := range report.AssertionFailures {
+		metrics = append(metrics, reportMetric{"assertion_failures." + group.Kind, strconv.Itoa(group.Count)})
+	}
+
+	for _, threshold := range report.Thresholds {
+		outcome := "passed"
+		if !threshold.Passed {
+			outcome = "failed"
+		}
+		metrics = append(metrics, reportMetric{"threshold." + threshold.Expression, outcome})
+	}
+
+	for _, bucket := range report.Histogram {
+		name := "histogram_le_" + formatMillis(bucket.To) + "_ms"
+		if bucket.To == 0 {
+			name = "histogram_gt_" + formatMillis(bucket.From) + "_ms"
+		}
+		metrics = append(metrics, reportMetric{name, strconv.Itoa(bucket.Count)})
+	}
+
+	for _, stage := range report.Stages {
+		prefix := "stage." + strconv.Itoa(stage.Index) + "."
+		metrics = append(metrics,
+			reportMetric{prefix + "from", strconv.Itoa(stage.From)},
+			reportMetric{prefix + "to", strconv.Itoa(stage.To)},
+			reportMetric{prefix + "total_requests", strconv.Itoa(stage.TotalRequests)},
+			reportMetric{prefix + "requests_per_second", strconv.FormatFloat(stage.RequestsPerSecond, 'f', 2, 64)},
+			reportMetric{prefix + "error_rate", strconv.FormatFloat(stage.ErrorRate(), 'f', 4, 64)},
+		)
+		for _, p := range stage.Percentiles {
+			metrics = append(metrics, reportMetric{prefix + quantileLabel(p.Quantile) + "_ms", formatMillis(p.Value)})
+		}
+	}
+
+	for _, point := range report.TimeSeries {
+		prefix := "second." + strconv.Itoa(point.Second) + "."
+		metrics = append(metrics,
+			reportMetric{prefix + "requests", strconv.Itoa(point.Requests)},
+			reportMetric{prefix + "success_count", strconv.Itoa(point.SuccessCount)},
+			reportMetric{prefix + "failure_count", strconv.Itoa(point.FailureCount)},
+			reportMetric{prefix + "average_duration_ms", formatMillis(point.AverageDuration)},
+			reportMetric{prefix + "p95_ms", formatMillis(point.P95)},
+		)
+		for _, statusCode := range sortedStatusCodes(point.StatusCounts) {
+			metrics = append(metrics, reportMetric{prefix + "status_" + strconv.Itoa(statusCode), strconv.Itoa(point.StatusCounts[statusCode])})
+		}
+	}
+
+	for i, agent := range report.Agents {
+		prefix := "agent." + strconv.Itoa(i+1) + "."
+		metrics = append(metrics,
+			reportMetric{prefix + "address", agent.Address},
+			reportMetric{prefix + "total_requests", strconv.Itoa(agent.TotalRequests)},
+			reportMetric{prefix + "success_count", strconv.Itoa(agent.SuccessCount)},
+			reportMetric{prefix + "failure_count", strconv.Itoa(agent.FailureCount)},
+		)
+		if agent.Error != "" {
+			metrics = append(metrics, reportMetric{prefix + "error", agent.Error})
+		}
+	}
+
+	for _, step := range report.Steps {
+		prefix := "step." + step.Name + "."
+		metrics = append(metrics,
+			reportMetric{prefix + "total_requests", strconv.Itoa(step.TotalRequests)},
+			reportMetric{prefix + "success_count", strconv.Itoa(step.SuccessCount)},
+			reportMetric{prefix + "failure_count", strconv.Itoa(step.FailureCount)},
+			reportMetric{prefix + "average_duration_ms", formatMillis(step.AverageDuration)},
+		)
+		for _, p := range step.Percentiles {
+			metrics = append(metrics, reportMetric{prefix + quantileLabel(p.Quantile) + "_ms", formatMillis(p.Value)})
+		}
+	}
+
+	return metrics
+}
+
+func sortedStatusCodes(counts map[int]int) []int {
+	codes := make([]int, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// formatStatusCounts formats status counts as "200:10 500:2"
+func formatStatusCounts(counts map[int]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, statusCode := range sortedStatusCodes(counts) {
+		parts = append(parts, fmt.Sprintf("%d:%d", statusCode, counts[statusCode]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// quantileLabel formats a quantile as p50, p99.9, etc.
+func quantileLabel(q float64) string {
+	return "p" + strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// bucketLabel describes the latency range of a histogram bucket
+func bucketLabel(bucket HistogramBucket) string {
+	if bucket.To == 0 {
+		return fmt.Sprintf("> %v", bucket.From)
+	}
+	return fmt.Sprintf("%v - %v", bucket.From, bucket.To)
+}
+
+// histogramBar draws a bar proportional to the share of requests in a bucket
+func histogramBar(count, total int) string {
+	const width = 40
+	if total == 0 {
+		return ""
+	}
+	return strings.Repeat("#", count*width/total)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleReport() Report {
+	durations := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		400 * time.Millisecond,
+	}
+	return Report{
+		URL:       "http://example.com",
+		StartedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		TotalTime: 2 * time.Second,
+		Summary: Summary{
+			TotalRequests: 4,
+			StatusCounts:  map[int]int{200: 2, 500: 1},
+			SuccessCount:  2,
+			FailureCount:  1,
+			LatencyStats:  computeLatencyStats(durations),
+		},
+	}
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	report := sampleReport()
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, report, formatJSON); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	if decoded.TotalRequests != report.TotalRequests {
+		t.Errorf("Expected %d total requests, got %d", report.TotalRequests, decoded.TotalRequests)
+	}
+	if decoded.StatusCounts[500] != 1 {
+		t.Errorf("Expected one 500 response, got %d", decoded.StatusCounts[500])
+	}
+	if decoded.MaxDuration != 400*time.Millisecond {
+		t.Errorf("Expected max duration 400ms, got %v", decoded.MaxDuration)
+	}
+	if p99, _ := decoded.Percentile(99); p99 != 400*time.Millisecond {
+		t.Errorf("Expected p99 400ms, got %v", p99)
+	}
+	if len(decoded.Histogram) != len(report.Histogram) {
+		t.Errorf("Expected %d histogram buckets, got %d", len(report.Histogram), len(decoded.Histogram))
+	}
+	if !strings.Contains(buf.String(), `"total_time_ns": 2000000000`) {
+		t.Errorf("Expected total time in nanoseconds, got %s", buf.String())
+	}
+}
+
+func TestWriteCSVReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, sampleReport(), formatCSV); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+
+	values := make(map[string]string)
+	for _, row := range rows[1:] {
+		values[row[0]] = row[1]
+	}
+
+	expected := map[string]string{
+		"total_requests":          "4",
+		"requests_per_second":     "2.00",
+		"p50_ms":                  "20.000",
+		"p99.9_ms":                "400.000",
+		"status_500":              "1",
+		"histogram_le_500.000_ms": "1",
+	}
+	for name, want := range expected {
+		if values[name] != want {
+			t.Errorf("Expected %s = %s, got %q", name, want, values[name])
+		}
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, sampleReport(), formatJUnit); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+
+	if len(suites.Suites) != 1 {
+		t.Fatalf("Expected one test suite, got %d", len(suites.Suites))
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 1 || suite.Failures != 1 {
+		t.Errorf("Expected 1 test with 1 failure, got %d tests and %d failures", suite.Tests, suite.Failures)
+	}
+	if suite.TestCases[0].Failure == nil {
+		t.Fatal("Expected the requests test case to fail")
+	}
+	if suite.Time != "2.000" {
+		t.Errorf("Expected suite time 2.000, got %s", suite.Time)
+	}
+}
+
+func TestWriteJUnitReportThresholds(t *testing.T) {
+	report := sampleReport()
+	report.Thresholds = []ThresholdResult{
+		{Expression: "p95<1s", Actual: "400ms", Passed: true},
+		{Expression: "errors<1%", Actual: "50.00%", Passed: false},
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, report, formatJUnit); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 2 {
+		t.Errorf("Expected 3 tests with 2 failures, got %d tests and %d failures", suite.Tests, suite.Failures)
+	}
+	if tc := suite.TestCases[2]; tc.Name != "errors<1%" || tc.Failure == nil {
+		t.Errorf("Expected failed threshold test case, got %+v", tc)
+	}
+}
+
+func TestReportMetricsUnique(t *testing.T) {
+	report := sampleReport()
+	report.Thresholds = []ThresholdResult{{Expression: "p95<1s", Actual: "400ms", Passed: true}}
+	report.Agents = []AgentReport{
+		{Address: "http://host1:9100", Summary: Summary{TotalRequests: 2, SuccessCount: 2}},
+		{Address: "http://host2:9100", Error: "connection refused"},
+	}
+	report.Steps = []StepReport{{Name: "login"}, {Name: "checkout"}}
+	report.Stages = []StageReport{{Index: 1, To: 10}, {Index: 2, From: 10, To: 20}}
+	report.TimeSeries = []TimeSeriesPoint{{Second: 1, StatusCounts: map[int]int{200: 1}}, {Second: 2}}
+
+	seen := make(map[string]bool)
+	for _, metric := range reportMetrics(report) {
+		if seen[metric.Name] {
+			t.Errorf("Metric %q reported more than once", metric.Name)
+		}
+		seen[metric.Name] = true
+	}
+	for _, want := range []string{"agent.1.address", "agent.2.error", "step.checkout.total_requests", "stage.2.to", "second.1.status_200"} {
+		if !seen[want] {
+			t.Errorf("Expected metric %q", want)
+		}
+	}
+}
+
+func TestWriteTextReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, sampleReport(), formatText); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"RELATÓRIO DO TESTE DE CARGA", "p99.9: 400ms", "500: 1 requests"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected text report to contain %q", want)
+		}
+	}
+}
+
+func TestWriteReportUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, sampleReport(), "yaml"); err == nil {
+		t.Fatal("Expected error for unknown format")
+	}
+}
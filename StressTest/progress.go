@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressWindow is the period used for the rolling RPS and p95 of the progress line
+const progressWindow = 5 * time.Second
+
+type progressSample struct {
+	finished  time.Time
+	duration  time.Duration
+	completed bool // whether the request got a response
+}
+
+// progress tracks the results of a running test and periodically prints a
+// one-line summary: requests done, current RPS, error rate and rolling p95
+type progress struct {
+	mu        sync.Mutex
+	startTime time.Time
+	total     int
+	failed    int
+	recent    []progressSample
+}
+
+func newProgress(startTime time.Time) *progress {
+	return &progress{startTime: startTime}
+}
+
+func (p *progress) add(result Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.total++
+	if !result.succeeded() {
+		p.failed++
+	}
+	p.recent = append(p.recent, progressSample{
+		finished:  result.Start.Add(result.Duration),
+		duration:  result.Duration,
+		completed: result.Error == nil,
+	})
+}
+
+// line summarizes the progress at now, discarding samples older than the window
+func (p *progress) line(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cutoff := now.Add(-progressWindow)
+	kept := p.recent[:0]
+	for _, sample := range p.recent {
+		if sample.finished.After(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	p.recent = kept
+
+	elapsed := now.Sub(p.startTime)
+	window := progressWindow
+	if elapsed < window {
+		window = elapsed
+	}
+
+	durations := make([]time.Duration, 0, len(kept))
+	for _, sample := range kept {
+		if sample.completed {
+			durations = append(durations, sample.duration)
+		}
+	}
+	stats := computeLatencyStats(durations)
+	p95, _ := stats.Percentile(95)
+
+	var rps, errorRate float64
+	if window > 0 {
+		rps = float64(len(kept)) / window.Seconds()
+	}
+	if p.total > 0 {
+		errorRate = float64(p.failed) / float64(p.total) * 100
+	}
+
+	return fmt.Sprintf("[%6s] %d requests | %.1f req/s | erros %.2f%% | p95 %v",
+		elapsed.Truncate(time.Second), p.total, rps, errorRate, p95.Round(time.Microsecond))
+}
+
+// run prints the progress line every interval until stop is closed. On a
+// terminal the line is rewritten in place; otherwise one line is printed per interval.
+func (p *progress) run(w io.Writer, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	terminal := isTerminal(w)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			if terminal {
+				fmt.Fprintln(w)
+			}
+			return
+		case now := <-ticker.C:
+			if terminal {
+				fmt.Fprintf(w, "\r%s\033[K", p.line(now))
+			} else {
+				fmt.Fprintln(w, p.line(now))
+			}
+		}
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
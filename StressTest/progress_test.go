@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	start := time.Now().Add(-10 * time.Second)
+	p := newProgress(start)
+
+	// Old sample: counted in the totals but outside the rolling window
+	p.add(Result{Start: start, Duration: 900 * time.Millisecond, StatusCode: 200})
+	for i := 0; i < 9; i++ {
+		p.add(Result{Start: start.Add(8 * time.Second), Duration: 10 * time.Millisecond, StatusCode: 200})
+	}
+	p.add(Result{Start: start.Add(8 * time.Second), Duration: 10 * time.Millisecond, Error: errors.New("timeout")})
+
+	line := p.line(start.Add(10 * time.Second))
+
+	for _, want := range []string{"[   10s]", "11 requests", "2.0 req/s", "erros 9.09%", "p95 10ms"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected progress line to contain %q, got %q", want, line)
+		}
+	}
+}
+
+func TestProgressRun(t *testing.T) {
+	p := newProgress(time.Now())
+	p.add(Result{Start: time.Now(), Duration: time.Millisecond, StatusCode: 200})
+
+	var buf bytes.Buffer
+	stop, done := make(chan struct{}), make(chan struct{})
+	go p.run(&buf, 20*time.Millisecond, stop, done)
+
+	time.Sleep(70 * time.Millisecond)
+	close(stop)
+	<-done
+
+	// Not a terminal: one line per interval
+	if lines := strings.Count(buf.String(), "\n"); lines < 2 {
+		t.Errorf("Expected at least 2 progress lines, got %d: %q", lines, buf.String())
+	}
+}
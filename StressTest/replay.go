@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replay file formats accepted by --replay-format
+const (
+	replayFormatAuto = "auto"
+	replayFormatHAR  = "har"
+	replayFormatLog  = "log"
+)
+
+// accessLogTimeLayout is the timestamp layout of the Common and Combined Log Formats
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogPattern matches a line in the Common or Combined Log Format:
+// host ident user [time] "METHOD target PROTO" status size ["referer" "user-agent"]
+var accessLogPattern = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "([A-Z]+) (\S+)(?: [^"]*)?" \S+ \S+(?: "([^"]*)" "([^"]*)")?`)
+
+// Replay is recorded traffic sent again with its original timing, divided by Speed
+type Replay struct {
+	Source  string        `json:"source"`
+	Speed   float64       `json:"speed"`
+	Entries []ReplayEntry `json:"entries"`
+	// Skipped counts the lines of an access log that could not be parsed
+	Skipped int `json:"-"`
+}
+
+// ReplayEntry is a recorded request. Offset is its instant relative to the
+// first request of the recording.
+type ReplayEntry struct {
+	Offset time.Duration `json:"offset_ns"`
+	Method string        `json:"method"`
+	URL    string        `json:"url"`
+	Header http.Header   `json:"header,omitempty"`
+	Body   []byte        `json:"body,omitempty"`
+}
+
+// harFile holds the parts of a HAR 1.2 file used by the replay
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// replayIgnoredHeaders are recomputed by the client, or point at the
+// recorded host instead of the target
+var replayIgnoredHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// loadReplay reads a HAR file or an access log. With the auto format, files
+// ending in .har or starting with "{" are read as HAR.
+func loadReplay(path, format string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == replayFormatAuto {
+		format = replayFormatLog
+		if strings.EqualFold(filepath.Ext(path), ".har") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			format = replayFormatHAR
+		}
+	}
+
+	var replay *Replay
+	switch format {
+	case replayFormatHAR:
+		replay, err = parseHAR(data)
+	case replayFormatLog:
+		replay, err = parseAccessLog(data)
+	default:
+		return nil, fmt.Errorf("formato de replay desconhecido: %s (use har ou log)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	replay.Source = path
+	return replay, nil
+}
+
+func parseHAR(data []byte) (*Replay, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("HAR inválido: %w", err)
+	}
+
+	replay := &Replay{}
+	starts := make([]time.Time, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		request := entry.Request
+		if request.Method == "" || request.URL == "" {
+			return nil, fmt.Errorf("HAR inválido: entrada %d sem método ou url", i+1)
+		}
+
+		header := make(http.Header)
+		for _, h := range request.Headers {
+			// Pseudo-headers do HTTP/2 (":authority", ":path", ...) não são headers de fato
+			if strings.HasPrefix(h.Name, ":") || replayIgnoredHeaders[http.CanonicalHeaderKey(h.Name)] {
+				continue
+			}
+			header.Add(h.Name, h.Value)
+		}
+
+		var body []byte
+		if request.PostData != nil && request.PostData.Text != "" {
+			body = []byte(request.PostData.Text)
+			if request.PostData.Encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(request.PostData.Text)
+				if err != nil {
+					return nil, fmt.Errorf("HAR inválido: corpo da entrada %d: %w", i+1, err)
+				}
+				body = decoded
+			}
+		}
+
+		replay.Entries = append(replay.Entries, ReplayEntry{
+			Method: strings.ToUpper(request.Method),
+			URL:    request.URL,
+			Header: header,
+			Body:   body,
+		})
+		starts = append(starts, entry.StartedDateTime)
+	}
+	if len(replay.Entries) == 0 {
+		return nil, fmt.Errorf("HAR sem requests")
+	}
+
+	setOffsets(replay.Entries, starts)
+	return replay, nil
+}
+
+// parseAccessLog reads an access log in the Common or Combined Log Format.
+// Access logs have no bodies and a one-second resolution, so the requests
+// logged in the same second are spread evenly over it. Lines that do not
+// match the format are skipped and counted.
+func parseAccessLog(data []byte) (*Replay, error) {
+	replay := &Replay{}
+	var starts []time.Time
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		matches := accessLogPattern.FindStringSubmatch(line)
+		if matches == nil {
+			replay.Skipped++
+			continue
+		}
+		start, err := time.Parse(accessLogTimeLayout, matches[1])
+		if err != nil {
+			replay.Skipped++
+			continue
+		}
+
+		header := make(http.Header)
+		if referer := matches[4]; referer != "" && referer != "-" {
+			header.Set("Referer", referer)
+		}
+		if userAgent := matches[5]; userAgent != "" && userAgent != "-" {
+			header.Set("User-Agent", userAgent)
+		}
+
+		replay.Entries = append(replay.Entries, ReplayEntry{Method: matches[2], URL: matches[3], Header: header})
+		starts = append(starts, start)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(replay.Entries) == 0 {
+		return nil, fmt.Errorf("access log sem requests no formato Common/Combined (%d linhas ignoradas)", replay.Skipped)
+	}
+
+	// Requests do mesmo segundo são distribuídos uniformemente dentro dele
+	for i := 0; i < len(starts); {
+		j := i
+		for j < len(starts) && starts[j].Equal(starts[i]) {
+			j++
+		}
+		for k := i; k < j; k++ {
+			starts[k] = starts[k].Add(time.Duration(k-i) * time.Second / time.Duration(j-i))
+		}
+		i = j
+	}
+
+	setOffsets(replay.Entries, starts)
+	return replay, nil
+}
+
+// setOffsets sorts the entries by start and sets their offsets from the first one
+func setOffsets(entries []ReplayEntry, starts []time.Time) {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return starts[order[a]].Before(starts[order[b]]) })
+
+	sorted := make([]ReplayEntry, len(entries))
+	first := starts[order[0]]
+	for i, index := range order {
+		sorted[i] = entries[index]
+		sorted[i].Offset = starts[index].Sub(first)
+	}
+	copy(entries, sorted)
+}
+
+// rebase points the entries at baseURL, keeping their path and query. Without
+// a base URL every entry must already have an absolute URL.
+func (r *Replay) rebase(baseURL string) error {
+	var base *url.URL
+	if baseURL != "" {
+		var err error
+		if base, err = url.Parse(baseURL); err != nil {
+			return fmt.Errorf("url base inválida: %w", err)
+		}
+	}
+
+	for i := range r.Entries {
+		target, err := url.Parse(r.Entries[i].URL)
+		if err != nil {
+			return fmt.Errorf("url inválida %q: %w", r.Entries[i].URL, err)
+		}
+		if base == nil {
+			if !target.IsAbs() {
+				return fmt.Errorf("url relativa %q: informe --url com o endereço do serviço", r.Entries[i].URL)
+			}
+			continue
+		}
+
+		rebased := *base
+		rebased.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(target.Path, "/")
+		rebased.RawPath = ""
+		rebased.RawQuery = target.RawQuery
+		rebased.Fragment = ""
+		r.Entries[i].URL = rebased.String()
+	}
+	return nil
+}
+
+// Duration returns how long the replay takes at its speed
+func (r *Replay) Duration() time.Duration {
+	if len(r.Entries) == 0 {
+		return 0
+	}
+	return r.scaled(r.Entries[len(r.Entries)-1].Offset)
+}
+
+// scaled returns the instant of an offset at the speed of the replay
+func (r *Replay) scaled(offset time.Duration) time.Duration {
+	if r.Speed <= 0 {
+		return offset
+	}
+	return time.Duration(float64(offset) / r.Speed)
+}
+
+// share returns the entries assigned to part i of n, dealt in turns so every
+// agent follows the shape of the whole recording
+func (r *Replay) share(n, i int) *Replay {
+	share := &Replay{Source: r.Source, Speed: r.Speed}
+	for j := i; j < len(r.Entries); j += n {
+		share.Entries = append(share.Entries, r.Entries[j])
+	}
+	return share
+}
+
+func (e ReplayEntry) requestSpec(assertions *Assertions) RequestSpec {
+	return RequestSpec{
+		Method:     e.Method,
+		URL:        e.URL,
+		Header:     e.Header,
+		Body:       e.Body,
+		Assertions: assertions,
+	}
+}
+
+// runReplay sends each recorded request at its scaled instant, each in its
+// own goroutine. As in runOpenModel, the latency is measured from the
+// scheduled instant. --requests and --duration cut the replay short.
+func runReplay(config Config, startTime time.Time, results chan<- Result) {
+	client := newHTTPClient(config.clientOptions())
+	replay := config.Replay
+
+	var wg sync.WaitGroup
+	for i, entry := range replay.Entries {
+		if config.Requests > 0 && i >= config.Requests {
+			break
+		}
+		offset := replay.scaled(entry.Offset)
+		if config.Duration > 0 && offset >= config.Duration {
+			break
+		}
+
+		scheduled := startTime.Add(offset)
+		if !waitUntil(scheduled, config.stop) {
+			break
+		}
+
+		executor := config.instrument(entry.requestSpec(config.Assertions))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			executor.Execute(client, scheduled, results)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "startedDateTime": "2025-03-01T10:00:00.500Z",
+        "request": {
+          "method": "post",
+          "url": "https://api.example.com/order?source=web",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "Content-Length", "value": "12"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "eyJpZCI6ImEifQ==", "encoding": "base64"}
+        }
+      },
+      {
+        "startedDateTime": "2025-03-01T10:00:00.000Z",
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/order/a",
+          "headers": [{"name": "API_KEY", "value": "abc123"}]
+        }
+      }
+    ]
+  }
+}`
+
+const testAccessLog = `10.0.0.1 - - [01/Mar/2025:10:00:00 +0000] "GET /weather?cep=01001000 HTTP/1.1" 200 52 "-" "curl/8.0"
+10.0.0.2 - frank [01/Mar/2025:10:00:00 +0000] "GET /weather?cep=22041001 HTTP/1.1" 200 52
+\x16\x03\x01 garbage
+10.0.0.1 - - [01/Mar/2025:10:00:02 +0000] "POST /orders HTTP/1.1" 201 10 "https://app.example.com/" "Mozilla/5.0"
+`
+
+func TestParseHAR(t *testing.T) {
+	replay, err := parseHAR([]byte(testHAR))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(replay.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(replay.Entries))
+	}
+
+	// As entradas são ordenadas pelo horário de início
+	first, second := replay.Entries[0], replay.Entries[1]
+	if first.Method != http.MethodGet || first.Offset != 0 || first.Header.Get("API_KEY") != "abc123" {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if second.Method != http.MethodPost || second.Offset != 500*time.Millisecond || string(second.Body) != `{"id":"a"}` {
+		t.Errorf("Unexpected second entry: %+v", second)
+	}
+	if len(second.Header) != 1 || second.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected only Content-Type to be kept, got %v", second.Header)
+	}
+
+	if _, err := parseHAR([]byte(`{"log": {"entries": []}}`)); err == nil {
+		t.Error("Expected an error for a HAR without entries")
+	}
+}
+
+func TestParseAccessLog(t *testing.T) {
+	replay, err := parseAccessLog([]byte(testAccessLog))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(replay.Entries) != 3 || replay.Skipped != 1 {
+		t.Fatalf("Expected 3 entries and 1 skipped line, got %d and %d", len(replay.Entries), replay.Skipped)
+	}
+
+	// Os dois requests do primeiro segundo são distribuídos dentro dele
+	offsets := []time.Duration{0, 500 * time.Millisecond, 2 * time.Second}
+	for i, entry := range replay.Entries {
+		if entry.Offset != offsets[i] {
+			t.Errorf("Entry %d: expected offset %v, got %v", i, offsets[i], entry.Offset)
+		}
+	}
+	if replay.Entries[0].URL != "/weather?cep=01001000" || replay.Entries[0].Header.Get("User-Agent") != "curl/8.0" {
+		t.Errorf("Unexpected first entry: %+v", replay.Entries[0])
+	}
+	last := replay.Entries[2]
+	if last.Method != http.MethodPost || last.Header.Get("Referer") != "https://app.example.com/" || len(replay.Entries[1].Header) != 0 {
+		t.Errorf("Unexpected headers: %+v / %+v", replay.Entries[1], last)
+	}
+
+	if _, err := parseAccessLog([]byte("not a log line\n")); err == nil {
+		t.Error("Expected an error for a log without requests")
+	}
+}
+
+func TestLoadReplayDetectsFormat(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"traffic.har": testHAR, "traffic.json": testHAR, "access.log": testAccessLog}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]int{"traffic.har": 2, "traffic.json": 2, "access.log": 3} {
+		replay, err := loadReplay(filepath.Join(dir, name), replayFormatAuto)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if len(replay.Entries) != want {
+			t.Errorf("%s: expected %d entries, got %d", name, want, len(replay.Entries))
+		}
+	}
+
+	if _, err := loadReplay(filepath.Join(dir, "access.log"), replayFormatHAR); err == nil {
+		t.Error("Expected an error reading an access log as HAR")
+	}
+}
+
+func TestReplayRebase(t *testing.T) {
+	replay := &Replay{Entries: []ReplayEntry{
+		{URL: "https://api.example.com/order?source=web"},
+		{URL: "/weather?cep=01001000"},
+	}}
+	if err := replay.rebase("http://localhost:8080/v1/"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"http://localhost:8080/v1/order?source=web", "http://localhost:8080/v1/weather?cep=01001000"}
+	for i, entry := range replay.Entries {
+		if entry.URL != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], entry.URL)
+		}
+	}
+
+	relative := &Replay{Entries: []ReplayEntry{{URL: "/weather"}}}
+	if err := relative.rebase(""); err == nil {
+		t.Error("Expected an error for relative URLs without a base URL")
+	}
+}
+
+func TestReplayShare(t *testing.T) {
+	replay := &Replay{Speed: 2}
+	for i := 0; i < 5; i++ {
+		replay.Entries = append(replay.Entries, ReplayEntry{Offset: time.Duration(i) * time.Second})
+	}
+
+	first, second := replay.share(2, 0), replay.share(2, 1)
+	if len(first.Entries) != 3 || len(second.Entries) != 2 || second.Entries[1].Offset != 3*time.Second {
+		t.Errorf("Unexpected shares: %+v / %+v", first.Entries, second.Entries)
+	}
+	if first.Speed != 2 || replay.Duration() != 2*time.Second {
+		t.Errorf("Expected the speed to be kept and the replay to take 2s, got %v / %v", first.Speed, replay.Duration())
+	}
+}
+
+func TestRunStressTestReplay(t *testing.T) {
+	var mu sync.Mutex
+	type arrival struct {
+		at   time.Time
+		path string
+		body string
+	}
+	var arrivals []arrival
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		arrivals = append(arrivals, arrival{time.Now(), r.URL.RequestURI(), string(body)})
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	replay := &Replay{Source: "traffic.har", Speed: 2, Entries: []ReplayEntry{
+		{Offset: 0, Method: http.MethodGet, URL: "/a"},
+		{Offset: 400 * time.Millisecond, Method: http.MethodPost, URL: "/b?x=1", Body: []byte("payload")},
+		{Offset: 800 * time.Millisecond, Method: http.MethodGet, URL: "/c"},
+	}}
+	if err := replay.rebase(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	report := runStressTest(Config{URL: server.URL, Replay: replay})
+
+	if report.TotalRequests != 3 || report.SuccessCount != 3 {
+		t.Fatalf("Expected 3 successful requests, got %d of %d", report.SuccessCount, report.TotalRequests)
+	}
+
+	mu.Lock()
+	second, last := arrivals[1], arrivals[2]
+	mu.Unlock()
+	if second.path != "/b?x=1" || second.body != "payload" {
+		t.Errorf("Unexpected second request: %+v", second)
+	}
+	// Com velocidade 2 a gravação de 800ms é reproduzida em 400ms
+	if elapsed := last.at.Sub(start); elapsed < 350*time.Millisecond || elapsed > 700*time.Millisecond {
+		t.Errorf("Expected the last request around 400ms, got %v", elapsed)
+	}
+
+	// --requests corta o replay
+	report = runStressTest(Config{URL: server.URL, Replay: replay, Requests: 2})
+	if report.TotalRequests != 2 {
+		t.Errorf("Expected 2 requests, got %d", report.TotalRequests)
+	}
+}
+
+func TestParseFlagsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(testAccessLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := parseFlagsFromArgs([]string{"--replay=" + path, "--replay-speed=10", "--url=http://localhost:8080"})
+	if config.Replay == nil || config.Replay.Speed != 10 || len(config.Replay.Entries) != 3 {
+		t.Fatalf("Unexpected replay: %+v", config.Replay)
+	}
+	if config.Replay.Entries[2].URL != "http://localhost:8080/orders" {
+		t.Errorf("Expected the entries to be rebased, got %s", config.Replay.Entries[2].URL)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestSpec describes the HTTP request sent on every iteration and the
+// assertions its response must pass (status 200, when nil)
+type RequestSpec struct {
+	Method     string
+	URL        string
+	Header     http.Header
+	Body       []byte
+	Assertions *Assertions
+}
+
+// newRequest builds a fresh *http.Request, since a request body can only be read once
+func (s RequestSpec) newRequest() (*http.Request, error) {
+	method := s.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if len(s.Body) > 0 {
+		body = bytes.NewReader(s.Body)
+	}
+
+	req, err := http.NewRequest(method, s.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range s.Header {
+		// O header Host é tratado pelo campo req.Host e não pelo mapa de headers
+		if strings.EqualFold(key, "Host") {
+			req.Host = values[0]
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	return req, nil
+}
+
+// Execute sends the request once
+func (s RequestSpec) Execute(client *http.Client, start time.Time, results chan<- Result) {
+	results <- doRequest(client, s, start)
+}
+
+// headerFlag collects repeated --header "Key: Value" flags
+type headerFlag struct {
+	header http.Header
+}
+
+func (h *headerFlag) String() string {
+	if h == nil || h.header == nil {
+		return ""
+	}
+	var parts []string
+	for key, values := range h.header {
+		for _, value := range values {
+			parts = append(parts, key+": "+value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	key, val, err := parseHeader(value)
+	if err != nil {
+		return err
+	}
+	if h.header == nil {
+		h.header = make(http.Header)
+	}
+	h.header.Add(key, val)
+	return nil
+}
+
+// parseHeader splits a "Key: Value" string
+func parseHeader(raw string) (string, string, error) {
+	key, value, found := strings.Cut(raw, ":")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", "", fmt.Errorf("header inválido %q, use o formato \"Nome: valor\"", raw)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+// listFlag collects the values of a repeated flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHeaderFlag(t *testing.T) {
+	var headers headerFlag
+
+	for _, value := range []string{"API_KEY: abc123", "Content-Type:application/json", "X-Tag: a", "X-Tag: b"} {
+		if err := headers.Set(value); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", value, err)
+		}
+	}
+
+	if got := headers.header.Get("API_KEY"); got != "abc123" {
+		t.Errorf("Expected API_KEY abc123, got %q", got)
+	}
+	if got := headers.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", got)
+	}
+	if got := headers.header.Values("X-Tag"); len(got) != 2 {
+		t.Errorf("Expected 2 X-Tag values, got %v", got)
+	}
+
+	for _, invalid := range []string{"no-colon", ": value"} {
+		if err := headers.Set(invalid); err == nil {
+			t.Errorf("Expected error for invalid header %q", invalid)
+		}
+	}
+}
+
+func TestParseFlagsRequestOptions(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(bodyFile, []byte(`{"cep":"01001000"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := parseFlagsFromArgs([]string{
+		"--url=http://localhost:8080/cep",
+		"--requests=10",
+		"--method=post",
+		"--header=API_KEY: premium",
+		"--header=Content-Type: application/json",
+		"--body-file=" + bodyFile,
+	})
+
+	if config.Method != http.MethodPost {
+		t.Errorf("Expected method POST, got %s", config.Method)
+	}
+	if config.Headers.Get("API_KEY") != "premium" {
+		t.Errorf("Expected API_KEY header, got %v", config.Headers)
+	}
+	if string(config.Body) != `{"cep":"01001000"}` {
+		t.Errorf("Expected body from file, got %q", config.Body)
+	}
+}
+
+func TestDoRequestSendsMethodHeadersAndBody(t *testing.T) {
+	var gotMethod, gotKey, gotHost, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotKey = r.Header.Get("API_KEY")
+		gotHost = r.Host
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	spec := RequestSpec{
+		Method: http.MethodPost,
+		URL:    server.URL + "/order",
+		Header: http.Header{"Api_key": {"abc123"}, "Host": {"orders.local"}},
+		Body:   []byte(`{"price":10}`),
+	}
+
+	// Two requests make sure the body is not consumed by the first one
+	for i := 0; i < 2; i++ {
+		result := doRequest(newHTTPClient(ClientOptions{}), spec, time.Now())
+		if result.Error != nil {
+			t.Fatalf("Unexpected error: %v", result.Error)
+		}
+		if result.StatusCode != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", result.StatusCode)
+		}
+		if gotMethod != http.MethodPost || gotKey != "abc123" || gotHost != "orders.local" || gotBody != `{"price":10}` {
+			t.Errorf("Unexpected request: method=%s key=%s host=%s body=%s", gotMethod, gotKey, gotHost, gotBody)
+		}
+	}
+}
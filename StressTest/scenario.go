@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes a multi-step load test loaded from a YAML or JSON file.
+// Each iteration picks one flow by weight and runs its steps in order.
+type Scenario struct {
+	Name      string            `yaml:"name"`
+	BaseURL   string            `yaml:"base_url"`
+	Variables map[string]string `yaml:"variables"`
+	Headers   map[string]string `yaml:"headers"`
+	// Expect holds the assertions of the steps without their own
+	Expect *Assertions `yaml:"expect"`
+	Flows  []*Flow     `yaml:"flows"`
+
+	variables   map[string]*template.Template
+	totalWeight int
+}
+
+// Flow is a weighted sequence of steps
+type Flow struct {
+	Name   string  `yaml:"name"`
+	Weight int     `yaml:"weight"`
+	Steps  []*Step `yaml:"steps"`
+}
+
+// Step is a single templated request of a flow
+type Step struct {
+	Name      string            `yaml:"name"`
+	Method    string            `yaml:"method"`
+	URL       string            `yaml:"url"`
+	Headers   map[string]string `yaml:"headers"`
+	Body      string            `yaml:"body"`
+	ThinkTime time.Duration     `yaml:"think_time"`
+	// Extract maps a variable name to an expression evaluated on the response:
+	// "json:<path>" (or just "<path>"), "header:<name>" or "regex:<pattern>"
+	Extract map[string]string `yaml:"extract"`
+	Expect  *Assertions       `yaml:"expect"`
+
+	url     *template.Template
+	body    *template.Template
+	headers map[string]*template.Template
+	regexes map[string]*regexp.Regexp
+}
+
+// templateFuncs are the helpers available in every scenario template
+var templateFuncs = template.FuncMap{
+	"uuid":         newUUID,
+	"randomCEP":    func() string { return randomDigits(8) },
+	"randomDigits": randomDigits,
+	"randomInt":    func(min, max int) int { return min + mathrand.Intn(max-min+1) },
+	"randomString": randomString,
+	"randomChoice": func(options ...string) string { return options[mathrand.Intn(len(options))] },
+	"now":          func() string { return time.Now().Format(time.RFC3339) },
+	"timestamp":    func() int64 { return time.Now().UnixMilli() },
+}
+
+// loadScenario reads and validates a scenario file. JSON files are accepted
+// as well, since JSON is valid YAML.
+func loadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseScenario(data)
+}
+
+func parseScenario(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("cenário inválido: %w", err)
+	}
+	if err := scenario.compile(); err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+// compile validates the scenario and parses all of its templates
+func (s *Scenario) compile() error {
+	if len(s.Flows) == 0 {
+		return fmt.Errorf("cenário deve ter pelo menos um fluxo")
+	}
+
+	if s.Expect != nil {
+		if err := s.Expect.compile(); err != nil {
+			return err
+		}
+	}
+
+	s.variables = make(map[string]*template.Template)
+	for name, value := range s.Variables {
+		tmpl, err := parseTemplate("variável "+name, value)
+		if err != nil {
+			return err
+		}
+		s.variables[name] = tmpl
+	}
+
+	s.totalWeight = 0
+	for i, flow := range s.Flows {
+		if flow.Name == "" {
+			flow.Name = fmt.Sprintf("fluxo-%d", i+1)
+		}
+		if flow.Weight < 0 {
+			return fmt.Errorf("fluxo %s: peso não pode ser negativo", flow.Name)
+		}
+		if flow.Weight == 0 {
+			flow.Weight = 1
+		}
+		if len(flow.Steps) == 0 {
+			return fmt.Errorf("fluxo %s: deve ter pelo menos uma etapa", flow.Name)
+		}
+		s.totalWeight += flow.Weight
+
+		for _, step := range flow.Steps {
+			if err := step.compile(s); err != nil {
+				return fmt.Errorf("fluxo %s: %w", flow.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (st *Step) compile(s *Scenario) error {
+	if st.URL == "" {
+		return fmt.Errorf("etapa %q sem url", st.Name)
+	}
+	if st.Method == "" {
+		st.Method = http.MethodGet
+	}
+	st.Method = strings.ToUpper(st.Method)
+	if st.Name == "" {
+		st.Name = st.Method + " " + st.URL
+	}
+	if st.Expect != nil {
+		if err := st.Expect.compile(); err != nil {
+			return fmt.Errorf("etapa %q: %w", st.Name, err)
+		}
+	}
+	st.regexes = make(map[string]*regexp.Regexp)
+	for name, expr := range st.Extract {
+		kind, arg := splitExtraction(expr)
+		if arg == "" {
+			return fmt.Errorf("etapa %q, extração %s: expressão vazia", st.Name, name)
+		}
+		if kind == "regex" {
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return fmt.Errorf("etapa %q, extração %s: %w", st.Name, name, err)
+			}
+			st.regexes[arg] = re
+		}
+	}
+
+	var err error
+	if st.url, err = parseTemplate(st.Name+" url", st.URL); err != nil {
+		return err
+	}
+	if st.body, err = parseTemplate(st.Name+" body", st.Body); err != nil {
+		return err
+	}
+
+	// Headers do cenário valem para todas as etapas, mas podem ser sobrescritos pela etapa
+	st.headers = make(map[string]*template.Template)
+	for _, headers := range []map[string]string{s.Headers, st.Headers} {
+		for name, value := range headers {
+			tmpl, err := parseTemplate(st.Name+" header "+name, value)
+			if err != nil {
+				return err
+			}
+			st.headers[http.CanonicalHeaderKey(name)] = tmpl
+		}
+	}
+
+	return nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template inválido em %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Execute runs one iteration: a weighted random flow, step by step.
+// The first request is measured from start; a failed step aborts the flow.
+func (s *Scenario) Execute(client *http.Client, start time.Time, results chan<- Result) {
+	flow := s.pickFlow()
+
+	vars, err := s.initialVariables()
+	if err != nil {
+		results <- Result{Start: start, Step: flow.Steps[0].Name, Duration: time.Since(start), Error: err}
+		return
+	}
+
+	for i, step := range flow.Steps {
+		if i > 0 {
+			start = time.Now()
+		}
+
+		spec, err := step.render(s.BaseURL, vars)
+		if err != nil {
+			results <- Result{Start: start, Step: step.Name, Duration: time.Since(start), Error: err}
+			return
+		}
+		spec.Assertions = step.Expect
+		if spec.Assertions == nil {
+			spec.Assertions = s.Expect
+		}
+
+		result, resp := performRequest(client, spec, start, len(step.Extract) > 0)
+		result.Step = step.Name
+
+		if result.Error == nil {
+			if err := step.extract(resp, vars); err != nil {
+				result.Error = err
+			}
+		}
+
+		results <- result
+		if result.Error != nil {
+			return
+		}
+
+		if step.ThinkTime > 0 {
+			time.Sleep(step.ThinkTime)
+		}
+	}
+}
+
+func (s *Scenario) pickFlow() *Flow {
+	n := mathrand.Intn(s.totalWeight)
+	for _, flow := range s.Flows {
+		if n < flow.Weight {
+			return flow
+		}
+		n -= flow.Weight
+	}
+	return s.Flows[len(s.Flows)-1]
+}
+
+// initialVariables evaluates the scenario variables for a new iteration
+func (s *Scenario) initialVariables() (map[string]string, error) {
+	vars := make(map[string]string, len(s.variables))
+	for name, tmpl := range s.variables {
+		value, err := executeTemplate(tmpl, nil)
+		if err != nil {
+			return nil, err
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// render builds the request of a step from the current variables
+func (st *Step) render(baseURL string, vars map[string]string) (RequestSpec, error) {
+	url, err := executeTemplate(st.url, vars)
+	if err != nil {
+		return RequestSpec{}, err
+	}
+	if strings.HasPrefix(url, "/") {
+		url = strings.TrimSuffix(baseURL, "/") + url
+	}
+
+	body, err := executeTemplate(st.body, vars)
+	if err != nil {
+		return RequestSpec{}, err
+	}
+
+	header := make(http.Header, len(st.headers))
+	for name, tmpl := range st.headers {
+		value, err := executeTemplate(tmpl, vars)
+		if err != nil {
+			return RequestSpec{}, err
+		}
+		header.Set(name, value)
+	}
+
+	return RequestSpec{Method: st.Method, URL: url, Header: header, Body: []byte(body)}, nil
+}
+
+// extract stores the values extracted from the response in vars
+func (st *Step) extract(resp response, vars map[string]string) error {
+	// Ordenado para que os erros sejam determinísticos
+	names := make([]string, 0, len(st.Extract))
+	for name := range st.Extract {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, err := st.extractValue(resp, st.Extract[name])
+		if err != nil {
+			return fmt.Errorf("extração de %s falhou: %w", name, err)
+		}
+		vars[name] = value
+	}
+	return nil
+}
+
+func (st *Step) extractValue(resp response, expr string) (string, error) {
+	kind, arg := splitExtraction(expr)
+	switch kind {
+	case "header":
+		value := resp.header.Get(arg)
+		if value == "" {
+			return "", fmt.Errorf("header %s ausente", arg)
+		}
+		return value, nil
+	case "regex":
+		matches := st.regexes[arg].FindSubmatch(resp.body)
+		if matches == nil {
+			return "", fmt.Errorf("regex %q não encontrou resultado", arg)
+		}
+		if len(matches) > 1 {
+			return string(matches[1]), nil
+		}
+		return string(matches[0]), nil
+	default:
+		return jsonPath(resp.body, arg)
+	}
+}
+
+// splitExtraction splits "kind:argument", defaulting to a JSON path
+func splitExtraction(expr string) (string, string) {
+	if kind, arg, found := strings.Cut(expr, ":"); found {
+		switch kind {
+		case "json", "header", "regex":
+			return kind, arg
+		}
+	}
+	return "json", expr
+}
+
+func executeTemplate(tmpl *template.Template, vars map[string]string) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("erro no template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func randomDigits(n int) string {
+	digits := make([]byte, n)
+	for i := range digits {
+		digits[i] = byte('0' + mathrand.Intn(10))
+	}
+	return string(digits)
+}
+
+func randomString(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = letters[mathrand.Intn(len(letters))]
+	}
+	return string(out)
+}
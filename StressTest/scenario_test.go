@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestParseScenario(t *testing.T) {
+	scenario, err := parseScenario([]byte(`
+name: test
+base_url: http://localhost
+flows:
+  - name: read
+    weight: 3
+    steps:
+      - url: /items
+  - steps:
+      - method: post
+        url: /items
+        body: '{"id": "{{uuid}}"}'
+        extract:
+          id: json:id
+          location: header:Location
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if scenario.totalWeight != 4 {
+		t.Errorf("Expected total weight 4, got %d", scenario.totalWeight)
+	}
+	if scenario.Flows[1].Name != "fluxo-2" || scenario.Flows[1].Weight != 1 {
+		t.Errorf("Expected default name and weight, got %s/%d", scenario.Flows[1].Name, scenario.Flows[1].Weight)
+	}
+	if step := scenario.Flows[1].Steps[0]; step.Method != http.MethodPost || step.Name != "POST /items" {
+		t.Errorf("Expected normalized method and default name, got %s / %s", step.Method, step.Name)
+	}
+}
+
+func TestParseScenarioJSON(t *testing.T) {
+	scenario, err := parseScenario([]byte(`{"flows": [{"steps": [{"url": "http://localhost/", "think_time": "150ms"}]}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if scenario.Flows[0].Steps[0].ThinkTime != 150*time.Millisecond {
+		t.Errorf("Expected think time 150ms, got %v", scenario.Flows[0].Steps[0].ThinkTime)
+	}
+}
+
+func TestParseScenarioExpect(t *testing.T) {
+	scenario, err := parseScenario([]byte(`
+expect:
+  status: [200]
+flows:
+  - steps:
+      - url: http://localhost/items
+      - method: post
+        url: http://localhost/items
+        expect:
+          status: [201, 2xx]
+          json: [id]
+          max_latency: 500ms
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	steps := scenario.Flows[0].Steps
+	if steps[0].Expect != nil {
+		t.Errorf("Expected first step to use the scenario assertions, got %+v", steps[0].Expect)
+	}
+	expect := steps[1].Expect
+	if expect == nil || len(expect.Status) != 2 || expect.Status[1] != "2xx" || expect.MaxLatency != 500*time.Millisecond {
+		t.Errorf("Unexpected step assertions: %+v", expect)
+	}
+}
+
+func TestParseScenarioInvalid(t *testing.T) {
+	invalid := map[string]string{
+		"no flows":        `name: empty`,
+		"no steps":        `flows: [{name: a}]`,
+		"no url":          `flows: [{steps: [{method: GET}]}]`,
+		"bad template":    `flows: [{steps: [{url: "/{{.id"}]}]`,
+		"bad regex":       `flows: [{steps: [{url: "/", extract: {id: "regex:("}}]}]`,
+		"negative weight": `flows: [{weight: -1, steps: [{url: "/"}]}]`,
+		"bad expect":      `flows: [{steps: [{url: "/", expect: {status: [2x]}}]}]`,
+	}
+
+	for name, data := range invalid {
+		if _, err := parseScenario([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestScenarioTemplateFuncs(t *testing.T) {
+	tmpl, err := parseTemplate("test", `{{uuid}}|{{randomCEP}}|{{randomInt 5 5}}|{{randomString 4}}|{{randomChoice "x"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := executeTemplate(tmpl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(out, "|")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(parts[0]) {
+		t.Errorf("Invalid UUID: %s", parts[0])
+	}
+	if !regexp.MustCompile(`^[0-9]{8}$`).MatchString(parts[1]) {
+		t.Errorf("Invalid CEP: %s", parts[1])
+	}
+	if parts[2] != "5" || len(parts[3]) != 4 || parts[4] != "x" {
+		t.Errorf("Unexpected template output: %s", out)
+	}
+
+	if _, err := executeTemplate(mustParseTemplate(t, "{{.missing}}"), map[string]string{}); err == nil {
+		t.Error("Expected error for missing variable")
+	}
+}
+
+func mustParseTemplate(t *testing.T, text string) *template.Template {
+	t.Helper()
+	tmpl, err := parseTemplate("test", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
+
+// auctionServer is a tiny in-memory version of the Audiction API
+func auctionServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	auctions := map[string]string{} // category -> id
+	var bids []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auction", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPost {
+			var input struct {
+				Category string `json:"category"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			auctions[input.Category] = "auction-" + input.Category
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		id, ok := auctions[r.URL.Query().Get("category")]
+		if !ok {
+			w.Write([]byte(`[]`))
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]string{{"id": id}})
+	})
+	mux.HandleFunc("/bid", func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			AuctionID string `json:"auction_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		mu.Lock()
+		bids = append(bids, input.AuctionID)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bids...)
+	}
+}
+
+func TestScenarioExecuteWithExtraction(t *testing.T) {
+	server, bids := auctionServer(t)
+
+	scenario, err := parseScenario([]byte(`
+base_url: ` + server.URL + `
+variables:
+  category: "cat-{{randomString 8}}"
+headers:
+  Content-Type: application/json
+flows:
+  - steps:
+      - name: create
+        method: POST
+        url: /auction
+        body: '{"category": "{{.category}}"}'
+      - name: find
+        url: /auction?category={{.category}}
+        extract:
+          auction_id: "[0].id"
+      - name: bid
+        method: POST
+        url: /bid
+        body: '{"auction_id": "{{.auction_id}}"}'
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	report := runStressTest(Config{Scenario: scenario, Requests: 5, Concurrency: 2})
+
+	if report.TotalRequests != 15 {
+		t.Fatalf("Expected 15 requests (5 iterations x 3 steps), got %d", report.TotalRequests)
+	}
+	if report.FailureCount != 0 {
+		t.Errorf("Expected no failures, got %d", report.FailureCount)
+	}
+	if len(report.Steps) != 3 {
+		t.Fatalf("Expected 3 step reports, got %d", len(report.Steps))
+	}
+	for _, step := range report.Steps {
+		if step.TotalRequests != 5 {
+			t.Errorf("Expected 5 requests for step %s, got %d", step.Name, step.TotalRequests)
+		}
+	}
+
+	recorded := bids()
+	if len(recorded) != 5 {
+		t.Fatalf("Expected 5 bids, got %d", len(recorded))
+	}
+	for _, id := range recorded {
+		if !strings.HasPrefix(id, "auction-cat-") {
+			t.Errorf("Expected bid to use the extracted auction id, got %q", id)
+		}
+	}
+}
+
+func TestScenarioExtractionFailureAbortsFlow(t *testing.T) {
+	server, bids := auctionServer(t)
+
+	scenario, err := parseScenario([]byte(`
+base_url: ` + server.URL + `
+flows:
+  - steps:
+      - name: find
+        url: /auction?category=none
+        extract:
+          auction_id: "[0].id"
+      - name: bid
+        method: POST
+        url: /bid
+        body: '{"auction_id": "{{.auction_id}}"}'
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	results := make(chan Result, 10)
+	scenario.Execute(newHTTPClient(ClientOptions{}), time.Now(), results)
+	close(results)
+
+	var got []Result
+	for result := range results {
+		got = append(got, result)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("Expected the flow to stop after the failed extraction, got %d results", len(got))
+	}
+	if got[0].Error == nil || got[0].Step != "find" {
+		t.Errorf("Expected extraction error on step find, got %+v", got[0])
+	}
+	if len(bids()) != 0 {
+		t.Error("Expected no bids after a failed extraction")
+	}
+}
+
+func TestScenarioExampleFile(t *testing.T) {
+	data, err := os.ReadFile("examples/leilao.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseScenario(data); err != nil {
+		t.Fatalf("Example scenario is invalid: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stageControlInterval is how often the number of workers is adjusted
+const stageControlInterval = 100 * time.Millisecond
+
+// Stage ramps the number of workers linearly from the previous target
+// (or --concurrency, for the first stage) to Target during Duration.
+// A zero Duration jumps to Target immediately.
+type Stage struct {
+	Duration time.Duration `json:"duration_ns"`
+	Target   int           `json:"target"`
+}
+
+// StageReport holds the results of the requests started during a stage
+type StageReport struct {
+	Index             int           `json:"index"`
+	Start             time.Duration `json:"start_ns"`
+	Duration          time.Duration `json:"duration_ns"`
+	From              int           `json:"from"`
+	To                int           `json:"to"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	Summary
+}
+
+// parseStages parses "30s:50,2m:50,10s:200" into stages
+func parseStages(raw string) ([]Stage, error) {
+	var stages []Stage
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rawDuration, rawTarget, found := strings.Cut(part, ":")
+		if !found {
+			return nil, fmt.Errorf("estágio %q inválido, use duração:workers (ex: 30s:50)", part)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(rawDuration))
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("duração inválida no estágio %q", part)
+		}
+		target, err := strconv.Atoi(strings.TrimSpace(rawTarget))
+		if err != nil || target < 0 {
+			return nil, fmt.Errorf("número de workers inválido no estágio %q", part)
+		}
+		stages = append(stages, Stage{Duration: duration, Target: target})
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("nenhum estágio informado")
+	}
+	if stagesDuration(stages) == 0 {
+		return nil, fmt.Errorf("a soma das durações dos estágios deve ser maior que 0")
+	}
+	return stages, nil
+}
+
+// parseStepTest turns "start:step:max:hold" (ex: 10:10:100:30s) into stages
+// that jump to each concurrency level and hold it
+func parseStepTest(raw string) ([]Stage, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("step test %q inválido, use inicio:incremento:maximo:duracao (ex: 10:10:100:30s)", raw)
+	}
+
+	var values [3]int
+	for i := range values {
+		value, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("step test %q: valores devem ser inteiros maiores que 0", raw)
+		}
+		values[i] = value
+	}
+	hold, err := time.ParseDuration(strings.TrimSpace(parts[3]))
+	if err != nil || hold <= 0 {
+		return nil, fmt.Errorf("step test %q: duração inválida", raw)
+	}
+
+	start, step, max := values[0], values[1], values[2]
+	var stages []Stage
+	for level := start; level <= max; level += step {
+		stages = append(stages, Stage{Duration: 0, Target: level}, Stage{Duration: hold, Target: level})
+	}
+	return stages, nil
+}
+
+func stagesDuration(stages []Stage) time.Duration {
+	var total time.Duration
+	for _, stage := range stages {
+		total += stage.Duration
+	}
+	return total
+}
+
+// stageAt returns the index of the stage running at elapsed (-1 after the
+// last one) and the number of workers it asks for at that instant
+func stageAt(stages []Stage, initial int, elapsed time.Duration) (int, int) {
+	from := initial
+	var stageStart time.Duration
+	for i, stage := range stages {
+		if elapsed < stageStart+stage.Duration {
+			progress := float64(elapsed-stageStart) / float64(stage.Duration)
+			return i, from + int(float64(stage.Target-from)*progress)
+		}
+		stageStart += stage.Duration
+		from = stage.Target
+	}
+	return -1, from
+}
+
+// runStagedModel behaves like runClosedModel, but the number of workers
+// follows config.Stages. Workers above the target finish their current
+// iteration and stop.
+func runStagedModel(config Config, startTime time.Time, results chan<- Result) {
+	jobs := make(chan int)
+	go produceJobs(config, jobs)
+
+	client := newHTTPClient(config.clientOptions())
+	executor := config.executor()
+	var wg sync.WaitGroup
+	var stops []chan struct{}
+
+	ticker := time.NewTicker(stageControlInterval)
+	defer ticker.Stop()
+
+stages:
+	for {
+		index, target := stageAt(config.Stages, config.Concurrency, time.Since(startTime))
+		if index < 0 {
+			break
+		}
+
+		for len(stops) < target {
+			stop := make(chan struct{})
+			stops = append(stops, stop)
+			wg.Add(1)
+			go stoppableWorker(client, executor, jobs, stop, results, &wg)
+		}
+		for len(stops) > target {
+			close(stops[len(stops)-1])
+			stops = stops[:len(stops)-1]
+		}
+		config.metrics.setWorkers(len(stops))
+
+		select {
+		case <-ticker.C:
+		case <-config.stop:
+			break stages
+		}
+	}
+
+	for _, stop := range stops {
+		close(stop)
+	}
+	config.metrics.setWorkers(0)
+	wg.Wait()
+}
+
+// stageReports splits the results of a run by the stage they started in
+type stageReports struct {
+	stages   []Stage
+	initial  int
+	builders []*summaryBuilder
+}
+
+func newStageReports(stages []Stage, initial int) *stageReports {
+	builders := make([]*summaryBuilder, len(stages))
+	for i := range builders {
+		builders[i] = newSummaryBuilder()
+	}
+	return &stageReports{stages: stages, initial: initial, builders: builders}
+}
+
+func (s *stageReports) add(elapsed time.Duration, result Result) {
+	index, _ := stageAt(s.stages, s.initial, elapsed)
+	if index < 0 {
+		// Requests iniciados no último instante contam no último estágio
+		index = len(s.stages) - 1
+	}
+	s.builders[index].add(result)
+}
+
+// reports returns one report per stage. Instant stages (zero duration) only
+// change the number of workers and are left out.
+func (s *stageReports) reports() []StageReport {
+	var reports []StageReport
+	from := s.initial
+	var start time.Duration
+	for i, stage := range s.stages {
+		if stage.Duration > 0 {
+			summary := s.builders[i].build()
+			reports = append(reports, StageReport{
+				Index:             i + 1,
+				Start:             start,
+				Duration:          stage.Duration,
+				From:              from,
+				To:                stage.Target,
+				RequestsPerSecond: float64(summary.TotalRequests) / stage.Duration.Seconds(),
+				Summary:           summary,
+			})
+		}
+		start += stage.Duration
+		from = stage.Target
+	}
+	return reports
+}
+
+// mergeStageReports combines the stage reports of two runs of the same
+// stages. The workers of each run add up.
+func mergeStageReports(a, b []StageReport) []StageReport {
+	if len(a) == 0 {
+		a, b = b, a
+	}
+	merged := make([]StageReport, len(a))
+	for i, stage := range a {
+		if i < len(b) {
+			stage.From += b[i].From
+			stage.To += b[i].To
+			stage.Summary = mergeSummaries(stage.Summary, b[i].Summary)
+		}
+		if stage.Duration > 0 {
+			stage.RequestsPerSecond = float64(stage.TotalRequests) / stage.Duration.Seconds()
+		}
+		merged[i] = stage
+	}
+	return merged
+}
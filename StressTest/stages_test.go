@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseStages(t *testing.T) {
+	stages, err := parseStages("30s:50, 2m:50,10s:200")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []Stage{
+		{Duration: 30 * time.Second, Target: 50},
+		{Duration: 2 * time.Minute, Target: 50},
+		{Duration: 10 * time.Second, Target: 200},
+	}
+	if len(stages) != len(expected) {
+		t.Fatalf("Expected %d stages, got %d", len(expected), len(stages))
+	}
+	for i := range expected {
+		if stages[i] != expected[i] {
+			t.Errorf("Stage %d: expected %+v, got %+v", i, expected[i], stages[i])
+		}
+	}
+
+	for _, invalid := range []string{"", "30s", "abc:10", "30s:-1", "0s:10"} {
+		if _, err := parseStages(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseStepTest(t *testing.T) {
+	stages, err := parseStepTest("10:20:50:30s")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 10, 30, 50: a jump and a hold for each level
+	expected := []Stage{
+		{0, 10}, {30 * time.Second, 10},
+		{0, 30}, {30 * time.Second, 30},
+		{0, 50}, {30 * time.Second, 50},
+	}
+	if len(stages) != len(expected) {
+		t.Fatalf("Expected %d stages, got %d", len(expected), len(stages))
+	}
+	for i := range expected {
+		if stages[i] != expected[i] {
+			t.Errorf("Stage %d: expected %+v, got %+v", i, expected[i], stages[i])
+		}
+	}
+
+	for _, invalid := range []string{"10:10:100", "0:10:100:30s", "10:10:100:0s"} {
+		if _, err := parseStepTest(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestStageAt(t *testing.T) {
+	stages := []Stage{
+		{Duration: 30 * time.Second, Target: 50},
+		{Duration: time.Minute, Target: 50},
+		{Duration: 0, Target: 200},
+		{Duration: 10 * time.Second, Target: 200},
+	}
+
+	tests := []struct {
+		elapsed       time.Duration
+		expectedIndex int
+		expectedCount int
+	}{
+		{0, 0, 1},
+		{15 * time.Second, 0, 25},
+		{30 * time.Second, 1, 50},
+		{89 * time.Second, 1, 50},
+		{90 * time.Second, 3, 200},
+		{100 * time.Second, -1, 200},
+	}
+
+	for _, tt := range tests {
+		index, count := stageAt(stages, 1, tt.elapsed)
+		if index != tt.expectedIndex || count != tt.expectedCount {
+			t.Errorf("stageAt(%v) = (%d, %d), expected (%d, %d)", tt.elapsed, index, count, tt.expectedIndex, tt.expectedCount)
+		}
+	}
+}
+
+func TestRunStressTestStages(t *testing.T) {
+	var inFlight, maxInFlight int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	stages := []Stage{
+		{Duration: 300 * time.Millisecond, Target: 1},
+		{Duration: 0, Target: 6},
+		{Duration: 300 * time.Millisecond, Target: 6},
+	}
+	config := Config{
+		URL:         server.URL,
+		Concurrency: 1,
+		Stages:      stages,
+		Duration:    stagesDuration(stages),
+	}
+
+	report := runStressTest(config)
+
+	if len(report.Stages) != 2 {
+		t.Fatalf("Expected 2 stage reports (instant stages are omitted), got %d", len(report.Stages))
+	}
+	first, second := report.Stages[0], report.Stages[1]
+	if first.From != 1 || first.To != 1 || second.From != 6 || second.To != 6 {
+		t.Errorf("Unexpected stage ranges: %+v / %+v", first, second)
+	}
+	if first.TotalRequests+second.TotalRequests != report.TotalRequests {
+		t.Errorf("Expected stage totals to add up to %d, got %d + %d", report.TotalRequests, first.TotalRequests, second.TotalRequests)
+	}
+	if second.TotalRequests <= first.TotalRequests*2 {
+		t.Errorf("Expected the 6-worker stage to make far more requests than the 1-worker stage, got %d and %d", second.TotalRequests, first.TotalRequests)
+	}
+	if got := atomic.LoadInt64(&maxInFlight); got != 6 {
+		t.Errorf("Expected at most 6 concurrent requests to be reached, got %d", got)
+	}
+	if report.TotalTime > time.Second {
+		t.Errorf("Expected the run to end with the last stage, took %v", report.TotalTime)
+	}
+}
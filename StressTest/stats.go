@@ -0,0 +1,261 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// reportQuantiles are the percentiles shown in every report
+var reportQuantiles = []float64{50, 90, 95, 99, 99.9}
+
+// histogramBounds are the upper bounds of the latency histogram buckets.
+// Durations above the last bound fall into an open-ended overflow bucket.
+var histogramBounds = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// Percentile holds the latency at a given quantile (0-100)
+type Percentile struct {
+	Quantile float64       `json:"quantile"`
+	Value    time.Duration `json:"value_ns"`
+}
+
+// HistogramBucket counts the requests whose latency is in (From, To].
+// The overflow bucket has To == 0.
+type HistogramBucket struct {
+	From  time.Duration `json:"from_ns"`
+	To    time.Duration `json:"to_ns"`
+	Count int           `json:"count"`
+}
+
+// LatencyStats summarizes a set of request durations
+type LatencyStats struct {
+	AverageDuration time.Duration     `json:"average_duration_ns"`
+	MinDuration     time.Duration     `json:"min_duration_ns"`
+	MaxDuration     time.Duration     `json:"max_duration_ns"`
+	Percentiles     []Percentile      `json:"percentiles"`
+	Histogram       []HistogramBucket `json:"histogram"`
+	// Distribution is only kept in the reports of the agents, to be merged
+	Distribution latencyDistribution `json:"distribution,omitempty"`
+}
+
+// Percentile returns the latency recorded for quantile q, if it was computed
+func (s LatencyStats) Percentile(q float64) (time.Duration, bool) {
+	for _, p := range s.Percentiles {
+		if p.Quantile == q {
+			return p.Value, true
+		}
+	}
+	return 0, false
+}
+
+// computeLatencyStats builds the latency summary from every recorded duration.
+// The slice is sorted in place.
+func computeLatencyStats(durations []time.Duration) LatencyStats {
+	stats := LatencyStats{
+		Histogram:    newHistogram(),
+		Distribution: newLatencyDistribution(durations),
+	}
+	if len(durations) == 0 {
+		return stats
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+		stats.Histogram[bucketIndex(d)].Count++
+	}
+
+	stats.AverageDuration = total / time.Duration(len(durations))
+	stats.MinDuration = durations[0]
+	stats.MaxDuration = durations[len(durations)-1]
+
+	for _, q := range reportQuantiles {
+		stats.Percentiles = append(stats.Percentiles, Percentile{
+			Quantile: q,
+			Value:    percentileOf(durations, q),
+		})
+	}
+
+	return stats
+}
+
+// mergeLatencyStats combines the latency of two groups of requests. The
+// percentiles are estimated from the merged distribution.
+func mergeLatencyStats(a, b LatencyStats) LatencyStats {
+	merged := LatencyStats{
+		Histogram:    newHistogram(),
+		Distribution: make(latencyDistribution),
+	}
+	for _, stats := range []LatencyStats{a, b} {
+		for i, bucket := range stats.Histogram {
+			if i < len(merged.Histogram) {
+				merged.Histogram[i].Count += bucket.Count
+			}
+		}
+		merged.Distribution.merge(stats.Distribution)
+	}
+
+	countA, countB := a.Distribution.count(), b.Distribution.count()
+	switch {
+	case countA == 0 && countB == 0:
+		return merged
+	case countA == 0:
+		merged.MinDuration, merged.MaxDuration = b.MinDuration, b.MaxDuration
+	case countB == 0:
+		merged.MinDuration, merged.MaxDuration = a.MinDuration, a.MaxDuration
+	default:
+		merged.MinDuration, merged.MaxDuration = min(a.MinDuration, b.MinDuration), max(a.MaxDuration, b.MaxDuration)
+	}
+	merged.AverageDuration = weightedAverage(a.AverageDuration, countA, b.AverageDuration, countB)
+
+	for _, q := range reportQuantiles {
+		merged.Percentiles = append(merged.Percentiles, Percentile{
+			Quantile: q,
+			Value:    merged.Distribution.percentile(q, merged.MinDuration, merged.MaxDuration),
+		})
+	}
+	return merged
+}
+
+// weightedAverage combines the averages of two groups of a and b durations
+func weightedAverage(averageA time.Duration, a int, averageB time.Duration, b int) time.Duration {
+	if a+b == 0 {
+		return 0
+	}
+	return time.Duration((float64(averageA)*float64(a) + float64(averageB)*float64(b)) / float64(a+b))
+}
+
+// percentileOf returns the nearest-rank percentile of an already sorted slice
+func percentileOf(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// O epsilon evita que erros de ponto flutuante (99.9/100*1000) subam um rank
+	rank := int(math.Ceil(q/100*float64(len(sorted)) - 1e-9))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// newHistogram returns an empty histogram with the default bucket bounds
+func newHistogram() []HistogramBucket {
+	buckets := make([]HistogramBucket, 0, len(histogramBounds)+1)
+	var from time.Duration
+	for _, bound := range histogramBounds {
+		buckets = append(buckets, HistogramBucket{From: from, To: bound})
+		from = bound
+	}
+	return append(buckets, HistogramBucket{From: from})
+}
+
+// bucketIndex returns the histogram bucket a duration belongs to
+func bucketIndex(d time.Duration) int {
+	return sort.Search(len(histogramBounds), func(i int) bool {
+		return d <= histogramBounds[i]
+	})
+}
+
+// distributionPrecision is the number of bits kept from each duration in the
+// latency distribution: 2^7 buckets per power of two, a relative error under 1%
+const distributionPrecision = 7
+
+// latencyDistribution counts durations in log-linear buckets, keyed by
+// distributionIndex. Unlike the percentiles, the distributions of several
+// runs can be added up, which is how the reports of the agents are merged.
+type latencyDistribution map[int]int
+
+func newLatencyDistribution(durations []time.Duration) latencyDistribution {
+	distribution := make(latencyDistribution)
+	for _, d := range durations {
+		distribution[distributionIndex(d)]++
+	}
+	return distribution
+}
+
+// distributionIndex returns the bucket of a duration. Durations below
+// 2^distributionPrecision nanoseconds have a bucket each; above that, each
+// power of two is split in 2^distributionPrecision buckets.
+func distributionIndex(d time.Duration) int {
+	if d < 0 {
+		d = 0
+	}
+	v := uint64(d)
+	if v < 1<<distributionPrecision {
+		return int(v)
+	}
+	shift := bits.Len64(v) - distributionPrecision - 1
+	return (shift+1)<<distributionPrecision + int(v>>shift) - 1<<distributionPrecision
+}
+
+// distributionBucket returns the range [from, to) of the durations in a bucket
+func distributionBucket(index int) (from, to time.Duration) {
+	if index < 1<<distributionPrecision {
+		return time.Duration(index), time.Duration(index + 1)
+	}
+	shift := index>>distributionPrecision - 1
+	mantissa := uint64(index&(1<<distributionPrecision-1) + 1<<distributionPrecision)
+	return time.Duration(mantissa << shift), time.Duration((mantissa + 1) << shift)
+}
+
+func (d latencyDistribution) merge(other latencyDistribution) {
+	for index, count := range other {
+		d[index] += count
+	}
+}
+
+func (d latencyDistribution) count() int {
+	total := 0
+	for _, count := range d {
+		total += count
+	}
+	return total
+}
+
+// percentile estimates the nearest-rank percentile as the middle of its
+// bucket, kept within the known minimum and maximum
+func (d latencyDistribution) percentile(q float64, minDuration, maxDuration time.Duration) time.Duration {
+	total := d.count()
+	if total == 0 {
+		return 0
+	}
+	indexes := make([]int, 0, len(d))
+	for index := range d {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	rank := int(math.Ceil(q/100*float64(total) - 1e-9))
+	seen := 0
+	value := maxDuration
+	for _, index := range indexes {
+		seen += d[index]
+		if seen >= rank {
+			from, to := distributionBucket(index)
+			value = from + (to-from-1)/2
+			break
+		}
+	}
+	return min(max(value, minDuration), maxDuration)
+}
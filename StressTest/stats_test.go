@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeLatencyStats(t *testing.T) {
+	// 1ms, 2ms, ..., 1000ms
+	durations := make([]time.Duration, 0, 1000)
+	for i := 1000; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := computeLatencyStats(durations)
+
+	if stats.MinDuration != time.Millisecond {
+		t.Errorf("Expected min 1ms, got %v", stats.MinDuration)
+	}
+	if stats.MaxDuration != time.Second {
+		t.Errorf("Expected max 1s, got %v", stats.MaxDuration)
+	}
+	if stats.AverageDuration != 500500*time.Microsecond {
+		t.Errorf("Expected average 500.5ms, got %v", stats.AverageDuration)
+	}
+
+	expected := map[float64]time.Duration{
+		50:   500 * time.Millisecond,
+		90:   900 * time.Millisecond,
+		95:   950 * time.Millisecond,
+		99:   990 * time.Millisecond,
+		99.9: 999 * time.Millisecond,
+	}
+	for q, want := range expected {
+		got, ok := stats.Percentile(q)
+		if !ok {
+			t.Errorf("Percentile %v not computed", q)
+			continue
+		}
+		if got != want {
+			t.Errorf("Expected %s = %v, got %v", quantileLabel(q), want, got)
+		}
+	}
+
+	total := 0
+	for _, bucket := range stats.Histogram {
+		total += bucket.Count
+	}
+	if total != len(durations) {
+		t.Errorf("Expected histogram to hold %d requests, got %d", len(durations), total)
+	}
+	// (500ms, 1s] holds 501ms..1000ms
+	if got := stats.Histogram[bucketIndex(time.Second)].Count; got != 500 {
+		t.Errorf("Expected 500 requests in (500ms, 1s], got %d", got)
+	}
+}
+
+func TestComputeLatencyStatsEmpty(t *testing.T) {
+	stats := computeLatencyStats(nil)
+
+	if stats.AverageDuration != 0 || stats.MaxDuration != 0 {
+		t.Errorf("Expected zero stats, got %+v", stats)
+	}
+	if len(stats.Histogram) != len(histogramBounds)+1 {
+		t.Errorf("Expected %d buckets, got %d", len(histogramBounds)+1, len(stats.Histogram))
+	}
+}
+
+func TestBucketIndex(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected int
+	}{
+		{0, 0},
+		{time.Millisecond, 0},
+		{time.Millisecond + 1, 1},
+		{30 * time.Second, len(histogramBounds) - 1},
+		{time.Minute, len(histogramBounds)},
+	}
+
+	for _, tt := range tests {
+		if got := bucketIndex(tt.duration); got != tt.expected {
+			t.Errorf("bucketIndex(%v) = %d, expected %d", tt.duration, got, tt.expected)
+		}
+	}
+}
+
+func TestDistributionBucket(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 127, 128, 255, 256, 1000, time.Millisecond, 1234567 * time.Microsecond, 30 * time.Second} {
+		from, to := distributionBucket(distributionIndex(d))
+		if d < from || d >= to {
+			t.Errorf("Expected %v in its bucket, got [%v, %v)", d, from, to)
+		}
+		if float64(to-from) > float64(d)/100 && to-from > 1 {
+			t.Errorf("Bucket [%v, %v) of %v is wider than 1%%", from, to, d)
+		}
+	}
+}
+
+func TestMergeReports(t *testing.T) {
+	start := time.Now()
+	reportOf := func(parity int) Report {
+		c := newCollector(Config{URL: "http://example.com"}, start)
+		for i := 1; i <= 1000; i++ {
+			if i%2 == parity {
+				d := time.Duration(i) * time.Millisecond
+				c.add(Result{Start: start, Step: "login", StatusCode: 200, Duration: d})
+			}
+		}
+		c.add(Result{Start: start, Step: "login", Error: context.DeadlineExceeded, Duration: time.Minute})
+		return c.report()
+	}
+
+	merged := mergeReports(reportOf(0), reportOf(1))
+
+	// 1ms, 2ms, ..., 1000ms, the latency of a single run with every request
+	var durations []time.Duration
+	for i := 1; i <= 1000; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	expected := computeLatencyStats(durations)
+
+	if merged.TotalRequests != 1002 || merged.StatusCounts[200] != 1000 || merged.FailureCount != 2 {
+		t.Errorf("Unexpected counters: %d requests, %v, %d failures", merged.TotalRequests, merged.StatusCounts, merged.FailureCount)
+	}
+	if len(merged.Errors) != 1 || merged.Errors[0].Kind != errorTimeout || merged.Errors[0].Count != 2 {
+		t.Errorf("Expected 2 timeouts, got %+v", merged.Errors)
+	}
+	if merged.MinDuration != expected.MinDuration || merged.MaxDuration != expected.MaxDuration {
+		t.Errorf("Expected min %v and max %v, got %v and %v", expected.MinDuration, expected.MaxDuration, merged.MinDuration, merged.MaxDuration)
+	}
+	if diff := merged.AverageDuration - expected.AverageDuration; diff < -time.Microsecond || diff > time.Microsecond {
+		t.Errorf("Expected average %v, got %v", expected.AverageDuration, merged.AverageDuration)
+	}
+	for i, bucket := range expected.Histogram {
+		if merged.Histogram[i].Count != bucket.Count {
+			t.Errorf("Expected %d requests in bucket %d, got %d", bucket.Count, i, merged.Histogram[i].Count)
+		}
+	}
+	for _, want := range expected.Percentiles {
+		got, ok := merged.Percentile(want.Quantile)
+		if !ok || math.Abs(float64(got-want.Value)) > float64(want.Value)/100 {
+			t.Errorf("Expected p%v within 1%% of %v, got %v", want.Quantile, want.Value, got)
+		}
+	}
+
+	if len(merged.Steps) != 1 || merged.Steps[0].TotalRequests != 1002 {
+		t.Errorf("Expected the login step merged, got %+v", merged.Steps)
+	}
+	seriesTotal := 0
+	for _, point := range merged.TimeSeries {
+		seriesTotal += point.Requests
+	}
+	if seriesTotal != 1002 || merged.TimeSeries[0].Requests != 999 {
+		t.Errorf("Expected the series of both reports merged, got %d requests (%d in second 0)", seriesTotal, merged.TimeSeries[0].Requests)
+	}
+	if p95, _ := merged.Steps[0].Percentile(95); p95 < 940*time.Millisecond || p95 > 960*time.Millisecond {
+		t.Errorf("Expected the step p95 near 950ms, got %v", p95)
+	}
+}
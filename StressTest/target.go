@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Latency distributions accepted by --latency
+const (
+	latencyFixed       = "fixed"
+	latencyUniform     = "uniform"
+	latencyNormal      = "normal"
+	latencyExponential = "exponential"
+)
+
+// LatencyDistribution describes how long the target waits before answering.
+// Min and Max are the bounds of the uniform distribution; Mean and StdDev
+// are used by the other ones.
+type LatencyDistribution struct {
+	Kind   string
+	Mean   time.Duration
+	StdDev time.Duration
+	Min    time.Duration
+	Max    time.Duration
+}
+
+// WeightedStatus is a status code answered in Weight of every total weight responses
+type WeightedStatus struct {
+	Code   int
+	Weight int
+}
+
+// TargetConfig holds the parameters of the "stresstest target" subcommand
+type TargetConfig struct {
+	Latency  LatencyDistribution
+	Statuses []WeightedStatus
+	// ErrorRate is the fraction of requests answered by resetting the connection
+	ErrorRate   float64
+	PayloadSize int
+	// Echo answers with the request body instead of a payload of PayloadSize bytes
+	Echo bool
+}
+
+// parseLatency parses "20ms", "fixed:20ms", "uniform:10ms-50ms",
+// "normal:50ms,10ms" (mean, standard deviation) or "exponential:20ms" (mean)
+func parseLatency(raw string) (LatencyDistribution, error) {
+	raw = strings.TrimSpace(raw)
+	kind, params, found := strings.Cut(raw, ":")
+	if !found {
+		kind, params = latencyFixed, raw
+	}
+
+	invalid := fmt.Errorf("latência inválida: %q", raw)
+	switch kind {
+	case latencyFixed, latencyExponential:
+		mean, err := time.ParseDuration(params)
+		if err != nil || mean < 0 {
+			return LatencyDistribution{}, invalid
+		}
+		return LatencyDistribution{Kind: kind, Mean: mean}, nil
+	case latencyUniform:
+		rawMin, rawMax, found := strings.Cut(params, "-")
+		minimum, errMin := time.ParseDuration(rawMin)
+		maximum, errMax := time.ParseDuration(rawMax)
+		if !found || errMin != nil || errMax != nil || minimum < 0 || maximum < minimum {
+			return LatencyDistribution{}, fmt.Errorf("latência inválida: %q (use uniform:min-max)", raw)
+		}
+		return LatencyDistribution{Kind: kind, Min: minimum, Max: maximum}, nil
+	case latencyNormal:
+		rawMean, rawStdDev, found := strings.Cut(params, ",")
+		mean, errMean := time.ParseDuration(rawMean)
+		stdDev, errStdDev := time.ParseDuration(rawStdDev)
+		if !found || errMean != nil || errStdDev != nil || mean < 0 || stdDev < 0 {
+			return LatencyDistribution{}, fmt.Errorf("latência inválida: %q (use normal:média,desvio)", raw)
+		}
+		return LatencyDistribution{Kind: kind, Mean: mean, StdDev: stdDev}, nil
+	default:
+		return LatencyDistribution{}, fmt.Errorf("distribuição de latência desconhecida: %s (use fixed, uniform, normal ou exponential)", kind)
+	}
+}
+
+// sample draws a latency from the distribution. Negative draws of the normal
+// distribution are answered immediately.
+func (d LatencyDistribution) sample() time.Duration {
+	var latency float64
+	switch d.Kind {
+	case latencyUniform:
+		latency = float64(d.Min) + mathrand.Float64()*float64(d.Max-d.Min)
+	case latencyNormal:
+		latency = float64(d.Mean) + mathrand.NormFloat64()*float64(d.StdDev)
+	case latencyExponential:
+		latency = mathrand.ExpFloat64() * float64(d.Mean)
+	default:
+		latency = float64(d.Mean)
+	}
+	return time.Duration(math.Max(latency, 0))
+}
+
+func (d LatencyDistribution) String() string {
+	switch d.Kind {
+	case latencyUniform:
+		return fmt.Sprintf("uniform %v-%v", d.Min, d.Max)
+	case latencyNormal:
+		return fmt.Sprintf("normal %v±%v", d.Mean, d.StdDev)
+	case latencyExponential:
+		return fmt.Sprintf("exponential %v", d.Mean)
+	default:
+		return d.Mean.String()
+	}
+}
+
+// parseStatuses parses "200" or weighted codes such as "200:90,404:5,500:5"
+func parseStatuses(raw string) ([]WeightedStatus, error) {
+	var statuses []WeightedStatus
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rawCode, rawWeight, found := strings.Cut(part, ":")
+		code, err := strconv.Atoi(strings.TrimSpace(rawCode))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("status inválido: %q", part)
+		}
+		weight := 1
+		if found {
+			weight, err = strconv.Atoi(strings.TrimSpace(rawWeight))
+			if err != nil || weight < 1 {
+				return nil, fmt.Errorf("peso inválido no status %q", part)
+			}
+		}
+		statuses = append(statuses, WeightedStatus{Code: code, Weight: weight})
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("nenhum status informado")
+	}
+	return statuses, nil
+}
+
+// parseSize parses a byte count such as "512", "512B", "4KB" or "1MB"
+func parseSize(raw string) (int, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	multiplier := 1
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"B", 1}} {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			value, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("tamanho inválido: %q", raw)
+	}
+	return size * multiplier, nil
+}
+
+func parseTargetFlags(args []string) (string, TargetConfig) {
+	fs := flag.NewFlagSet("stresstest target", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "Endereço onde o alvo recebe os requests")
+	latency := fs.String("latency", "0s", "Latência das respostas: 20ms, uniform:10ms-50ms, normal:50ms,10ms ou exponential:20ms")
+	status := fs.String("status", "200", "Status das respostas, com pesos opcionais (ex: 200:90,500:10)")
+	errorRate := fs.String("error-rate", "0%", "Fração dos requests respondidos com a conexão resetada (ex: 1% ou 0.01)")
+	payloadSize := fs.String("payload-size", "0", "Tamanho do corpo das respostas (ex: 512, 4KB, 1MB)")
+	echo := fs.Bool("echo", false, "Responde com o corpo do request em vez do payload")
+	fs.Parse(args)
+
+	var config TargetConfig
+	var err error
+	if config.Latency, err = parseLatency(*latency); err != nil {
+		log.Fatalf("Parâmetro --latency inválido: %v", err)
+	}
+	if config.Statuses, err = parseStatuses(*status); err != nil {
+		log.Fatalf("Parâmetro --status inválido: %v", err)
+	}
+	config.ErrorRate, err = parseRatio(*errorRate)
+	if err != nil || config.ErrorRate < 0 || config.ErrorRate > 1 {
+		log.Fatalf("Parâmetro --error-rate inválido: %s", *errorRate)
+	}
+	if config.PayloadSize, err = parseSize(*payloadSize); err != nil {
+		log.Fatalf("Parâmetro --payload-size inválido: %v", err)
+	}
+	config.Echo = *echo
+
+	return *listen, config
+}
+
+// runTarget serves a local target with the configured behavior until the
+// process is stopped
+func runTarget(args []string) {
+	listen, config := parseTargetFlags(args)
+
+	log.Printf("Alvo aguardando requests em %s (latência %s, status %s, erros %.2f%%, payload %d bytes)",
+		listen, config.Latency, config.statusLabel(), config.ErrorRate*100, config.PayloadSize)
+	log.Fatal(http.ListenAndServe(listen, newTargetHandler(config)))
+}
+
+func (c TargetConfig) statusLabel() string {
+	parts := make([]string, len(c.Statuses))
+	for i, status := range c.Statuses {
+		parts[i] = fmt.Sprintf("%d:%d", status.Code, status.Weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+// targetHandler answers every request according to its TargetConfig
+type targetHandler struct {
+	config      TargetConfig
+	payload     []byte
+	totalWeight int
+}
+
+func newTargetHandler(config TargetConfig) http.Handler {
+	if len(config.Statuses) == 0 {
+		config.Statuses = []WeightedStatus{{Code: http.StatusOK, Weight: 1}}
+	}
+	h := &targetHandler{config: config, payload: bytes.Repeat([]byte("x"), config.PayloadSize)}
+	for _, status := range config.Statuses {
+		h.totalWeight += status.Weight
+	}
+	return h
+}
+
+func (h *targetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// O corpo é lido antes da espera, como faria um serviço real
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+
+	if latency := h.config.Latency.sample(); latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if h.config.ErrorRate > 0 && mathrand.Float64() < h.config.ErrorRate {
+		resetConnection(w)
+		return
+	}
+
+	payload := h.payload
+	if h.config.Echo {
+		payload = body
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+	w.WriteHeader(h.status())
+	w.Write(payload)
+}
+
+// status picks a status code according to the weights
+func (h *targetHandler) status() int {
+	n := mathrand.Intn(h.totalWeight)
+	for _, status := range h.config.Statuses {
+		if n < status.Weight {
+			return status.Code
+		}
+		n -= status.Weight
+	}
+	return h.config.Statuses[len(h.config.Statuses)-1].Code
+}
+
+// resetConnection drops the connection without answering. On HTTP/1 the
+// connection is closed with a TCP reset, so the client sees the same error
+// as from a crashed service; HTTP/2 only aborts the stream.
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLatency(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    LatencyDistribution
+		wantErr bool
+	}{
+		{"20ms", LatencyDistribution{Kind: latencyFixed, Mean: 20 * time.Millisecond}, false},
+		{"fixed:1s", LatencyDistribution{Kind: latencyFixed, Mean: time.Second}, false},
+		{"uniform:10ms-50ms", LatencyDistribution{Kind: latencyUniform, Min: 10 * time.Millisecond, Max: 50 * time.Millisecond}, false},
+		{"normal:50ms,10ms", LatencyDistribution{Kind: latencyNormal, Mean: 50 * time.Millisecond, StdDev: 10 * time.Millisecond}, false},
+		{"exponential:20ms", LatencyDistribution{Kind: latencyExponential, Mean: 20 * time.Millisecond}, false},
+		{"uniform:50ms-10ms", LatencyDistribution{}, true},
+		{"normal:50ms", LatencyDistribution{}, true},
+		{"pareto:20ms", LatencyDistribution{}, true},
+		{"-5ms", LatencyDistribution{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLatency(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestLatencySample(t *testing.T) {
+	uniform := LatencyDistribution{Kind: latencyUniform, Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}
+	normal := LatencyDistribution{Kind: latencyNormal, Mean: time.Millisecond, StdDev: 10 * time.Millisecond}
+
+	for i := 0; i < 1000; i++ {
+		if got := uniform.sample(); got < uniform.Min || got > uniform.Max {
+			t.Fatalf("Expected a uniform latency between %v and %v, got %v", uniform.Min, uniform.Max, got)
+		}
+		if got := normal.sample(); got < 0 {
+			t.Fatalf("Expected no negative latency, got %v", got)
+		}
+	}
+}
+
+func TestParseStatuses(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []WeightedStatus
+		wantErr bool
+	}{
+		{"200", []WeightedStatus{{200, 1}}, false},
+		{"200:90, 500:10", []WeightedStatus{{200, 90}, {500, 10}}, false},
+		{"200:0", nil, true},
+		{"99", nil, true},
+		{"abc", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatuses(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.input, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"4KB", 4096, false},
+		{"1mb", 1 << 20, false},
+		{"-1", 0, true},
+		{"1GB", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestTargetHandler(t *testing.T) {
+	server := httptest.NewServer(newTargetHandler(TargetConfig{
+		Statuses:    []WeightedStatus{{Code: http.StatusCreated, Weight: 1}},
+		PayloadSize: 1024,
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", resp.StatusCode)
+	}
+	if len(body) != 1024 {
+		t.Errorf("Expected a 1024 byte payload, got %d", len(body))
+	}
+}
+
+func TestTargetHandlerEcho(t *testing.T) {
+	server := httptest.NewServer(newTargetHandler(TargetConfig{Echo: true, PayloadSize: 10}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "text/plain", strings.NewReader("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "ping" {
+		t.Errorf("Expected the request body echoed with status 200, got %d %q", resp.StatusCode, body)
+	}
+}
+
+// TestRunStressTestAgainstTarget runs the load generator against the local
+// target, with no external service
+func TestRunStressTestAgainstTarget(t *testing.T) {
+	server := httptest.NewServer(newTargetHandler(TargetConfig{
+		Latency:  LatencyDistribution{Kind: latencyFixed, Mean: 20 * time.Millisecond},
+		Statuses: []WeightedStatus{{Code: 200, Weight: 3}, {Code: 503, Weight: 1}},
+	}))
+	defer server.Close()
+
+	report := runStressTest(Config{URL: server.URL, Method: http.MethodGet, Requests: 200, Concurrency: 20})
+
+	if report.TotalRequests != 200 || report.StatusCounts[200]+report.StatusCounts[503] != 200 {
+		t.Fatalf("Expected 200 responses, got %v", report.StatusCounts)
+	}
+	// Com pesos 3:1, 503 fica bem longe de 0% e de 50% em 200 requests
+	if share := float64(report.StatusCounts[503]) / 200; share < 0.1 || share > 0.4 {
+		t.Errorf("Expected about 25%% of 503 responses, got %.0f%%", share*100)
+	}
+	if report.MinDuration < 20*time.Millisecond {
+		t.Errorf("Expected every latency to include the 20ms delay, got min %v", report.MinDuration)
+	}
+}
+
+func TestRunStressTestTargetErrors(t *testing.T) {
+	server := httptest.NewServer(newTargetHandler(TargetConfig{ErrorRate: 1}))
+	defer server.Close()
+
+	report := runStressTest(Config{URL: server.URL, Method: http.MethodGet, Requests: 10, Concurrency: 2})
+
+	if report.SuccessCount != 0 || len(report.Errors) != 1 {
+		t.Fatalf("Expected every request to fail, got %d successes and errors %+v", report.SuccessCount, report.Errors)
+	}
+	if group := report.Errors[0]; group.Kind != errorConnectionReset || group.Count != 10 {
+		t.Errorf("Expected 10 connection resets, got %+v", group)
+	}
+}
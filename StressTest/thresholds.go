@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is a run-level pass/fail criterion, such as "p95<300ms" or "errors<1%".
+// Value is in the unit of the metric: nanoseconds for latencies, a ratio for errors.
+type Threshold struct {
+	Expression string
+	Metric     string
+	Operator   string
+	Value      float64
+}
+
+// ThresholdResult is the outcome of a threshold for a finished run
+type ThresholdResult struct {
+	Expression string `json:"expression"`
+	Actual     string `json:"actual"`
+	Passed     bool   `json:"passed"`
+}
+
+var thresholdPattern = regexp.MustCompile(`^\s*([a-z][a-z0-9.]*)\s*(<=|>=|<|>)\s*(\S+)\s*$`)
+
+// parseThreshold parses "<metric><op><value>". The metrics are avg, min, max,
+// p50/p90/p95/p99/p99.9 (latencies, ex: 300ms), errors (ex: 1% or 0.01), rps
+// and requests.
+func parseThreshold(raw string) (Threshold, error) {
+	matches := thresholdPattern.FindStringSubmatch(strings.ToLower(raw))
+	if matches == nil {
+		return Threshold{}, fmt.Errorf("threshold %q inválido, use métrica<valor (ex: p95<300ms, errors<1%%)", raw)
+	}
+	threshold := Threshold{Expression: strings.TrimSpace(raw), Metric: matches[1], Operator: matches[2]}
+	rawValue := matches[3]
+
+	var err error
+	switch {
+	case isLatencyMetric(threshold.Metric):
+		var d time.Duration
+		d, err = time.ParseDuration(rawValue)
+		threshold.Value = float64(d)
+	case threshold.Metric == "errors":
+		if percent, found := strings.CutSuffix(rawValue, "%"); found {
+			threshold.Value, err = strconv.ParseFloat(percent, 64)
+			threshold.Value /= 100
+		} else {
+			threshold.Value, err = strconv.ParseFloat(rawValue, 64)
+		}
+	case threshold.Metric == "rps", threshold.Metric == "requests":
+		threshold.Value, err = strconv.ParseFloat(rawValue, 64)
+	default:
+		return Threshold{}, fmt.Errorf("threshold %q: métrica desconhecida %s", raw, threshold.Metric)
+	}
+	if err != nil {
+		return Threshold{}, fmt.Errorf("threshold %q: valor inválido %s", raw, rawValue)
+	}
+	return threshold, nil
+}
+
+func isLatencyMetric(metric string) bool {
+	switch metric {
+	case "avg", "min", "max":
+		return true
+	}
+	_, ok := metricQuantile(metric)
+	return ok
+}
+
+// metricQuantile returns the quantile of a "pN" metric, which must be one of
+// the reported percentiles
+func metricQuantile(metric string) (float64, bool) {
+	quantile, found := strings.CutPrefix(metric, "p")
+	if !found {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(quantile, 64)
+	if err != nil {
+		return 0, false
+	}
+	for _, reported := range reportQuantiles {
+		if q == reported {
+			return q, true
+		}
+	}
+	return 0, false
+}
+
+// actual returns the value of the metric in the report and its formatted
+// form, which is empty when the report has no value for the metric
+func (t Threshold) actual(report Report) (float64, string) {
+	switch t.Metric {
+	case "avg":
+		return float64(report.AverageDuration), report.AverageDuration.String()
+	case "min":
+		return float64(report.MinDuration), report.MinDuration.String()
+	case "max":
+		return float64(report.MaxDuration), report.MaxDuration.String()
+	case "errors":
+		rate := report.ErrorRate()
+		return rate, fmt.Sprintf("%.2f%%", rate*100)
+	case "rps":
+		rps := report.RequestsPerSecond()
+		return rps, fmt.Sprintf("%.2f", rps)
+	case "requests":
+		return float64(report.TotalRequests), strconv.Itoa(report.TotalRequests)
+	}
+
+	q, _ := metricQuantile(t.Metric)
+	value, ok := report.Percentile(q)
+	if !ok {
+		return 0, ""
+	}
+	return float64(value), value.String()
+}
+
+// evaluate checks the threshold against the report. A metric without value
+// (ex: percentiles of a run without requests) fails the threshold.
+func (t Threshold) evaluate(report Report) ThresholdResult {
+	value, formatted := t.actual(report)
+	result := ThresholdResult{Expression: t.Expression, Actual: formatted}
+	if formatted == "" {
+		result.Actual = "indisponível"
+		return result
+	}
+	switch t.Operator {
+	case "<":
+		result.Passed = value < t.Value
+	case "<=":
+		result.Passed = value <= t.Value
+	case ">":
+		result.Passed = value > t.Value
+	case ">=":
+		result.Passed = value >= t.Value
+	}
+	return result
+}
+
+func evaluateThresholds(thresholds []Threshold, report Report) []ThresholdResult {
+	var results []ThresholdResult
+	for _, threshold := range thresholds {
+		results = append(results, threshold.evaluate(report))
+	}
+	return results
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected Threshold
+		wantErr  bool
+	}{
+		{"p95<300ms", Threshold{Metric: "p95", Operator: "<", Value: float64(300 * time.Millisecond)}, false},
+		{"p99.9 <= 1s", Threshold{Metric: "p99.9", Operator: "<=", Value: float64(time.Second)}, false},
+		{"avg<200ms", Threshold{Metric: "avg", Operator: "<", Value: float64(200 * time.Millisecond)}, false},
+		{"errors<1%", Threshold{Metric: "errors", Operator: "<", Value: 0.01}, false},
+		{"errors<=0.05", Threshold{Metric: "errors", Operator: "<=", Value: 0.05}, false},
+		{"rps>=100", Threshold{Metric: "rps", Operator: ">=", Value: 100}, false},
+		{"requests>10", Threshold{Metric: "requests", Operator: ">", Value: 10}, false},
+		{"p42<1s", Threshold{}, true},
+		{"p95<fast", Threshold{}, true},
+		{"latency<1s", Threshold{}, true},
+		{"p95=300ms", Threshold{}, true},
+		{"errors<abc%", Threshold{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseThreshold(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Metric != tt.expected.Metric || got.Operator != tt.expected.Operator || got.Value != tt.expected.Value {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	report := sampleReport()
+
+	tests := []struct {
+		raw    string
+		passed bool
+	}{
+		{"p50<=20ms", true},
+		{"p99<300ms", false},
+		{"max<1s", true},
+		{"errors<60%", true},
+		{"errors<50%", false},
+		{"rps>=2", true},
+		{"requests>4", false},
+	}
+
+	for _, tt := range tests {
+		threshold, err := parseThreshold(tt.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.raw, err)
+		}
+		result := threshold.evaluate(report)
+		if result.Passed != tt.passed {
+			t.Errorf("%s: expected passed=%v, got %+v", tt.raw, tt.passed, result)
+		}
+	}
+}
+
+func TestEvaluateThresholdWithoutRequests(t *testing.T) {
+	threshold, err := parseThreshold("p95<1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := threshold.evaluate(Report{})
+	if result.Passed || result.Actual != "indisponível" {
+		t.Errorf("Expected unavailable percentile to fail, got %+v", result)
+	}
+}
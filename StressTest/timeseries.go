@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// TimeSeriesPoint holds the results of the requests that finished during one
+// second of the run
+type TimeSeriesPoint struct {
+	Second          int           `json:"second"`
+	Requests        int           `json:"requests"`
+	SuccessCount    int           `json:"success_count"`
+	FailureCount    int           `json:"failure_count"`
+	StatusCounts    map[int]int   `json:"status_counts"`
+	AverageDuration time.Duration `json:"average_duration_ns"`
+	P50             time.Duration `json:"p50_ns"`
+	P95             time.Duration `json:"p95_ns"`
+	P99             time.Duration `json:"p99_ns"`
+	MaxDuration     time.Duration `json:"max_duration_ns"`
+	// Distribution is only kept in the reports of the agents, to be merged
+	Distribution latencyDistribution `json:"distribution,omitempty"`
+}
+
+// timeSeries groups results by the second of the run in which they finished
+type timeSeries struct {
+	startTime time.Time
+	seconds   map[int]*summaryBuilder
+}
+
+func newTimeSeries(startTime time.Time) *timeSeries {
+	return &timeSeries{
+		startTime: startTime,
+		seconds:   make(map[int]*summaryBuilder),
+	}
+}
+
+func (ts *timeSeries) add(result Result) {
+	second := int(result.Start.Add(result.Duration).Sub(ts.startTime) / time.Second)
+	if second < 0 {
+		second = 0
+	}
+	builder, ok := ts.seconds[second]
+	if !ok {
+		builder = newSummaryBuilder()
+		ts.seconds[second] = builder
+	}
+	builder.add(result)
+}
+
+// points returns one point per second, including the seconds without results
+func (ts *timeSeries) points() []TimeSeriesPoint {
+	if len(ts.seconds) == 0 {
+		return nil
+	}
+
+	seconds := make([]int, 0, len(ts.seconds))
+	for second := range ts.seconds {
+		seconds = append(seconds, second)
+	}
+	sort.Ints(seconds)
+
+	last := seconds[len(seconds)-1]
+	points := make([]TimeSeriesPoint, 0, last+1)
+	for second := 0; second <= last; second++ {
+		point := TimeSeriesPoint{Second: second, StatusCounts: make(map[int]int)}
+		if builder, ok := ts.seconds[second]; ok {
+			summary := builder.build()
+			point.Requests = summary.TotalRequests
+			point.SuccessCount = summary.SuccessCount
+			point.FailureCount = summary.FailureCount
+			point.StatusCounts = summary.StatusCounts
+			point.AverageDuration = summary.AverageDuration
+			point.P50, _ = summary.Percentile(50)
+			point.P95, _ = summary.Percentile(95)
+			point.P99, _ = summary.Percentile(99)
+			point.MaxDuration = summary.MaxDuration
+			point.Distribution = summary.Distribution
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// mergeTimeSeries combines the series of two runs that started together,
+// second by second
+func mergeTimeSeries(a, b []TimeSeriesPoint) []TimeSeriesPoint {
+	points := make([]TimeSeriesPoint, max(len(a), len(b)))
+	for second := range points {
+		var pointA, pointB TimeSeriesPoint
+		if second < len(a) {
+			pointA = a[second]
+		}
+		if second < len(b) {
+			pointB = b[second]
+		}
+		points[second] = mergeTimeSeriesPoints(second, pointA, pointB)
+	}
+	return points
+}
+
+func mergeTimeSeriesPoints(second int, a, b TimeSeriesPoint) TimeSeriesPoint {
+	point := TimeSeriesPoint{
+		Second:       second,
+		Requests:     a.Requests + b.Requests,
+		SuccessCount: a.SuccessCount + b.SuccessCount,
+		FailureCount: a.FailureCount + b.FailureCount,
+		StatusCounts: make(map[int]int),
+		MaxDuration:  max(a.MaxDuration, b.MaxDuration),
+		Distribution: make(latencyDistribution),
+	}
+	for _, p := range []TimeSeriesPoint{a, b} {
+		for status, count := range p.StatusCounts {
+			point.StatusCounts[status] += count
+		}
+		point.Distribution.merge(p.Distribution)
+	}
+	point.AverageDuration = weightedAverage(a.AverageDuration, a.Distribution.count(), b.AverageDuration, b.Distribution.count())
+
+	point.P50 = point.Distribution.percentile(50, 0, point.MaxDuration)
+	point.P95 = point.Distribution.percentile(95, 0, point.MaxDuration)
+	point.P99 = point.Distribution.percentile(99, 0, point.MaxDuration)
+	return point
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	start := time.Now()
+	ts := newTimeSeries(start)
+
+	// Bucketed by the second in which the request finished
+	ts.add(Result{Start: start, Duration: 100 * time.Millisecond, StatusCode: 200})
+	ts.add(Result{Start: start.Add(500 * time.Millisecond), Duration: 300 * time.Millisecond, StatusCode: 500,
+		AssertionError: &assertionError{assertionStatus, "status 500 fora de 200"}})
+	ts.add(Result{Start: start.Add(900 * time.Millisecond), Duration: 200 * time.Millisecond, StatusCode: 200})
+	ts.add(Result{Start: start.Add(2500 * time.Millisecond), Duration: 10 * time.Millisecond, Error: errors.New("refused")})
+
+	points := ts.points()
+	if len(points) != 3 {
+		t.Fatalf("Expected 3 points (seconds 0 to 2), got %d", len(points))
+	}
+
+	if points[0].Requests != 2 || points[0].SuccessCount != 1 || points[0].StatusCounts[500] != 1 {
+		t.Errorf("Unexpected point for second 0: %+v", points[0])
+	}
+	if points[0].MaxDuration != 300*time.Millisecond {
+		t.Errorf("Expected max 300ms in second 0, got %v", points[0].MaxDuration)
+	}
+	if points[1].Requests != 1 || points[1].P95 != 200*time.Millisecond {
+		t.Errorf("Unexpected point for second 1: %+v", points[1])
+	}
+	if points[2].FailureCount != 1 || points[2].MaxDuration != 0 {
+		t.Errorf("Expected one failure without latency in second 2, got %+v", points[2])
+	}
+}
+
+func TestTimeSeriesFillsEmptySeconds(t *testing.T) {
+	start := time.Now()
+	ts := newTimeSeries(start)
+	ts.add(Result{Start: start.Add(3 * time.Second), Duration: time.Millisecond, StatusCode: 200})
+
+	points := ts.points()
+	if len(points) != 4 {
+		t.Fatalf("Expected 4 points, got %d", len(points))
+	}
+	for _, point := range points[:3] {
+		if point.Requests != 0 || point.StatusCounts == nil {
+			t.Errorf("Expected empty point for second %d, got %+v", point.Second, point)
+		}
+	}
+}